@@ -0,0 +1,366 @@
+package admin
+
+import (
+    "crypto/subtle"
+    "encoding/json"
+    "errors"
+    "github.com/sprinkle-it/donut/file"
+    "github.com/sprinkle-it/donut/game"
+    "github.com/sprinkle-it/donut/logging"
+    "github.com/sprinkle-it/donut/message"
+    "github.com/sprinkle-it/donut/server"
+    "go.uber.org/zap"
+    "go.uber.org/zap/zapcore"
+    "net/http"
+    "strconv"
+    "strings"
+)
+
+type Config struct {
+    // The shared logger, the admin API logs to a sub-logger named "admin".
+    Logger *zap.Logger
+
+    // The address that the admin API listens on.
+    Address string
+
+    // The token that callers must provide as a bearer token in the Authorization header of every request.
+    Token string
+
+    Server      Server
+    FileService *file.Service
+
+    // The log levels that can be changed through the admin API. Optional.
+    LogLevels *logging.Levels
+
+    // Reloads the archives that the file service serves from the cache directory. Optional.
+    Reload func() error
+}
+
+// The operations of the server that the admin API uses. Implemented by *server.Server.
+type Server interface {
+    Clients() []*server.Client
+    Kick(id uint64) bool
+    Broadcast(stage server.Stage, msg message.Outbound) int
+}
+
+func (cfg Config) Build() (*Admin, error) {
+    if cfg.Token == "" {
+        return nil, errors.New("admin: a token is required to serve the admin API")
+    }
+
+    if cfg.Server == nil {
+        return nil, errors.New("admin: a server is required to serve the admin API")
+    }
+
+    if cfg.Logger == nil {
+        return nil, errors.New("admin: a logger is required")
+    }
+
+    admin := &Admin{
+        logger:      cfg.Logger.Named("admin"),
+        address:     cfg.Address,
+        token:       []byte(cfg.Token),
+        server:      cfg.Server,
+        fileService: cfg.FileService,
+        logLevels:   cfg.LogLevels,
+        reload:      cfg.Reload,
+        mux:         http.NewServeMux(),
+    }
+
+    admin.mux.HandleFunc("/clients", admin.handleClients)
+    admin.mux.HandleFunc("/clients/", admin.handleClient)
+    admin.mux.HandleFunc("/broadcast", admin.handleBroadcast)
+    admin.mux.HandleFunc("/update", admin.handleUpdate)
+    admin.mux.HandleFunc("/file/sessions", admin.handleFileSessions)
+    admin.mux.HandleFunc("/file/reload", admin.handleFileReload)
+    admin.mux.HandleFunc("/log/levels", admin.handleLogLevels)
+    admin.mux.HandleFunc("/log/levels/", admin.handleLogLevel)
+
+    return admin, nil
+}
+
+// An embedded HTTP/JSON API for operating a running server. Every request must be authorized with the configured token.
+type Admin struct {
+    logger      *zap.Logger
+    address     string
+    token       []byte
+    server      Server
+    fileService *file.Service
+    logLevels   *logging.Levels
+    reload      func() error
+    mux         *http.ServeMux
+}
+
+func New(config Config) (*Admin, error) {
+    return config.Build()
+}
+
+// Listens on the configured address and serves the admin API. This function blocks until the listener fails.
+func (a *Admin) Listen() error {
+    a.logger.Info("Serving admin API", zap.String("address", a.address))
+    return http.ListenAndServe(a.address, a)
+}
+
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    header := r.Header.Get("Authorization")
+    if !strings.HasPrefix(header, "Bearer ") ||
+        subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), a.token) != 1 {
+        writeError(w, http.StatusUnauthorized, "invalid or missing token")
+        return
+    }
+
+    a.mux.ServeHTTP(w, r)
+}
+
+type clientView struct {
+    Id      uint64       `json:"id"`
+    Address string       `json:"address"`
+    Stage   server.Stage `json:"stage"`
+}
+
+// GET /clients lists all of the clients connected to the server.
+func (a *Admin) handleClients(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+        return
+    }
+
+    clients := a.server.Clients()
+
+    views := make([]clientView, 0, len(clients))
+    for _, cli := range clients {
+        views = append(views, clientView{
+            Id:      cli.Id(),
+            Address: cli.RemoteAddress().String(),
+            Stage:   cli.Stage(),
+        })
+    }
+
+    writeJSON(w, http.StatusOK, views)
+}
+
+// POST /clients/{id}/kick closes the connection of a client.
+func (a *Admin) handleClient(w http.ResponseWriter, r *http.Request) {
+    parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/clients/"), "/")
+    if len(parts) != 2 || parts[1] != "kick" {
+        writeError(w, http.StatusNotFound, "not found")
+        return
+    }
+
+    if r.Method != http.MethodPost {
+        writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+        return
+    }
+
+    id, err := strconv.ParseUint(parts[0], 10, 64)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid client id")
+        return
+    }
+
+    if !a.server.Kick(id) {
+        writeError(w, http.StatusNotFound, "client not found")
+        return
+    }
+
+    a.logger.Info("Kicked client through admin API", zap.Uint64("id", id))
+
+    w.WriteHeader(http.StatusNoContent)
+}
+
+type broadcastRequest struct {
+    Text string `json:"text"`
+}
+
+// POST /broadcast displays a system message to every player in the game.
+func (a *Admin) handleBroadcast(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+        return
+    }
+
+    var req broadcastRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Text == "" {
+        writeError(w, http.StatusBadRequest, "expected a body with non-empty text")
+        return
+    }
+
+    count := a.server.Broadcast(game.Stage, &game.DisplaySystemMessage{Text: req.Text})
+
+    a.logger.Info("Broadcast system message through admin API",
+        zap.String("text", req.Text),
+        zap.Int("recipients", count),
+    )
+
+    writeJSON(w, http.StatusOK, map[string]int{"recipients": count})
+}
+
+type updateRequest struct {
+    Ticks uint16 `json:"ticks"`
+}
+
+// POST /update starts the system update countdown for every player in the game.
+func (a *Admin) handleUpdate(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+        return
+    }
+
+    var req updateRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Ticks == 0 {
+        writeError(w, http.StatusBadRequest, "expected a body with a non-zero number of ticks")
+        return
+    }
+
+    count := a.server.Broadcast(game.Stage, &game.SetSystemUpdateTimer{Ticks: req.Ticks})
+
+    a.logger.Info("Started update countdown through admin API",
+        zap.Uint16("ticks", req.Ticks),
+        zap.Int("recipients", count),
+    )
+
+    writeJSON(w, http.StatusOK, map[string]int{"recipients": count})
+}
+
+type fileSessionsView struct {
+    IdleWorkers int               `json:"idleWorkers"`
+    Sessions    []fileSessionView `json:"sessions"`
+}
+
+type fileSessionView struct {
+    Id             uint64 `json:"id"`
+    Address        string `json:"address"`
+    PriorityQueued int    `json:"priorityQueued"`
+    PassiveQueued  int    `json:"passiveQueued"`
+    Online         bool   `json:"online"`
+    Stale          bool   `json:"stale"`
+}
+
+// GET /file/sessions lists the file service sessions and the depths of their request queues.
+func (a *Admin) handleFileSessions(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+        return
+    }
+
+    if a.fileService == nil {
+        writeError(w, http.StatusNotImplemented, "file service is not available")
+        return
+    }
+
+    sessions := a.fileService.Sessions()
+
+    view := fileSessionsView{
+        IdleWorkers: a.fileService.IdleWorkers(),
+        Sessions:    make([]fileSessionView, 0, len(sessions)),
+    }
+
+    for _, session := range sessions {
+        view.Sessions = append(view.Sessions, fileSessionView{
+            Id:             session.Id,
+            Address:        session.Address,
+            PriorityQueued: session.PriorityQueued,
+            PassiveQueued:  session.PassiveQueued,
+            Online:         session.Online,
+            Stale:          session.Stale,
+        })
+    }
+
+    writeJSON(w, http.StatusOK, view)
+}
+
+// POST /file/reload reloads the archives from the cache directory. Clients of the file service are reconnected once
+// they have finished the archive they are being served so that they download the new release manifest.
+func (a *Admin) handleFileReload(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+        return
+    }
+
+    if a.reload == nil {
+        writeError(w, http.StatusNotImplemented, "reloading archives is not available")
+        return
+    }
+
+    if err := a.reload(); err != nil {
+        a.logger.Error("Failed to reload archives through admin API", zap.Error(err))
+        writeError(w, http.StatusInternalServerError, "failed to reload archives")
+        return
+    }
+
+    a.logger.Info("Reloaded archives through admin API")
+
+    w.WriteHeader(http.StatusNoContent)
+}
+
+type logLevelsView struct {
+    Root  zapcore.Level            `json:"root"`
+    Named map[string]zapcore.Level `json:"named"`
+}
+
+// GET /log/levels lists the level of the root logger and of every subsystem that has a level set.
+func (a *Admin) handleLogLevels(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+        return
+    }
+
+    if a.logLevels == nil {
+        writeError(w, http.StatusNotImplemented, "log levels are not available")
+        return
+    }
+
+    writeJSON(w, http.StatusOK, logLevelsView{
+        Root:  a.logLevels.Root().Level(),
+        Named: a.logLevels.Named(),
+    })
+}
+
+type logLevelView struct {
+    Level zapcore.Level `json:"level"`
+}
+
+// GET and PUT /log/levels/{name} gets or sets the level of a subsystem. The root logger is named "root". The request
+// and response bodies are of the form {"level": "debug"}. Getting the level of a subsystem that does not have a level
+// set gets the level it inherits without setting one for it.
+func (a *Admin) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+    if a.logLevels == nil {
+        writeError(w, http.StatusNotImplemented, "log levels are not available")
+        return
+    }
+
+    name := strings.TrimPrefix(r.URL.Path, "/log/levels/")
+    if name == "" {
+        writeError(w, http.StatusNotFound, "not found")
+        return
+    }
+
+    switch r.Method {
+    case http.MethodGet:
+        level := a.logLevels.Root().Level()
+        if name != "root" {
+            level = a.logLevels.Resolve(name)
+        }
+        writeJSON(w, http.StatusOK, logLevelView{Level: level})
+    case http.MethodPut:
+        a.logger.Info("Changing log level through admin API", zap.String("name", name))
+
+        if name == "root" {
+            a.logLevels.Root().ServeHTTP(w, r)
+            return
+        }
+        a.logLevels.Level(name).ServeHTTP(w, r)
+    default:
+        writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+    }
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    _ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+    writeJSON(w, status, map[string]string{"error": message})
+}
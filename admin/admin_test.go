@@ -0,0 +1,295 @@
+package admin
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "github.com/sprinkle-it/donut/game"
+    "github.com/sprinkle-it/donut/logging"
+    "github.com/sprinkle-it/donut/message"
+    "github.com/sprinkle-it/donut/server"
+    "go.uber.org/zap"
+    "go.uber.org/zap/zapcore"
+    "io"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+const testToken = "secret"
+
+// Server that records the messages broadcast to it.
+type fakeServer struct {
+    stages   []server.Stage
+    messages []message.Outbound
+    kicked   []uint64
+}
+
+func (s *fakeServer) Clients() []*server.Client { return nil }
+
+func (s *fakeServer) Kick(id uint64) bool {
+    s.kicked = append(s.kicked, id)
+    return id == 1
+}
+
+func (s *fakeServer) Broadcast(stage server.Stage, msg message.Outbound) int {
+    s.stages = append(s.stages, stage)
+    s.messages = append(s.messages, msg)
+    return 3
+}
+
+func newTestAdmin(t *testing.T, cfg Config) (*Admin, *fakeServer) {
+    srv := &fakeServer{}
+    cfg.Logger, cfg.Token, cfg.Server = zap.NewNop(), testToken, srv
+
+    admin, err := New(cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return admin, srv
+}
+
+func mustAdmin(t *testing.T, cfg Config) *Admin {
+    admin, _ := newTestAdmin(t, cfg)
+    return admin
+}
+
+func request(admin *Admin, method, path string, body io.Reader) *httptest.ResponseRecorder {
+    r := httptest.NewRequest(method, path, body)
+    r.Header.Set("Authorization", "Bearer "+testToken)
+
+    w := httptest.NewRecorder()
+    admin.ServeHTTP(w, r)
+    return w
+}
+
+func TestAdmin_Unauthorized(t *testing.T) {
+    admin, _ := newTestAdmin(t, Config{})
+
+    for _, header := range []string{"", "Bearer wrong", testToken} {
+        r := httptest.NewRequest(http.MethodGet, "/clients", nil)
+        if header != "" {
+            r.Header.Set("Authorization", header)
+        }
+
+        w := httptest.NewRecorder()
+        admin.ServeHTTP(w, r)
+
+        if w.Code != http.StatusUnauthorized {
+            t.Errorf("expected %q to be unauthorized, got %d", header, w.Code)
+        }
+    }
+}
+
+func TestAdmin_Broadcast(t *testing.T) {
+    admin, srv := newTestAdmin(t, Config{})
+
+    w := request(admin, http.MethodPost, "/broadcast", bytes.NewBufferString(`{"text": "Hello"}`))
+    if w.Code != http.StatusOK {
+        t.Fatalf("expected the broadcast to succeed, got %d", w.Code)
+    }
+
+    var reply map[string]int
+    if err := json.NewDecoder(w.Body).Decode(&reply); err != nil || reply["recipients"] != 3 {
+        t.Errorf("expected the number of recipients in the reply, got %v %v", reply, err)
+    }
+
+    // The message is broadcast to players of the game service that is running.
+    msg, ok := srv.messages[0].(*game.DisplaySystemMessage)
+    if !ok || srv.stages[0] != game.Stage || msg.Text != "Hello" {
+        t.Errorf("expected a system message to be broadcast to the game stage, got %#v to %s", srv.messages[0],
+            srv.stages[0])
+    }
+
+    w = request(admin, http.MethodPost, "/broadcast", bytes.NewBufferString(`{}`))
+    if w.Code != http.StatusBadRequest {
+        t.Errorf("expected a broadcast without text to be rejected, got %d", w.Code)
+    }
+
+    if w := request(admin, http.MethodGet, "/broadcast", nil); w.Code != http.StatusMethodNotAllowed {
+        t.Errorf("expected a broadcast to require POST, got %d", w.Code)
+    }
+}
+
+func TestAdmin_Broadcast_Game(t *testing.T) {
+    logger := zap.NewNop()
+
+    gameService, err := game.New(game.Config{Logger: logger})
+    if err != nil {
+        t.Fatal(err)
+    }
+    gameService.Process()
+
+    protocols, err := message.NewRegistry(game.Definitions...)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    srv, err := server.New(server.Config{
+        Logger:         logger,
+        ClientCapacity: 1,
+        ClientConfig:   server.NewDefaultClientConfig(),
+        Receivers:      []server.MailReceiver{gameService.MailReceiver()},
+        Protocols:      protocols,
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer listener.Close()
+
+    go func() { _ = srv.Serve(listener) }()
+
+    conn, err := net.Dial("tcp", listener.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+
+    // Log in to the game with an empty authentication message.
+    if _, err := conn.Write([]byte{16, 0, 0}); err != nil {
+        t.Fatal(err)
+    }
+
+    for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+        if clients := srv.Clients(); len(clients) == 1 && clients[0].Stage() == game.Stage {
+            break
+        }
+
+        if time.Now().After(deadline) {
+            t.Fatal("expected the client to be put in the game stage once it logged in")
+        }
+    }
+
+    admin, err := New(Config{Logger: logger, Token: testToken, Server: srv})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    w := request(admin, http.MethodPost, "/broadcast", bytes.NewBufferString(`{"text": "Hello"}`))
+
+    var reply map[string]int
+    if err := json.NewDecoder(w.Body).Decode(&reply); err != nil || reply["recipients"] != 1 {
+        t.Fatalf("expected the broadcast to reach the client, got %v %v", reply, err)
+    }
+
+    // A system message without a player it is about, framed with its identifier and length.
+    expected := []byte{3, 8, 0, 0, 'H', 'e', 'l', 'l', 'o', 0}
+
+    received := make([]byte, len(expected))
+    _ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+    if _, err := io.ReadFull(conn, received); err != nil || !bytes.Equal(received, expected) {
+        t.Errorf("expected the client to receive %x, got %x (%v)", expected, received, err)
+    }
+}
+
+func TestAdmin_Update(t *testing.T) {
+    admin, srv := newTestAdmin(t, Config{})
+
+    w := request(admin, http.MethodPost, "/update", bytes.NewBufferString(`{"ticks": 500}`))
+    if w.Code != http.StatusOK {
+        t.Fatalf("expected the update to succeed, got %d", w.Code)
+    }
+
+    msg, ok := srv.messages[0].(*game.SetSystemUpdateTimer)
+    if !ok || srv.stages[0] != game.Stage || msg.Ticks != 500 {
+        t.Errorf("expected an update timer to be broadcast to the game stage, got %#v to %s", srv.messages[0],
+            srv.stages[0])
+    }
+
+    w = request(admin, http.MethodPost, "/update", bytes.NewBufferString(`{"ticks": 0}`))
+    if w.Code != http.StatusBadRequest {
+        t.Errorf("expected an update without ticks to be rejected, got %d", w.Code)
+    }
+}
+
+func TestAdmin_Kick(t *testing.T) {
+    admin, srv := newTestAdmin(t, Config{})
+
+    tests := map[string]int{
+        "/clients/1/kick": http.StatusNoContent,
+        "/clients/2/kick": http.StatusNotFound,
+        "/clients/x/kick": http.StatusBadRequest,
+        "/clients/1":      http.StatusNotFound,
+    }
+
+    for path, code := range tests {
+        if w := request(admin, http.MethodPost, path, nil); w.Code != code {
+            t.Errorf("expected %s to reply with %d, got %d", path, code, w.Code)
+        }
+    }
+
+    if len(srv.kicked) != 2 {
+        t.Errorf("expected two clients to be kicked, got %v", srv.kicked)
+    }
+}
+
+func TestAdmin_FileReload(t *testing.T) {
+    if w := request(mustAdmin(t, Config{}), http.MethodPost, "/file/reload", nil); w.Code != http.StatusNotImplemented {
+        t.Errorf("expected reloading without a reload function to be unavailable, got %d", w.Code)
+    }
+
+    reloads := 0
+    admin := mustAdmin(t, Config{Reload: func() error {
+        reloads++
+        return nil
+    }})
+
+    if w := request(admin, http.MethodPost, "/file/reload", nil); w.Code != http.StatusNoContent || reloads != 1 {
+        t.Errorf("expected the archives to be reloaded, got %d after %d reloads", w.Code, reloads)
+    }
+
+    admin = mustAdmin(t, Config{Reload: func() error { return errors.New("missing cache") }})
+    if w := request(admin, http.MethodPost, "/file/reload", nil); w.Code != http.StatusInternalServerError {
+        t.Errorf("expected a failed reload to be reported, got %d", w.Code)
+    }
+}
+
+func TestAdmin_FileSessions(t *testing.T) {
+    admin, _ := newTestAdmin(t, Config{})
+
+    if w := request(admin, http.MethodGet, "/file/sessions", nil); w.Code != http.StatusNotImplemented {
+        t.Errorf("expected file sessions without a file service to be unavailable, got %d", w.Code)
+    }
+}
+
+func TestAdmin_LogLevels(t *testing.T) {
+    levels := logging.NewLevels(zapcore.InfoLevel)
+    levels.SetLevel("file", zapcore.DebugLevel)
+
+    admin := mustAdmin(t, Config{LogLevels: levels})
+
+    w := request(admin, http.MethodGet, "/log/levels", nil)
+
+    var view logLevelsView
+    if err := json.NewDecoder(w.Body).Decode(&view); err != nil {
+        t.Fatal(err)
+    }
+
+    if view.Root != zapcore.InfoLevel || view.Named["file"] != zapcore.DebugLevel {
+        t.Errorf("expected the root and file levels, got %+v", view)
+    }
+
+    // Getting the level of a subsystem without a level gets the level it inherits without setting one for it.
+    w = request(admin, http.MethodGet, "/log/levels/file.session", nil)
+
+    var level logLevelView
+    if err := json.NewDecoder(w.Body).Decode(&level); err != nil || level.Level != zapcore.DebugLevel {
+        t.Errorf("expected file.session to inherit the file level, got %v %v", level.Level, err)
+    }
+
+    if _, ok := levels.Named()["file.session"]; ok {
+        t.Error("expected getting the level of file.session not to set a level for it")
+    }
+
+    w = request(admin, http.MethodPut, "/log/levels/file", bytes.NewBufferString(`{"level": "warn"}`))
+    if w.Code != http.StatusOK || levels.Named()["file"] != zapcore.WarnLevel {
+        t.Errorf("expected the file level to be changed to warn, got %d %v", w.Code, levels.Named())
+    }
+}
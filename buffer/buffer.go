@@ -19,6 +19,17 @@ func IsReadable(r Readable, n int) bool {
     return r.Readable() >= n
 }
 
+// Type declaration for readable buffers whose bytes can be decoded in place instead of being copied out first.
+type Peekable interface {
+    Readable
+
+    // Gets the next n readable bytes without consuming them if they are stored contiguously, otherwise returns nil.
+    PeekContiguous(n int) []byte
+
+    // Consumes the next n readable bytes.
+    Discard(n int) error
+}
+
 type Writable interface {
     io.Writer
     Writable() int
@@ -2,6 +2,7 @@ package buffer
 
 import (
     "errors"
+    "fmt"
     "strings"
 )
 
@@ -11,23 +12,84 @@ const (
 
 var masks = []uint32{0x00, 0x01, 0x03, 0x07, 0x0f, 0x1f, 0x3f, 0x7f, 0xff,}
 
+// Error returned when writing to a growable buffer would grow it past its limit.
+var ErrLimitExceeded = errors.New("buffer: write exceeds the limit of the buffer")
+
 type ByteBuffer struct {
     Bytes     []byte
     Offset    int
     BitOffset int
+
+    // The length that the buffer is allowed to grow to when writing past the end of the bytes. Buffers without a
+    // limit do not grow.
+    Limit int
+
+    // Whether bit access is active, byte operations are rejected while it is.
+    bitAccess bool
 }
 
 func NewByteBuffer(capacity int) ByteBuffer {
     return ByteBuffer{Bytes: make([]byte, capacity)}
 }
 
+// Creates a buffer that starts with the given capacity and grows when it is written past the end, up to the limit.
+func NewGrowableByteBuffer(capacity, limit int) ByteBuffer {
+    return ByteBuffer{Bytes: make([]byte, capacity), Limit: limit}
+}
+
 func (b *ByteBuffer) check(n int) error {
+    if b.bitAccess {
+        return errors.New("buffer: cannot access bytes while bit access is active")
+    }
     if len(b.Bytes)-b.Offset < n {
         return errors.New("buffer: insufficient bytes remaining to perform this operation")
     }
     return nil
 }
 
+// Checks that n bytes can be written, growing the buffer if they cannot and the buffer is growable.
+func (b *ByteBuffer) reserve(n int) error {
+    if b.bitAccess {
+        return errors.New("buffer: cannot access bytes while bit access is active")
+    }
+    return b.grow(b.Offset + n)
+}
+
+// Grows the bytes of the buffer to at least the given length. The length of the bytes is at least doubled when the
+// buffer grows so that writing byte by byte does not reallocate on every write.
+func (b *ByteBuffer) grow(length int) error {
+    if length <= len(b.Bytes) {
+        return nil
+    }
+
+    if b.Limit == 0 {
+        return errors.New("buffer: insufficient bytes remaining to perform this operation")
+    }
+
+    if length > b.Limit {
+        return ErrLimitExceeded
+    }
+
+    grown := 2 * len(b.Bytes)
+    if grown < length {
+        grown = length
+    }
+
+    if grown > b.Limit {
+        grown = b.Limit
+    }
+
+    if grown <= cap(b.Bytes) {
+        b.Bytes = b.Bytes[:grown]
+        return nil
+    }
+
+    bytes := make([]byte, grown)
+    copy(bytes, b.Bytes)
+    b.Bytes = bytes
+    return nil
+}
+
 func (b *ByteBuffer) Skip(amount int) error {
     if err := b.check(amount); err != nil {
         return err
@@ -36,11 +98,19 @@ func (b *ByteBuffer) Skip(amount int) error {
     return nil
 }
 
-func (b *ByteBuffer) PutBytes(arr []byte) error {
+func (b *ByteBuffer) GetBytes(arr []byte) error {
     if err := b.check(len(arr)); err != nil {
         return err
     }
-    copy(b.Bytes[b.Offset:], arr)
+    b.Offset += copy(arr, b.Bytes[b.Offset:])
+    return nil
+}
+
+func (b *ByteBuffer) PutBytes(arr []byte) error {
+    if err := b.reserve(len(arr)); err != nil {
+        return err
+    }
+    b.Offset += copy(b.Bytes[b.Offset:], arr)
     return nil
 }
 
@@ -54,7 +124,7 @@ func (b *ByteBuffer) GetUint8() (uint8, error) {
 }
 
 func (b *ByteBuffer) PutUint8(v uint8) error {
-    if err := b.check(1); err != nil {
+    if err := b.reserve(1); err != nil {
         return err
     }
     b.Offset += 1
@@ -72,7 +142,7 @@ func (b *ByteBuffer) GetUint16() (uint16, error) {
 }
 
 func (b *ByteBuffer) PutUint16(v uint16) error {
-    if err := b.check(2); err != nil {
+    if err := b.reserve(2); err != nil {
         return err
     }
     b.Offset += 2
@@ -92,7 +162,7 @@ func (b *ByteBuffer) GetUint32() (uint32, error) {
 }
 
 func (b *ByteBuffer) PutUint32(v uint32) error {
-    if err := b.check(4); err != nil {
+    if err := b.reserve(4); err != nil {
         return err
     }
 
@@ -117,7 +187,7 @@ func (b *ByteBuffer) GetUint64() (uint64, error) {
 }
 
 func (b *ByteBuffer) PutUint64(v uint64) error {
-    if err := b.check(8); err != nil {
+    if err := b.reserve(8); err != nil {
         return err
     }
     b.Offset += 8
@@ -151,7 +221,7 @@ func (b *ByteBuffer) GetCString() (string, error) {
 }
 
 func (b *ByteBuffer) PutCString(v string) error {
-    if err := b.check(len(v) + 1); err != nil {
+    if err := b.reserve(len(v) + 1); err != nil {
         return err
     }
 
@@ -187,15 +257,85 @@ func (b *ByteBuffer) GetBool() (bool, error) {
     }
 }
 
-func (b *ByteBuffer) StartBitAccess() {
+// Starts bit access at the current offset. Byte operations are rejected until bit access is finished.
+func (b *ByteBuffer) StartBitAccess() error {
+    if b.bitAccess {
+        return errors.New("buffer: bit access has already been started")
+    }
+    b.bitAccess = true
     b.BitOffset = b.Offset * 8
+    return nil
 }
 
-func (b *ByteBuffer) FinishBitAccess() {
+// Finishes bit access and moves the offset to the first byte after the last bit that was accessed.
+func (b *ByteBuffer) FinishBitAccess() error {
+    if !b.bitAccess {
+        return errors.New("buffer: bit access has not been started")
+    }
+    b.bitAccess = false
     b.Offset = (b.BitOffset + 7) / 8
+    return nil
+}
+
+// Gets if bit access has been started and not yet finished.
+func (b *ByteBuffer) BitAccess() bool {
+    return b.bitAccess
+}
+
+func (b *ByteBuffer) checkBits(n int) error {
+    if !b.bitAccess {
+        return errors.New("buffer: bit access has not been started")
+    }
+
+    if n < 1 || n > 32 {
+        return fmt.Errorf("buffer: can only access between 1 and 32 bits at a time, got %d", n)
+    }
+
+    if len(b.Bytes)*8-b.BitOffset < n {
+        return errors.New("buffer: insufficient bits remaining to perform this operation")
+    }
+    return nil
+}
+
+// Checks that n bits can be written, growing the buffer if they cannot and the buffer is growable.
+func (b *ByteBuffer) reserveBits(n int) error {
+    if b.bitAccess {
+        if err := b.grow((b.BitOffset + n + 7) / 8); err != nil {
+            return err
+        }
+    }
+    return b.checkBits(n)
+}
+
+func (b *ByteBuffer) GetBits(n int) (uint32, error) {
+    if err := b.checkBits(n); err != nil {
+        return 0, err
+    }
+
+    bytePos := b.BitOffset >> 3
+    offset := 8 - (b.BitOffset & 7)
+    b.BitOffset += n
+
+    var v uint32
+    for ; n > offset; offset = 8 {
+        v |= (uint32(b.Bytes[bytePos]) & masks[offset]) << uint(n-offset)
+        bytePos++
+        n -= offset
+    }
+
+    if n == offset {
+        v |= uint32(b.Bytes[bytePos]) & masks[offset]
+    } else {
+        v |= uint32(b.Bytes[bytePos]) >> uint(offset-n) & masks[n]
+    }
+    return v, nil
 }
 
-func (b *ByteBuffer) PutBits(v uint32, n int) {
+func (b *ByteBuffer) PutBits(v uint32, n int) error {
+    if err := b.reserveBits(n); err != nil {
+        return err
+    }
+
     bytePos := b.BitOffset >> 3
     offset := 8 - (b.BitOffset & 7)
     b.BitOffset += n
@@ -214,4 +354,5 @@ func (b *ByteBuffer) PutBits(v uint32, n int) {
         b.Bytes[bytePos] &= uint8((masks[n] << uint(offset-n)) ^ 0xff)
         b.Bytes[bytePos] |= uint8((v & masks[n]) << uint(offset-n))
     }
+    return nil
 }
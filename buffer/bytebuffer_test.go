@@ -48,3 +48,115 @@ func TestByteBuffer_PutCString(t *testing.T) {
 		t.Error("value mismatch: expected %i to match %i", readResult, ExpectedStringValue)
 	}
 }
+
+func TestByteBuffer_GetBits(t *testing.T) {
+	widths := []int{1, 2, 5, 8, 11, 14, 3, 32, 7}
+	values := []uint32{1, 2, 17, 0xab, 0x5a5, 0x3fff, 5, 0xdeadbeef, 0x41}
+
+	buffer := NewByteBuffer(16)
+	if err := buffer.StartBitAccess(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, width := range widths {
+		if err := buffer.PutBits(values[i], width); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := buffer.FinishBitAccess(); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer.Offset = 0
+	if err := buffer.StartBitAccess(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, width := range widths {
+		value, err := buffer.GetBits(width)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if value != values[i] {
+			t.Errorf("value mismatch: expected %#x to match %#x", value, values[i])
+		}
+	}
+}
+
+func TestByteBuffer_BitsOutOfBounds(t *testing.T) {
+	buffer := NewByteBuffer(2)
+	if err := buffer.StartBitAccess(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := buffer.PutBits(0x7ff, 11); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := buffer.PutBits(0x3f, 6); err == nil {
+		t.Error("expected an error when writing past the end of the buffer")
+	}
+
+	if _, err := buffer.GetBits(6); err == nil {
+		t.Error("expected an error when reading past the end of the buffer")
+	}
+
+	if err := buffer.PutBits(0, 33); err == nil {
+		t.Error("expected an error when writing more than 32 bits")
+	}
+}
+
+func TestByteBuffer_BitAccessMode(t *testing.T) {
+	buffer := NewByteBuffer(4)
+
+	if err := buffer.PutBits(1, 1); err == nil {
+		t.Error("expected an error when writing bits before bit access is started")
+	}
+
+	if err := buffer.FinishBitAccess(); err == nil {
+		t.Error("expected an error when finishing bit access that was not started")
+	}
+
+	if err := buffer.StartBitAccess(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := buffer.PutUint8(1); err == nil {
+		t.Error("expected an error when writing bytes while bit access is active")
+	}
+
+	if err := buffer.PutBits(1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := buffer.FinishBitAccess(); err != nil {
+		t.Fatal(err)
+	}
+
+	if buffer.Offset != 1 {
+		t.Errorf("expected finishing bit access to round up to offset 1, got %d", buffer.Offset)
+	}
+
+	if err := buffer.PutUint8(1); err != nil {
+		t.Errorf("expected bytes to be writable after bit access has finished: %v", err)
+	}
+}
+
+func TestByteBuffer_Grow(t *testing.T) {
+	buffer := NewGrowableByteBuffer(2, 5)
+	for i := 0; i < 5; i++ {
+		if err := buffer.PutUint8(uint8(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := buffer.PutUint8(5); err != ErrLimitExceeded {
+		t.Errorf("expected the limit to be exceeded, got %v", err)
+	}
+
+	if len(buffer.Bytes) != 5 || buffer.Bytes[4] != 4 {
+		t.Errorf("expected the buffer to have grown to the limit, got %x", buffer.Bytes)
+	}
+}
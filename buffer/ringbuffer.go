@@ -3,6 +3,15 @@ package buffer
 import (
     "errors"
     "io"
+    "net"
+)
+
+var (
+    // Error returned when more bytes are peeked or discarded than are readable.
+    ErrInsufficientReadable = errors.New("buffer: insufficient bytes readable to perform this operation")
+
+    // Error returned when reading into a ring buffer that has no writable bytes.
+    ErrFull = errors.New("buffer: ring buffer is full")
 )
 
 type RingBuffer struct {
@@ -45,7 +54,7 @@ func (b *RingBuffer) Read(bytes []byte) (int, error) {
 
     // Implementations of Read are discouraged from returning a zero byte count with a nil error.
     if n == 0 {
-        return n, errors.New("")
+        return n, io.EOF
     }
 
     return n, nil
@@ -75,6 +84,102 @@ func (b *RingBuffer) Write(bytes []byte) (int, error) {
     return n, nil
 }
 
+// Gets the next n readable bytes without consuming them. The bytes are returned as two regions because they can wrap
+// around the end of the internal array, the second region is empty if they do not. The regions refer to the internal
+// array of the buffer so they are only valid until the bytes are consumed.
+func (b *RingBuffer) Peek(n int) ([]byte, []byte, error) {
+    if n < 0 || n > b.Readable() {
+        return nil, nil, ErrInsufficientReadable
+    }
+
+    if b.readPos+n > len(b.bytes) {
+        return b.bytes[b.readPos:], b.bytes[:n-len(b.bytes)+b.readPos], nil
+    }
+    return b.bytes[b.readPos : b.readPos+n], nil, nil
+}
+
+// Gets the next n readable bytes without consuming them if they are stored contiguously, otherwise returns nil. The
+// returned slice refers to the internal array of the buffer so it is only valid until the bytes are consumed.
+func (b *RingBuffer) PeekContiguous(n int) []byte {
+    first, second, err := b.Peek(n)
+    if err != nil || len(second) > 0 {
+        return nil
+    }
+    return first
+}
+
+// Consumes the next n readable bytes without copying them.
+func (b *RingBuffer) Discard(n int) error {
+    if n < 0 || n > b.Readable() {
+        return ErrInsufficientReadable
+    }
+    b.readPos = (b.readPos + n) % len(b.bytes)
+    return nil
+}
+
+// Gets the writable bytes as two regions, the second region is empty if the writable bytes do not wrap around the end
+// of the internal array.
+func (b *RingBuffer) writableRegions() ([]byte, []byte) {
+    n := b.Writable()
+    if b.writePos+n > len(b.bytes) {
+        return b.bytes[b.writePos:], b.bytes[:n-len(b.bytes)+b.writePos]
+    }
+    return b.bytes[b.writePos : b.writePos+n], nil
+}
+
+// Reads from the reader directly into the writable bytes with a single call to Read. Returns ErrFull if there are no
+// writable bytes. Unlike ReadFrom this does not wait for the reader to reach the end, which makes it suitable for
+// reading whatever is available from a connection. Readers have no vectored form of Read, so when the writable bytes
+// wrap around the end of the internal array only the region up to the end is read into and the region at the start
+// is left for the next call.
+func (b *RingBuffer) Fill(r io.Reader) (int, error) {
+    region, _ := b.writableRegions()
+    if len(region) == 0 {
+        return 0, ErrFull
+    }
+
+    n, err := r.Read(region)
+    b.writePos = (b.writePos + n) % len(b.bytes)
+    return n, err
+}
+
+// Reads from the reader directly into the writable bytes until the reader reaches the end. Returns ErrFull if the
+// buffer fills up before then.
+func (b *RingBuffer) ReadFrom(r io.Reader) (int64, error) {
+    var total int64
+    for {
+        n, err := b.Fill(r)
+        total += int64(n)
+
+        if err == io.EOF {
+            return total, nil
+        }
+
+        if err != nil {
+            return total, err
+        }
+
+        if n == 0 {
+            return total, io.ErrNoProgress
+        }
+    }
+}
+
+// Writes all of the readable bytes to the writer. Both regions of the readable bytes are handed to the writer at once
+// so that writers which support vectored I/O, such as TCP connections, write them with a single system call.
+func (b *RingBuffer) WriteTo(w io.Writer) (int64, error) {
+    first, second, _ := b.Peek(b.Readable())
+
+    buffers := net.Buffers{first}
+    if len(second) > 0 {
+        buffers = append(buffers, second)
+    }
+
+    n, err := buffers.WriteTo(w)
+    b.readPos = (b.readPos + int(n)) % len(b.bytes)
+    return n, err
+}
+
 func (b *RingBuffer) Readable() int {
     // Check if the write position has not wrapped over the boundary to be before the read position.
     if b.readPos <= b.writePos {
@@ -0,0 +1,134 @@
+package buffer
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// Creates a ring buffer whose readable bytes wrap around the end of its internal array.
+func wrappedRingBuffer(t *testing.T) RingBuffer {
+	buffer := NewRingBuffer(7)
+	if _, err := buffer.Write([]byte{0, 0, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := buffer.Discard(5); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := buffer.Write([]byte{1, 2, 3, 4, 5}); err != nil {
+		t.Fatal(err)
+	}
+	return buffer
+}
+
+func TestRingBuffer_Peek(t *testing.T) {
+	buffer := wrappedRingBuffer(t)
+
+	first, second, err := buffer.Peek(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(first, []byte{1, 2, 3}) || !bytes.Equal(second, []byte{4}) {
+		t.Errorf("expected regions 010203 and 04, got %x and %x", first, second)
+	}
+
+	if buffer.Readable() != 5 {
+		t.Errorf("expected peeking not to consume bytes, %d are readable", buffer.Readable())
+	}
+
+	if _, _, err := buffer.Peek(6); err != ErrInsufficientReadable {
+		t.Errorf("expected ErrInsufficientReadable, got %v", err)
+	}
+
+	if contiguous := buffer.PeekContiguous(4); contiguous != nil {
+		t.Errorf("expected wrapped bytes not to be contiguous, got %x", contiguous)
+	}
+}
+
+func TestRingBuffer_Discard(t *testing.T) {
+	buffer := wrappedRingBuffer(t)
+
+	if err := buffer.Discard(6); err != ErrInsufficientReadable {
+		t.Errorf("expected ErrInsufficientReadable, got %v", err)
+	}
+
+	if err := buffer.Discard(4); err != nil {
+		t.Fatal(err)
+	}
+
+	value := make([]byte, 1)
+	if _, err := buffer.Read(value); err != nil || value[0] != 5 {
+		t.Errorf("expected to read 05 after discarding, got %x (%v)", value, err)
+	}
+
+	if _, err := buffer.Read(value); err != io.EOF {
+		t.Errorf("expected io.EOF when reading an empty buffer, got %v", err)
+	}
+}
+
+func TestRingBuffer_WriteTo(t *testing.T) {
+	buffer := wrappedRingBuffer(t)
+
+	var output bytes.Buffer
+	n, err := buffer.WriteTo(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 5 || !bytes.Equal(output.Bytes(), []byte{1, 2, 3, 4, 5}) {
+		t.Errorf("expected to write 0102030405, wrote %d bytes %x", n, output.Bytes())
+	}
+
+	if HasReadable(&buffer) {
+		t.Errorf("expected all of the bytes to be consumed, %d are readable", buffer.Readable())
+	}
+}
+
+func TestRingBuffer_Fill(t *testing.T) {
+	buffer := wrappedRingBuffer(t)
+	_ = buffer.Discard(5)
+
+	// The writable bytes wrap around the end of the internal array, so each call only reads into one region.
+	reader := bytes.NewReader([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	for _, expected := range []int{6, 1} {
+		if n, err := buffer.Fill(reader); n != expected || err != nil {
+			t.Fatalf("expected to read %d bytes, read %d (%v)", expected, n, err)
+		}
+	}
+
+	if n, err := buffer.Fill(reader); n != 0 || err != ErrFull {
+		t.Errorf("expected ErrFull when the buffer is full, read %d bytes (%v)", n, err)
+	}
+
+	first, second, _ := buffer.Peek(buffer.Readable())
+	if !bytes.Equal(append(append([]byte(nil), first...), second...), []byte{1, 2, 3, 4, 5, 6, 7}) {
+		t.Errorf("expected to read 01020304050607, read %x%x", first, second)
+	}
+}
+
+func TestRingBuffer_ReadFrom(t *testing.T) {
+	buffer := wrappedRingBuffer(t)
+	_ = buffer.Discard(5)
+
+	n, err := buffer.ReadFrom(bytes.NewReader([]byte{6, 7, 8, 9}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, second, _ := buffer.Peek(buffer.Readable())
+	if n != 4 || !bytes.Equal(append(append([]byte(nil), first...), second...), []byte{6, 7, 8, 9}) {
+		t.Errorf("expected to read 06070809, read %d bytes %x%x", n, first, second)
+	}
+
+	n, err = buffer.ReadFrom(bytes.NewReader([]byte{10, 11, 12, 13}))
+	if err != ErrFull {
+		t.Errorf("expected ErrFull when reading more bytes than are writable, got %v", err)
+	}
+
+	if n != 3 || buffer.Readable() != 7 {
+		t.Errorf("expected to fill the buffer with 3 bytes, read %d and %d are readable", n, buffer.Readable())
+	}
+}
@@ -0,0 +1,225 @@
+package buffer
+
+import "errors"
+
+// The client obfuscates its protocol by transforming values when they are written and reading them back with the
+// inverse transformation. The suffixes of the functions in this file describe the transformation that is applied:
+//
+//  A   - The value has 128 added to it (the low byte for multi-byte values).
+//  C   - The value is negated.
+//  S   - The value is subtracted from 128.
+//  LE  - The bytes of the value are written in little-endian order.
+//  ME  - The bytes of the value are written in middle-endian order, 2 3 0 1 where 0 is the most significant byte.
+//  IME - The bytes of the value are written in inverse middle-endian order, 1 0 3 2 where 0 is the most significant byte.
+
+const (
+    // The largest value that can be written as a smart.
+    MaximumSmart = 0x7fff
+
+    // The largest value that can be written as an integer smart.
+    MaximumSmartInt = 0x7fffffff
+)
+
+func (b *ByteBuffer) GetUint8A() (uint8, error) {
+    v, err := b.GetUint8()
+    return v - 128, err
+}
+
+func (b *ByteBuffer) PutUint8A(v uint8) error {
+    return b.PutUint8(v + 128)
+}
+
+func (b *ByteBuffer) GetUint8C() (uint8, error) {
+    v, err := b.GetUint8()
+    return -v, err
+}
+
+func (b *ByteBuffer) PutUint8C(v uint8) error {
+    return b.PutUint8(-v)
+}
+
+func (b *ByteBuffer) GetUint8S() (uint8, error) {
+    v, err := b.GetUint8()
+    return 128 - v, err
+}
+
+func (b *ByteBuffer) PutUint8S(v uint8) error {
+    return b.PutUint8(128 - v)
+}
+
+func (b *ByteBuffer) GetUint16LE() (uint16, error) {
+    if err := b.check(2); err != nil {
+        return 0, err
+    }
+    b.Offset += 2
+    v := uint16(b.Bytes[b.Offset-1])<<8 | uint16(b.Bytes[b.Offset-2])
+    return v, nil
+}
+
+func (b *ByteBuffer) PutUint16LE(v uint16) error {
+    if err := b.reserve(2); err != nil {
+        return err
+    }
+    b.Offset += 2
+    b.Bytes[b.Offset-2] = uint8(v)
+    b.Bytes[b.Offset-1] = uint8(v >> 8)
+    return nil
+}
+
+func (b *ByteBuffer) GetUint16A() (uint16, error) {
+    if err := b.check(2); err != nil {
+        return 0, err
+    }
+    b.Offset += 2
+    v := uint16(b.Bytes[b.Offset-2])<<8 | uint16(b.Bytes[b.Offset-1]-128)
+    return v, nil
+}
+
+func (b *ByteBuffer) PutUint16A(v uint16) error {
+    if err := b.reserve(2); err != nil {
+        return err
+    }
+    b.Offset += 2
+    b.Bytes[b.Offset-2] = uint8(v >> 8)
+    b.Bytes[b.Offset-1] = uint8(v) + 128
+    return nil
+}
+
+func (b *ByteBuffer) GetUint16LEA() (uint16, error) {
+    if err := b.check(2); err != nil {
+        return 0, err
+    }
+    b.Offset += 2
+    v := uint16(b.Bytes[b.Offset-1])<<8 | uint16(b.Bytes[b.Offset-2]-128)
+    return v, nil
+}
+
+func (b *ByteBuffer) PutUint16LEA(v uint16) error {
+    if err := b.reserve(2); err != nil {
+        return err
+    }
+    b.Offset += 2
+    b.Bytes[b.Offset-2] = uint8(v) + 128
+    b.Bytes[b.Offset-1] = uint8(v >> 8)
+    return nil
+}
+
+func (b *ByteBuffer) GetUint32LE() (uint32, error) {
+    if err := b.check(4); err != nil {
+        return 0, err
+    }
+    b.Offset += 4
+    v := uint32(b.Bytes[b.Offset-1])<<24 | uint32(b.Bytes[b.Offset-2])<<16 |
+        uint32(b.Bytes[b.Offset-3])<<8 | uint32(b.Bytes[b.Offset-4])
+    return v, nil
+}
+
+func (b *ByteBuffer) PutUint32LE(v uint32) error {
+    if err := b.reserve(4); err != nil {
+        return err
+    }
+    b.Offset += 4
+    b.Bytes[b.Offset-4] = uint8(v)
+    b.Bytes[b.Offset-3] = uint8(v >> 8)
+    b.Bytes[b.Offset-2] = uint8(v >> 16)
+    b.Bytes[b.Offset-1] = uint8(v >> 24)
+    return nil
+}
+
+func (b *ByteBuffer) GetUint32ME() (uint32, error) {
+    if err := b.check(4); err != nil {
+        return 0, err
+    }
+    b.Offset += 4
+    v := uint32(b.Bytes[b.Offset-4])<<8 | uint32(b.Bytes[b.Offset-3]) |
+        uint32(b.Bytes[b.Offset-2])<<24 | uint32(b.Bytes[b.Offset-1])<<16
+    return v, nil
+}
+
+func (b *ByteBuffer) PutUint32ME(v uint32) error {
+    if err := b.reserve(4); err != nil {
+        return err
+    }
+    b.Offset += 4
+    b.Bytes[b.Offset-4] = uint8(v >> 8)
+    b.Bytes[b.Offset-3] = uint8(v)
+    b.Bytes[b.Offset-2] = uint8(v >> 24)
+    b.Bytes[b.Offset-1] = uint8(v >> 16)
+    return nil
+}
+
+func (b *ByteBuffer) GetUint32IME() (uint32, error) {
+    if err := b.check(4); err != nil {
+        return 0, err
+    }
+    b.Offset += 4
+    v := uint32(b.Bytes[b.Offset-4])<<16 | uint32(b.Bytes[b.Offset-3])<<24 |
+        uint32(b.Bytes[b.Offset-2]) | uint32(b.Bytes[b.Offset-1])<<8
+    return v, nil
+}
+
+func (b *ByteBuffer) PutUint32IME(v uint32) error {
+    if err := b.reserve(4); err != nil {
+        return err
+    }
+    b.Offset += 4
+    b.Bytes[b.Offset-4] = uint8(v >> 16)
+    b.Bytes[b.Offset-3] = uint8(v >> 24)
+    b.Bytes[b.Offset-2] = uint8(v)
+    b.Bytes[b.Offset-1] = uint8(v >> 8)
+    return nil
+}
+
+// Gets a smart. Smarts are written as a single byte if the value is less than 128, otherwise they are written as a
+// short with the most significant bit set.
+func (b *ByteBuffer) GetSmart() (uint16, error) {
+    if err := b.check(1); err != nil {
+        return 0, err
+    }
+
+    if b.Bytes[b.Offset] < 128 {
+        v, err := b.GetUint8()
+        return uint16(v), err
+    }
+
+    v, err := b.GetUint16()
+    return v - 0x8000, err
+}
+
+func (b *ByteBuffer) PutSmart(v uint16) error {
+    switch {
+    case v < 128:
+        return b.PutUint8(uint8(v))
+    case v <= MaximumSmart:
+        return b.PutUint16(v + 0x8000)
+    default:
+        return errors.New("buffer: value is too large to be written as a smart")
+    }
+}
+
+// Gets an integer smart. Integer smarts are written as a short if the value is less than 32768, otherwise they are
+// written as an int with the most significant bit set.
+func (b *ByteBuffer) GetSmartInt() (uint32, error) {
+    if err := b.check(1); err != nil {
+        return 0, err
+    }
+
+    if b.Bytes[b.Offset] < 128 {
+        v, err := b.GetUint16()
+        return uint32(v), err
+    }
+
+    v, err := b.GetUint32()
+    return v & MaximumSmartInt, err
+}
+
+func (b *ByteBuffer) PutSmartInt(v uint32) error {
+    switch {
+    case v <= MaximumSmart:
+        return b.PutUint16(uint16(v))
+    case v <= MaximumSmartInt:
+        return b.PutUint32(v | 0x80000000)
+    default:
+        return errors.New("buffer: value is too large to be written as an integer smart")
+    }
+}
@@ -0,0 +1,195 @@
+package buffer
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestByteBuffer_Transformations(t *testing.T) {
+	tests := []struct {
+		name     string
+		put      func(b *ByteBuffer) error
+		get      func(b *ByteBuffer) (uint32, error)
+		value    uint32
+		expected []byte
+	}{
+		{
+			name:     "Uint8A",
+			put:      func(b *ByteBuffer) error { return b.PutUint8A(0x12) },
+			get:      func(b *ByteBuffer) (uint32, error) { v, err := b.GetUint8A(); return uint32(v), err },
+			value:    0x12,
+			expected: []byte{0x92},
+		},
+		{
+			name:     "Uint8C",
+			put:      func(b *ByteBuffer) error { return b.PutUint8C(0x12) },
+			get:      func(b *ByteBuffer) (uint32, error) { v, err := b.GetUint8C(); return uint32(v), err },
+			value:    0x12,
+			expected: []byte{0xee},
+		},
+		{
+			name:     "Uint8S",
+			put:      func(b *ByteBuffer) error { return b.PutUint8S(0x12) },
+			get:      func(b *ByteBuffer) (uint32, error) { v, err := b.GetUint8S(); return uint32(v), err },
+			value:    0x12,
+			expected: []byte{0x6e},
+		},
+		{
+			name:     "Uint16LE",
+			put:      func(b *ByteBuffer) error { return b.PutUint16LE(0x1234) },
+			get:      func(b *ByteBuffer) (uint32, error) { v, err := b.GetUint16LE(); return uint32(v), err },
+			value:    0x1234,
+			expected: []byte{0x34, 0x12},
+		},
+		{
+			name:     "Uint16A",
+			put:      func(b *ByteBuffer) error { return b.PutUint16A(0x1234) },
+			get:      func(b *ByteBuffer) (uint32, error) { v, err := b.GetUint16A(); return uint32(v), err },
+			value:    0x1234,
+			expected: []byte{0x12, 0xb4},
+		},
+		{
+			name:     "Uint16LEA",
+			put:      func(b *ByteBuffer) error { return b.PutUint16LEA(0x1234) },
+			get:      func(b *ByteBuffer) (uint32, error) { v, err := b.GetUint16LEA(); return uint32(v), err },
+			value:    0x1234,
+			expected: []byte{0xb4, 0x12},
+		},
+		{
+			name:     "Uint32LE",
+			put:      func(b *ByteBuffer) error { return b.PutUint32LE(0x12345678) },
+			get:      func(b *ByteBuffer) (uint32, error) { return b.GetUint32LE() },
+			value:    0x12345678,
+			expected: []byte{0x78, 0x56, 0x34, 0x12},
+		},
+		{
+			name:     "Uint32ME",
+			put:      func(b *ByteBuffer) error { return b.PutUint32ME(0x12345678) },
+			get:      func(b *ByteBuffer) (uint32, error) { return b.GetUint32ME() },
+			value:    0x12345678,
+			expected: []byte{0x56, 0x78, 0x12, 0x34},
+		},
+		{
+			name:     "Uint32IME",
+			put:      func(b *ByteBuffer) error { return b.PutUint32IME(0x12345678) },
+			get:      func(b *ByteBuffer) (uint32, error) { return b.GetUint32IME() },
+			value:    0x12345678,
+			expected: []byte{0x34, 0x12, 0x78, 0x56},
+		},
+		{
+			name:     "SmallSmart",
+			put:      func(b *ByteBuffer) error { return b.PutSmart(0x7f) },
+			get:      func(b *ByteBuffer) (uint32, error) { v, err := b.GetSmart(); return uint32(v), err },
+			value:    0x7f,
+			expected: []byte{0x7f},
+		},
+		{
+			name:     "LargeSmart",
+			put:      func(b *ByteBuffer) error { return b.PutSmart(0x80) },
+			get:      func(b *ByteBuffer) (uint32, error) { v, err := b.GetSmart(); return uint32(v), err },
+			value:    0x80,
+			expected: []byte{0x80, 0x80},
+		},
+		{
+			name:     "SmallSmartInt",
+			put:      func(b *ByteBuffer) error { return b.PutSmartInt(0x7fff) },
+			get:      func(b *ByteBuffer) (uint32, error) { return b.GetSmartInt() },
+			value:    0x7fff,
+			expected: []byte{0x7f, 0xff},
+		},
+		{
+			name:     "LargeSmartInt",
+			put:      func(b *ByteBuffer) error { return b.PutSmartInt(0x8000) },
+			get:      func(b *ByteBuffer) (uint32, error) { return b.GetSmartInt() },
+			value:    0x8000,
+			expected: []byte{0x80, 0x00, 0x80, 0x00},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := NewByteBuffer(len(test.expected))
+			if err := test.put(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			if buf.Offset != len(test.expected) {
+				t.Fatalf("expected to write %d bytes, wrote %d", len(test.expected), buf.Offset)
+			}
+
+			if !bytes.Equal(buf.Bytes, test.expected) {
+				t.Fatalf("expected bytes %x, got %x", test.expected, buf.Bytes)
+			}
+
+			buf.Offset = 0
+
+			value, err := test.get(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value != test.value {
+				t.Fatalf("expected value %#x, got %#x", test.value, value)
+			}
+
+			if buf.Offset != len(test.expected) {
+				t.Fatalf("expected to read %d bytes, read %d", len(test.expected), buf.Offset)
+			}
+		})
+	}
+}
+
+func TestByteBuffer_TransformationsOverflow(t *testing.T) {
+	buf := NewByteBuffer(3)
+	buf.Offset = 1
+
+	if err := buf.PutUint32IME(0); err == nil {
+		t.Fatal("expected an error when writing past the end of the buffer")
+	}
+
+	if _, err := buf.GetUint32ME(); err == nil {
+		t.Fatal("expected an error when reading past the end of the buffer")
+	}
+
+	if buf.Offset != 1 {
+		t.Fatalf("expected the offset to be unchanged, got %d", buf.Offset)
+	}
+}
+
+func TestByteBuffer_SmartTooLarge(t *testing.T) {
+	buf := NewByteBuffer(4)
+
+	if err := buf.PutSmart(MaximumSmart + 1); err == nil {
+		t.Fatal("expected an error when writing a smart that is too large")
+	}
+
+	if err := buf.PutSmartInt(MaximumSmartInt + 1); err == nil {
+		t.Fatal("expected an error when writing an integer smart that is too large")
+	}
+}
+
+func TestByteBuffer_PutBytes(t *testing.T) {
+	buf := NewByteBuffer(4)
+	if err := buf.PutBytes([]byte{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := buf.PutBytes([]byte{3, 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes, []byte{1, 2, 3, 4}) {
+		t.Fatalf("expected bytes 01020304, got %x", buf.Bytes)
+	}
+
+	buf.Offset = 0
+
+	arr := make([]byte, 4)
+	if err := buf.GetBytes(arr); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(arr, buf.Bytes) || buf.Offset != 4 {
+		t.Fatalf("expected to read all of the bytes, got %x with offset %d", arr, buf.Offset)
+	}
+}
@@ -0,0 +1,52 @@
+// Command cacheverify checks the integrity of a cache so that corrupt or missing archives are found before clients
+// request them and are disconnected:
+//
+//  cacheverify -cache ./cache
+//
+// Every problem is printed and the command exits with a non-zero status if any were found.
+package main
+
+import (
+    "flag"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+)
+
+func main() {
+    cachePath := flag.String("cache", "cache", "path to the directory containing main_file_cache.dat2 and its idx files")
+    encryptedIndices := flag.String("encrypted", "5", "comma separated list of indices whose archives are encrypted "+
+        "and cannot be decompressed")
+    flag.Parse()
+
+    encrypted := make(map[uint8]bool)
+    for _, field := range strings.Split(*encryptedIndices, ",") {
+        if field = strings.TrimSpace(field); field == "" {
+            continue
+        }
+
+        index, err := strconv.ParseUint(field, 10, 8)
+        if err != nil {
+            log.Fatalf("cacheverify: invalid encrypted index %q", field)
+        }
+        encrypted[uint8(index)] = true
+    }
+
+    report, err := Verify(*cachePath, encrypted)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    for _, problem := range report.Problems {
+        fmt.Println(problem)
+    }
+
+    fmt.Printf("Verified %d archives, %d encrypted archives were not decompressed, %d problems\n",
+        report.Archives, report.Encrypted, len(report.Problems))
+
+    if len(report.Problems) > 0 {
+        os.Exit(1)
+    }
+}
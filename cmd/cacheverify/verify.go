@@ -0,0 +1,212 @@
+package main
+
+import (
+    "encoding/binary"
+    "fmt"
+    "github.com/sprinkle-it/coffee"
+    "hash/crc32"
+    "os"
+    "path/filepath"
+    "sort"
+)
+
+// The length of the reference of each archive in an index file.
+const referenceLength = 6
+
+// A problem with an archive of a cache.
+type Problem struct {
+    Index uint8
+    Id    uint16
+
+    // Whether the archive is missing from the cache, rather than corrupt.
+    Missing bool
+    Err     error
+}
+
+func (p Problem) String() string {
+    kind := "corrupt"
+    if p.Missing {
+        kind = "missing"
+    }
+    return fmt.Sprintf("%d:%d %s: %v", p.Index, p.Id, kind, p.Err)
+}
+
+// The outcome of verifying a cache.
+type Report struct {
+    // The number of archives that were verified, including the manifests.
+    Archives int
+
+    // The number of archives whose checksums were verified but which could not be decompressed because they are
+    // encrypted.
+    Encrypted int
+
+    Problems []Problem
+}
+
+// Verifies every archive of the cache in a directory. The block chain of each archive is checked as it is read, then
+// its checksum and version are checked against its manifest and it is decompressed. The manifest of each index is
+// checked against the release manifest in the same way. Archives of the encrypted indices cannot be decompressed
+// without their keys so only their checksums and versions are checked.
+func Verify(root string, encrypted map[uint8]bool) (*Report, error) {
+    cache, err := coffee.OpenCache(root)
+    if err != nil {
+        return nil, err
+    }
+
+    report := &Report{}
+
+    problem := func(index uint8, id uint16, err error) {
+        report.Problems = append(report.Problems, Problem{Index: index, Id: id, Missing: missing(root, index, id),
+            Err: err})
+    }
+
+    release, err := createReleaseManifest(cache)
+    if err != nil {
+        // The release manifest is created rather than stored in the cache, so it is never missing.
+        report.Problems = append(report.Problems, Problem{Index: coffee.ManifestPackage, Id: coffee.ManifestPackage,
+            Err: err})
+    }
+
+    indices := append([]uint8(nil), cache.PackageIds()...)
+    sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+    for _, index := range indices {
+        report.Archives++
+
+        var checksum, version uint32
+        if entry := int(index) * 8; release != nil && entry+8 <= len(release) {
+            checksum, version = binary.BigEndian.Uint32(release[entry:]), binary.BigEndian.Uint32(release[entry+4:])
+        }
+
+        b, err := verifyArchive(cache, coffee.ManifestPackage, uint16(index), release != nil, checksum, 0)
+        if err != nil {
+            problem(coffee.ManifestPackage, uint16(index), err)
+            continue
+        }
+
+        manifest, err := coffee.DecodeManifest(b)
+        if err != nil {
+            problem(coffee.ManifestPackage, uint16(index), err)
+            continue
+        }
+
+        if release != nil && manifest.Version != version {
+            problem(coffee.ManifestPackage, uint16(index), fmt.Errorf("manifest version %d does not match the "+
+                "release manifest version %d", manifest.Version, version))
+        }
+
+        ids := make([]uint16, 0, len(manifest.Groups))
+        for id := range manifest.Groups {
+            ids = append(ids, id)
+        }
+        sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+        for _, id := range ids {
+            report.Archives++
+
+            group := manifest.Groups[id]
+
+            if encrypted[index] {
+                _, err = readArchive(cache, index, id, true, group.Checksum, uint16(group.Version))
+                if err == nil {
+                    report.Encrypted++
+                }
+            } else {
+                _, err = verifyArchive(cache, index, id, true, group.Checksum, uint16(group.Version))
+            }
+
+            if err != nil {
+                problem(index, id, err)
+            }
+        }
+    }
+    return report, nil
+}
+
+// Creates the release manifest that would be served for the cache. Without the release manifest the manifests of the
+// indices are still verified, but not against the release manifest.
+func createReleaseManifest(cache *coffee.Cache) (release []byte, err error) {
+    // Corrupt manifests can cause them to be sliced out of bounds when they are decompressed.
+    defer func() {
+        if r := recover(); r != nil {
+            release, err = nil, fmt.Errorf("failed to create release manifest: %v", r)
+        }
+    }()
+    return coffee.CreateReleaseManifest(cache)
+}
+
+// Reads an archive and checks its checksum and, if the archive has a version footer, its version. The archive is
+// returned without its footer.
+func readArchive(cache *coffee.Cache, index uint8, id uint16, check bool, checksum uint32, version uint16) (
+    archive []byte, err error) {
+
+    // Malformed headers can cause the archive to be sliced out of bounds.
+    defer func() {
+        if r := recover(); r != nil {
+            archive, err = nil, fmt.Errorf("malformed archive: %v", r)
+        }
+    }()
+
+    b, err := cache.Get(index, id)
+    if err != nil {
+        return nil, err
+    }
+
+    if len(b) < 5 {
+        return nil, fmt.Errorf("archive of %d bytes is too short to have a header", len(b))
+    }
+
+    archive, err = coffee.TrimArchive(b)
+    if err != nil {
+        return nil, err
+    }
+
+    if !check {
+        return archive, nil
+    }
+
+    if crc := crc32.ChecksumIEEE(archive); crc != checksum {
+        return nil, fmt.Errorf("checksum %08x does not match the expected checksum %08x", crc, checksum)
+    }
+
+    // Archives of the manifest index do not have versions.
+    if footer := b[len(archive):]; len(footer) >= 2 && index != coffee.ManifestPackage {
+        if v := binary.BigEndian.Uint16(footer); v != version {
+            return nil, fmt.Errorf("version %d does not match the manifest version %d", v, version)
+        }
+    }
+    return archive, nil
+}
+
+// Reads and checks an archive like readArchive and decompresses it, returning the decompressed archive.
+func verifyArchive(cache *coffee.Cache, index uint8, id uint16, check bool, checksum uint32, version uint16) (
+    b []byte, err error) {
+
+    archive, err := readArchive(cache, index, id, check, checksum, version)
+    if err != nil {
+        return nil, err
+    }
+
+    defer func() {
+        if r := recover(); r != nil {
+            b, err = nil, fmt.Errorf("malformed archive: %v", r)
+        }
+    }()
+
+    b, err = coffee.DecompressArchive(archive)
+    if err != nil {
+        return nil, fmt.Errorf("failed to decompress: %v", err)
+    }
+    return b, nil
+}
+
+// Checks if an archive is not in the cache, which is the case when its index file does not exist or its reference is
+// past the end of the index file, the same check that coffee.Cache.Get makes. Archives whose references point to
+// blocks that are out of range or past the end of a truncated data file are corrupt rather than missing.
+func missing(root string, index uint8, id uint16) bool {
+    info, err := os.Stat(filepath.Join(root, fmt.Sprintf("main_file_cache.idx%d", index)))
+    if err != nil {
+        return true
+    }
+    return info.Size() < (int64(id)+1)*referenceLength
+}
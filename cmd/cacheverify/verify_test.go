@@ -0,0 +1,234 @@
+package main
+
+import (
+    "encoding/binary"
+    "fmt"
+    "github.com/sprinkle-it/coffee"
+    "hash/crc32"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// An archive as it is stored in a cache, with its version footer.
+type testArchive struct {
+    index uint8
+    id    uint16
+    data  []byte
+}
+
+// Packs bytes as an uncompressed archive with a version footer.
+func packArchive(b []byte, version uint16) []byte {
+    packed, _ := coffee.CompressArchive(coffee.Uncompressed, b)
+    return append(packed, uint8(version>>8), uint8(version))
+}
+
+// Creates a manifest of format 6 for archives with the given checksums, each archive is given version 7.
+func createManifest(checksums map[uint16]uint32) []byte {
+    ids := make([]uint16, 0, len(checksums))
+    for id := uint16(0); len(ids) < len(checksums); id++ {
+        if _, ok := checksums[id]; ok {
+            ids = append(ids, id)
+        }
+    }
+
+    b := []byte{6, 0, 0, 0, 3, 0, uint8(len(ids) >> 8), uint8(len(ids))}
+
+    previous := uint16(0)
+    for _, id := range ids {
+        b = append(b, uint8((id-previous)>>8), uint8(id-previous))
+        previous = id
+    }
+
+    for _, id := range ids {
+        b = append(b, uint8(checksums[id]>>24), uint8(checksums[id]>>16), uint8(checksums[id]>>8), uint8(checksums[id]))
+    }
+
+    for range ids {
+        b = append(b, 0, 0, 0, 7)
+    }
+
+    for range ids {
+        b = append(b, 0, 1)
+    }
+
+    for range ids {
+        b = append(b, 0, 0)
+    }
+    return b
+}
+
+// Writes archives into a cache of block chains in a temporary directory.
+func writeCache(t *testing.T, archives []testArchive) string {
+    root, err := ioutil.TempDir("", "donut-cacheverify")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { _ = os.RemoveAll(root) })
+
+    // Block zero is never used, so the first archive starts at block one.
+    blocks := make([]byte, 520)
+    indices := make(map[uint8][]byte)
+
+    for _, archive := range archives {
+        first := len(blocks) / 520
+
+        for part, offset := 0, 0; offset < len(archive.data); part, offset = part+1, offset+512 {
+            end := offset + 512
+            if end > len(archive.data) {
+                end = len(archive.data)
+            }
+
+            next := 0
+            if end < len(archive.data) {
+                next = len(blocks)/520 + 1
+            }
+
+            block := make([]byte, 520)
+            binary.BigEndian.PutUint16(block, archive.id)
+            binary.BigEndian.PutUint16(block[2:], uint16(part))
+            block[4], block[5], block[6], block[7] = uint8(next>>16), uint8(next>>8), uint8(next), archive.index
+            copy(block[8:], archive.data[offset:end])
+            blocks = append(blocks, block...)
+        }
+
+        index := indices[archive.index]
+        for len(index) < int(archive.id+1)*6 {
+            index = append(index, 0)
+        }
+
+        reference := index[int(archive.id)*6:]
+        length := len(archive.data)
+        reference[0], reference[1], reference[2] = uint8(length>>16), uint8(length>>8), uint8(length)
+        reference[3], reference[4], reference[5] = uint8(first>>16), uint8(first>>8), uint8(first)
+        indices[archive.index] = index
+    }
+
+    if err := ioutil.WriteFile(filepath.Join(root, "main_file_cache.dat2"), blocks, 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    for index, b := range indices {
+        name := fmt.Sprintf("main_file_cache.idx%d", index)
+        if err := ioutil.WriteFile(filepath.Join(root, name), b, 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return root
+}
+
+// Writes a cache with a single index 0 of archives 0 to 2, where archive 2 is large enough to span multiple blocks,
+// returning the directory that it was written to. The archives of the cache are modified by the given function before
+// their manifest is created.
+func writeTestCache(t *testing.T, modify func(archives map[uint16][]byte, checksums map[uint16]uint32)) string {
+    archives := map[uint16][]byte{
+        0: packArchive([]byte("first"), 7),
+        1: packArchive([]byte("second"), 7),
+        2: packArchive(make([]byte, 2000), 7),
+    }
+
+    checksums := make(map[uint16]uint32)
+    for id, archive := range archives {
+        checksums[id] = crc32.ChecksumIEEE(archive[:len(archive)-2])
+    }
+
+    if modify != nil {
+        modify(archives, checksums)
+    }
+
+    manifest, _ := coffee.CompressArchive(coffee.Uncompressed, createManifest(checksums))
+    stored := []testArchive{{index: coffee.ManifestPackage, id: 0, data: manifest}}
+    for id := uint16(0); id < 3; id++ {
+        if archive, ok := archives[id]; ok {
+            stored = append(stored, testArchive{index: 0, id: id, data: archive})
+        }
+    }
+
+    return writeCache(t, stored)
+}
+
+// Verifies the cache in a directory, failing the test if the cache cannot be opened.
+func verify(t *testing.T, root string, encrypted map[uint8]bool) *Report {
+    report, err := Verify(root, encrypted)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return report
+}
+
+func TestVerify(t *testing.T) {
+    report := verify(t, writeTestCache(t, nil), nil)
+
+    if len(report.Problems) != 0 {
+        t.Errorf("expected no problems, got %v", report.Problems)
+    }
+
+    if report.Archives != 4 {
+        t.Errorf("expected the manifest and 3 archives to be verified, got %d", report.Archives)
+    }
+}
+
+func TestVerify_Problems(t *testing.T) {
+    root := writeTestCache(t, func(archives map[uint16][]byte, checksums map[uint16]uint32) {
+        // Archive 0 is corrupted after its checksum was taken, archive 1 has a stale version and archive 2 is
+        // missing from the cache.
+        archives[0][6] ^= 0xff
+        archives[1] = packArchive([]byte("second"), 6)
+        delete(archives, 2)
+    })
+
+    report := verify(t, root, nil)
+    if len(report.Problems) != 3 {
+        t.Fatalf("expected 3 problems, got %v", report.Problems)
+    }
+
+    for i, problem := range report.Problems {
+        if problem.Index != 0 || problem.Id != uint16(i) {
+            t.Errorf("expected a problem with archive 0:%d, got %v", i, problem)
+        }
+    }
+
+    if !report.Problems[2].Missing {
+        t.Errorf("expected archive 0:2 to be missing, got %v", report.Problems[2])
+    }
+}
+
+func TestVerify_Truncated(t *testing.T) {
+    root := writeTestCache(t, nil)
+
+    // The blocks of archive 2 are cut off, its reference is in the index but points past the end of the data file.
+    path := filepath.Join(root, "main_file_cache.dat2")
+    info, err := os.Stat(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if err := os.Truncate(path, info.Size()-4*520); err != nil {
+        t.Fatal(err)
+    }
+
+    report := verify(t, root, nil)
+    if len(report.Problems) != 1 || report.Problems[0].Id != 2 || report.Problems[0].Missing {
+        t.Errorf("expected archive 0:2 to be corrupt rather than missing, got %v", report.Problems)
+    }
+}
+
+func TestVerify_Encrypted(t *testing.T) {
+    root := writeTestCache(t, func(archives map[uint16][]byte, checksums map[uint16]uint32) {
+        // An encrypted payload that claims to be gzipped, which cannot be decompressed without its key.
+        archive := []byte{uint8(coffee.Gzip), 0, 0, 0, 4, 0, 0, 0, 4, 1, 2, 3, 4}
+        checksums[1] = crc32.ChecksumIEEE(archive)
+        archives[1] = append(archive, 0, 7)
+    })
+
+    if report := verify(t, root, nil); len(report.Problems) != 1 {
+        t.Errorf("expected the encrypted archive to fail to decompress, got %v", report.Problems)
+    }
+
+    report := verify(t, root, map[uint8]bool{0: true})
+    if len(report.Problems) != 0 || report.Encrypted != 3 {
+        t.Errorf("expected the encrypted index to only be checksummed, got %v and %d encrypted",
+            report.Problems, report.Encrypted)
+    }
+}
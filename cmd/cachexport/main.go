@@ -0,0 +1,49 @@
+// Command cachexport exports the archives of a cache into a directory tree or a pack file that the file service can
+// serve from:
+//
+//  cachexport -cache ./cache -format directory -out ./archives
+//  cachexport -cache ./cache -format pack -out ./cache.pack
+package main
+
+import (
+    "flag"
+    "github.com/sprinkle-it/coffee"
+    "github.com/sprinkle-it/donut/file"
+    "log"
+)
+
+func main() {
+    cachePath := flag.String("cache", "cache", "path to the directory containing main_file_cache.dat2 and its idx files")
+    format := flag.String("format", "directory", "format to export to, either directory or pack")
+    out := flag.String("out", "", "path to write the directory tree or pack file to")
+    flag.Parse()
+
+    if *out == "" {
+        log.Fatal("cachexport: an output path is required")
+    }
+
+    cache, err := coffee.OpenCache(*cachePath)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    storage, err := coffee.NewStorage(cache)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    provider := file.CacheProvider(cache, storage)
+
+    switch *format {
+    case "directory":
+        err = file.WriteDirectory(*out, provider)
+    case "pack":
+        err = file.WritePackFile(*out, provider)
+    default:
+        log.Fatalf("cachexport: format must be directory or pack, got %q", *format)
+    }
+
+    if err != nil {
+        log.Fatal(err)
+    }
+}
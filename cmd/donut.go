@@ -1,74 +1,227 @@
 package main
 
 import (
+    "flag"
     "github.com/sprinkle-it/coffee"
+    "github.com/sprinkle-it/donut/admin"
+    "github.com/sprinkle-it/donut/config"
     "github.com/sprinkle-it/donut/file"
     "github.com/sprinkle-it/donut/game"
+    "github.com/sprinkle-it/donut/message"
+    "github.com/sprinkle-it/donut/metrics"
     "github.com/sprinkle-it/donut/server"
     "go.uber.org/zap"
-    "go.uber.org/zap/zapcore"
     "log"
+    "sync"
 )
 
 func main() {
-    loggerConfig := zap.NewDevelopmentConfig()
-    loggerConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-    loggerConfig.DisableCaller = true
+    configPath := flag.String("config", "", "path to the configuration file, the defaults are used if empty")
+    flag.Parse()
 
-    cache, err := coffee.OpenCache("cache")
+    cfg, err := config.Load(*configPath)
     if err != nil {
-        log.Fatal("Failed to open cache: ", err)
+        log.Fatal("Failed to load configuration: ", err)
     }
 
-    storage, err := coffee.NewStorage(cache)
+    if cfg.Metrics.Address != "" {
+        go func() {
+            if err := metrics.Serve(cfg.Metrics.Address); err != nil {
+                log.Fatal("Failed to serve metrics: ", err)
+            }
+        }()
+    }
+
+    logLevels := cfg.LogLevels()
+
+    logger, err := cfg.Logger(logLevels)
+    if err != nil {
+        log.Fatal("Failed to create logger: ", err)
+    }
+
+    // The protocols of every supported client revision, built from the message definitions of each service.
+    protocols, err := message.NewRegistry(append(file.Definitions, game.Definitions...)...)
     if err != nil {
-        log.Fatal("Failed to create storage: ", err)
+        log.Fatal("Failed to create protocol registry: ", err)
     }
 
-    fileService, err := file.New(file.Config{
-        LoggerConfig:     loggerConfig,
-        Capacity:         1000,
-        SupportedVersion: 177,
-        ArchiveProvider:  storage.GetArchive,
-        Workers:          2,
-        SessionConfig: file.SessionConfig{
-            PriorityRequestCapacity: 200,
-            PassiveRequestCapacity:  200,
-        },
-    })
+    archives, releaseArchives, err := openArchives(cfg)
+    if err != nil {
+        log.Fatal("Failed to open archives: ", err)
+    }
 
+    fileConfig := cfg.FileConfig()
+    fileConfig.Logger = logger
+    fileConfig.ArchiveProvider = archives
+    fileConfig.ReleaseArchives = releaseArchives
+    fileConfig.Protocols = protocols
+
+    fileService, err := file.New(fileConfig)
     if err != nil {
         log.Fatal("Failed to create file service: ", err)
     }
 
     fileService.Process()
 
-    gameService, err := game.New(game.Config{
-        LoggerConfig:     loggerConfig,
-        SupportedVersion: 177,
-    })
+    // Reloads the archives from the cache directory, reloads are serialized so that a slow reload cannot replace the
+    // archives of a later one.
+    var reloadMutex sync.Mutex
+    reloadArchives := func() error {
+        reloadMutex.Lock()
+        defer reloadMutex.Unlock()
+
+        archives, release, err := openArchives(cfg)
+        if err != nil {
+            return err
+        }
 
+        fileService.Reload(archives, release)
+        return nil
+    }
+
+    if cfg.File.CacheWatch > 0 {
+        // The cache is watched at its own path, so for pack files only the pack file is watched rather than the
+        // directory that holds it and changes to other files next to it do not reload the archives.
+        go file.Watch(cfg.File.Cache, cfg.File.CacheWatch, nil, func() {
+            if err := reloadArchives(); err != nil {
+                logger.Error("Failed to reload archives after the cache changed", zap.Error(err))
+            }
+        })
+    }
+
+    if cfg.File.HTTP.Address != "" {
+        httpConfig := cfg.FileHTTPConfig()
+        httpConfig.Logger = logger
+        httpConfig.Service = fileService
+
+        httpServer, err := file.NewHTTPServer(httpConfig)
+        if err != nil {
+            log.Fatal("Failed to create file HTTP server: ", err)
+        }
+
+        go func() {
+            if err := httpServer.Listen(); err != nil {
+                log.Fatal("Failed to serve archives over HTTP: ", err)
+            }
+        }()
+    }
+
+    gameConfig := cfg.GameConfig()
+    gameConfig.Logger = logger
+
+    gameService, err := game.New(gameConfig)
     if err != nil {
         log.Fatal("Failed to create game service: ", err)
     }
 
     gameService.Process()
 
-    srv, err := server.New(server.Config{
-        LoggerConfig:   loggerConfig,
-        ClientCapacity: 2000,
-        ClientConfig:   server.NewDefaultClientConfig(),
-        Receivers: []server.MailReceiver{
-            fileService.MailReceiver(),
-            gameService.MailReceiver(),
-        },
-    })
+    serverConfig := cfg.ServerConfig()
+    serverConfig.Logger = logger
+    serverConfig.Protocols = protocols
+    serverConfig.Receivers = []server.MailReceiver{
+        fileService.MailReceiver(),
+        gameService.MailReceiver(),
+    }
 
+    srv, err := server.New(serverConfig)
     if err != nil {
         log.Fatal("Failed to create server: ", err)
     }
 
-    if err := srv.Listen(43594); err != nil {
+    if cfg.Admin.Address != "" {
+        adm, err := admin.New(admin.Config{
+            Logger:      logger,
+            Address:     cfg.Admin.Address,
+            Token:       cfg.Admin.Token,
+            Server:      srv,
+            FileService: fileService,
+            LogLevels:   logLevels,
+            Reload:      reloadArchives,
+        })
+
+        if err != nil {
+            log.Fatal("Failed to create admin API: ", err)
+        }
+
+        go func() {
+            if err := adm.Listen(); err != nil {
+                log.Fatal("Failed to serve admin API: ", err)
+            }
+        }()
+    }
+
+    if err := srv.Listen(cfg.Server.Port); err != nil {
         log.Fatal("Failed to listen to server port: ", err)
     }
 }
+
+// Opens the archives of the cache directory, returning the provider that serves them and a function that releases the
+// memory and files that they hold. The release function must only be called once nothing is served from them anymore.
+func openArchives(cfg config.Config) (file.ArchiveProvider, func(), error) {
+    // The functions that release what has been opened so far, called in reverse order.
+    var releases []func()
+    release := func() {
+        for i := len(releases) - 1; i >= 0; i-- {
+            releases[i]()
+        }
+    }
+
+    var provider file.ArchiveProvider
+    switch cfg.File.Format {
+    case config.FormatDirectory:
+        directory, err := file.OpenDirectory(cfg.File.Cache)
+        if err != nil {
+            return nil, nil, err
+        }
+        provider = directory.GetArchive
+    case config.FormatPack:
+        // Archives are served straight from the mapping of the pack file, so it is only unmapped once released.
+        pack, err := file.OpenPackFile(cfg.File.Cache)
+        if err != nil {
+            return nil, nil, err
+        }
+        releases = append(releases, func() { _ = pack.Close() })
+        provider = pack.GetArchive
+    default:
+        cache, err := file.OpenDiskCache(cfg.File.Cache)
+        if err != nil {
+            return nil, nil, err
+        }
+        releases = append(releases, func() { _ = cache.Close() })
+        provider = cache.GetArchive
+    }
+
+    if compression := cfg.FileCompression(); compression != coffee.Uncompressed {
+        compressor, err := file.NewCompressor(provider, compression)
+        if err != nil {
+            release()
+            return nil, nil, err
+        }
+
+        if err := compressor.Precompress(); err != nil {
+            release()
+            return nil, nil, err
+        }
+        provider = compressor.GetArchive
+    }
+
+    if cfg.File.CacheBudget <= 0 {
+        return provider, release, nil
+    }
+
+    archiveCache, err := file.NewArchiveCache(provider, cfg.File.CacheBudget, cfg.File.CacheShards)
+    if err != nil {
+        release()
+        return nil, nil, err
+    }
+    releases = append(releases, archiveCache.Release)
+
+    // The configuration has been validated so the indices are known to parse.
+    indices, _ := cfg.FileCacheWarm()
+    if err := archiveCache.Warm(indices...); err != nil {
+        release()
+        return nil, nil, err
+    }
+    return archiveCache.GetArchive, release, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "go/format"
+    "text/template"
+)
+
+// The data that the templates are executed with.
+type templateData struct {
+    Source string
+    Spec
+}
+
+var funcs = template.FuncMap{
+    "size": func(m MessageSpec) string { return m.sizeExpression() },
+    "direction": func(m MessageSpec) string {
+        if m.Direction == inbound {
+            return "message.DirectionInbound"
+        }
+        return "message.DirectionOutbound"
+    },
+    "goType": func(f FieldSpec) string { return fieldTypes[f.Type].goType },
+    "get": func(f FieldSpec) string { return f.method("Get") },
+    "put": func(f FieldSpec) string { return f.method("Put") },
+    "sample": func(f FieldSpec, i int) string { return f.sample(i) },
+}
+
+var messagesTemplate = template.Must(template.New("messages").Funcs(funcs).Parse(`// Code generated by msggen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"github.com/sprinkle-it/donut/buffer"
+	"github.com/sprinkle-it/donut/message"
+)
+
+var (
+{{- range .Messages}}
+	{{.Name}}Config = message.Config{
+		Id:        {{.Id}},
+		Size:      {{size .}},
+		Direction: {{direction .}},
+		New:       func() message.Message { return &{{.Name}}{} },
+	}
+{{end}}
+	// All of the messages generated from {{.Source}}.
+	generated = []message.Config{
+{{- range .Messages}}
+		{{.Name}}Config,
+{{- end}}
+	}
+)
+{{range .Messages}}
+{{if .Doc}}// {{.Doc}}
+{{end -}}
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{goType .}}
+{{- end}}
+}
+
+func ({{.Name}}) Config() message.Config { return {{.Name}}Config }
+{{if eq .Direction "outbound"}}
+func (m {{.Name}}) Encode(buf *buffer.ByteBuffer) error {
+{{- range .Fields}}
+	if err := buf.{{put .}}(m.{{.Name}}); err != nil {
+		return err
+	}
+{{end}}
+	return nil
+}
+{{else}}
+func (m *{{.Name}}) Decode(buf *buffer.ByteBuffer, length int) (err error) {
+{{- range .Fields}}
+	if m.{{.Name}}, err = buf.{{get .}}(); err != nil {
+		return
+	}
+{{end}}
+	return
+}
+{{end -}}
+{{end}}`))
+
+var testsTemplate = template.Must(template.New("tests").Funcs(funcs).Parse(`// Code generated by msggen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"github.com/sprinkle-it/donut/buffer"
+	"github.com/sprinkle-it/donut/message/messagetest"
+	"testing"
+)
+
+func TestGenerated_RoundTrip(t *testing.T) {
+	messagetest.RoundTrip(t, generated)
+}
+{{range .Messages}}
+{{- if eq .Direction "outbound"}}
+func Test{{.Name}}_Encode(t *testing.T) {
+	expected := {{.Name}}{
+{{- range $i, $f := .Fields}}
+		{{$f.Name}}: {{sample $f $i}},
+{{- end}}
+	}
+
+	buf := buffer.NewByteBuffer(256)
+	if err := expected.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The payload is decoded in the order that the fields are declared in the spec.
+	payload := buffer.ByteBuffer{Bytes: buf.Bytes[:buf.Offset]}
+
+	var decoded {{.Name}}
+{{- if .Fields}}
+	var err error
+{{- end}}
+{{- range .Fields}}
+	if decoded.{{.Name}}, err = payload.{{get .}}(); err != nil {
+		t.Fatal(err)
+	}
+{{- end}}
+
+	if payload.Offset != len(payload.Bytes) || decoded != expected {
+		t.Errorf("expected %+v to be encoded as %x, decoded %+v", expected, payload.Bytes, decoded)
+	}
+}
+{{else}}
+func Test{{.Name}}_Decode(t *testing.T) {
+	expected := {{.Name}}{
+{{- range $i, $f := .Fields}}
+		{{$f.Name}}: {{sample $f $i}},
+{{- end}}
+	}
+
+	// The payload is encoded in the order that the fields are declared in the spec.
+	buf := buffer.NewByteBuffer(256)
+{{- range .Fields}}
+	if err := buf.{{put .}}(expected.{{.Name}}); err != nil {
+		t.Fatal(err)
+	}
+{{- end}}
+
+	payload := buffer.ByteBuffer{Bytes: buf.Bytes[:buf.Offset]}
+
+	var decoded {{.Name}}
+	if err := decoded.Decode(&payload, len(payload.Bytes)); err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.Offset != len(payload.Bytes) || decoded != expected {
+		t.Errorf("expected %x to be decoded as %+v, got %+v", payload.Bytes, expected, decoded)
+	}
+}
+{{end}}
+{{- end}}`))
+
+// Generates the source of the messages declared by a spec and the source of their round trip tests.
+func Generate(source string, spec Spec) ([]byte, []byte, error) {
+    data := templateData{Source: source, Spec: spec}
+
+    messages, err := execute(messagesTemplate, data)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    tests, err := execute(testsTemplate, data)
+    if err != nil {
+        return nil, nil, err
+    }
+    return messages, tests, nil
+}
+
+func execute(t *template.Template, data templateData) ([]byte, error) {
+    var buf bytes.Buffer
+    if err := t.Execute(&buf, data); err != nil {
+        return nil, fmt.Errorf("msggen: failed to generate %s: %v", t.Name(), err)
+    }
+
+    formatted, err := format.Source(buf.Bytes())
+    if err != nil {
+        return nil, fmt.Errorf("msggen: generated invalid %s: %v", t.Name(), err)
+    }
+    return formatted, nil
+}
@@ -0,0 +1,51 @@
+// Command msggen generates message types from a declarative protocol spec. It is meant to be run with go generate:
+//
+//  //go:generate go run ../cmd/msggen -spec messages.json
+//
+// For a spec named messages.json the messages are written to messages_gen.go and their round trip tests to
+// messages_gen_test.go, next to the spec.
+package main
+
+import (
+    "flag"
+    "io/ioutil"
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+func main() {
+    specPath := flag.String("spec", "", "path to the JSON protocol spec")
+    flag.Parse()
+
+    if *specPath == "" {
+        log.Fatal("msggen: a spec is required")
+    }
+
+    f, err := os.Open(*specPath)
+    if err != nil {
+        log.Fatal(err)
+    }
+    defer f.Close()
+
+    spec, err := ReadSpec(f)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    messages, tests, err := Generate(filepath.Base(*specPath), spec)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    base := strings.TrimSuffix(*specPath, filepath.Ext(*specPath)) + "_gen"
+
+    if err := ioutil.WriteFile(base+".go", messages, 0644); err != nil {
+        log.Fatal(err)
+    }
+
+    if err := ioutil.WriteFile(base+"_test.go", tests, 0644); err != nil {
+        log.Fatal(err)
+    }
+}
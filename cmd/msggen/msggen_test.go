@@ -0,0 +1,133 @@
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+func TestReadSpec_Collisions(t *testing.T) {
+    tests := []struct {
+        name     string
+        messages string
+        collides bool
+    }{
+        {
+            name: "SameDirection",
+            messages: `{"name": "MinimapWalk", "id": 52, "direction": "inbound"},
+                       {"name": "WalkHere", "id": 52, "direction": "inbound"}`,
+            collides: true,
+        },
+        {
+            name: "DifferentDirection",
+            messages: `{"name": "MinimapWalk", "id": 52, "direction": "inbound"},
+                       {"name": "ClearInputBox", "id": 52, "direction": "outbound"}`,
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            spec := `{"package": "gameold", "messages": [` + test.messages + `]}`
+
+            _, err := ReadSpec(strings.NewReader(spec))
+            if test.collides && err == nil {
+                t.Error("expected the messages to collide")
+            }
+
+            if !test.collides && err != nil {
+                t.Errorf("expected the messages not to collide: %v", err)
+            }
+        })
+    }
+}
+
+func TestReadSpec_Invalid(t *testing.T) {
+    for name, message := range map[string]string{
+        "UnknownType":       `{"name": "A", "id": 1, "direction": "inbound", "fields": [{"name": "B", "type": "int"}]}`,
+        "UnknownTransform":  `{"name": "A", "id": 1, "direction": "inbound", "fields": [{"name": "B", "type": "uint64", "transform": "a"}]}`,
+        "VariableFixedSize": `{"name": "A", "id": 1, "direction": "inbound", "fields": [{"name": "B", "type": "string"}]}`,
+        "UnknownDirection":  `{"name": "A", "id": 1, "direction": "both"}`,
+        "UnexportedName":    `{"name": "a", "id": 1, "direction": "inbound"}`,
+        "UnknownKey":        `{"name": "A", "id": 1, "direction": "inbound", "opcode": 1}`,
+        "Stage":             `{"name": "A", "id": 1, "direction": "inbound", "stage": "game"}`,
+    } {
+        spec := `{"package": "gameold", "messages": [` + message + `]}`
+        if _, err := ReadSpec(strings.NewReader(spec)); err == nil {
+            t.Errorf("%s: expected the spec to be invalid", name)
+        }
+    }
+}
+
+func TestGenerate(t *testing.T) {
+    spec, err := ReadSpec(strings.NewReader(`{
+        "package": "gameold",
+        "messages": [{
+            "name": "CameraRotated",
+            "id": 39,
+            "direction": "inbound",
+            "fields": [
+                {"name": "Pitch", "type": "uint16", "transform": "a"},
+                {"name": "Yaw", "type": "uint16", "transform": "le"}
+            ]
+        }, {
+            "name": "DisplaySystemMessage",
+            "id": 3,
+            "direction": "outbound",
+            "size": "byte",
+            "fields": [
+                {"name": "Type", "type": "smart"},
+                {"name": "Text", "type": "string"}
+            ]
+        }]
+    }`))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    messages, tests, err := Generate("messages.json", spec)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for _, expected := range []string{
+        "Size:      4,",
+        "Direction: message.DirectionInbound,",
+        "Size:      message.SizeVariableByte,",
+        "Direction: message.DirectionOutbound,",
+        "buf.GetUint16A()",
+        "buf.GetUint16LE()",
+        "buf.PutSmart(m.Type)",
+        "Type uint16",
+        "func (m *CameraRotated) Decode(buf *buffer.ByteBuffer, length int) (err error) {",
+        "func (m DisplaySystemMessage) Encode(buf *buffer.ByteBuffer) error {",
+    } {
+        if !bytes.Contains(messages, []byte(expected)) {
+            t.Errorf("expected the generated messages to contain %q", expected)
+        }
+    }
+
+    // Messages are only generated with the method of their direction.
+    for _, unexpected := range []string{
+        "func (m CameraRotated) Encode(",
+        "func (m *DisplaySystemMessage) Decode(",
+    } {
+        if bytes.Contains(messages, []byte(unexpected)) {
+            t.Errorf("expected the generated messages not to contain %q", unexpected)
+        }
+    }
+
+    // Each message is tested against the order of the fields in the spec, with values that tell the fields apart.
+    for _, expected := range []string{
+        "messagetest.RoundTrip(t, generated)",
+        "func TestCameraRotated_Decode(t *testing.T) {",
+        "if err := buf.PutUint16A(expected.Pitch); err != nil {",
+        "Yaw:   0x8103,",
+        "func TestDisplaySystemMessage_Encode(t *testing.T) {",
+        "if decoded.Type, err = payload.GetSmart(); err != nil {",
+        `Text: "field 1",`,
+    } {
+        if !bytes.Contains(tests, []byte(expected)) {
+            t.Errorf("expected the generated tests to contain %q", expected)
+        }
+    }
+}
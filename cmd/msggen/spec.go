@@ -0,0 +1,225 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "go/token"
+    "io"
+    "strings"
+)
+
+// A protocol spec declares the messages of a package and the fields that each of them is encoded with.
+type Spec struct {
+    // The name of the package that the messages are generated into.
+    Package  string        `json:"package"`
+    Messages []MessageSpec `json:"messages"`
+}
+
+type MessageSpec struct {
+    Name string `json:"name"`
+    Id   uint8  `json:"id"`
+
+    // Either "inbound" for messages sent by the client or "outbound" for messages sent by the server. Inbound messages
+    // are only generated with a Decode method and outbound messages with an Encode method.
+    Direction string `json:"direction"`
+
+    // Either "byte" or "short" for messages with a variable length. Messages without a size are fixed, their size is
+    // the sum of the widths of their fields.
+    Size string `json:"size"`
+
+    // Documentation of the message, written as the comment of the generated type.
+    Doc    string      `json:"doc"`
+    Fields []FieldSpec `json:"fields"`
+}
+
+type FieldSpec struct {
+    Name string `json:"name"`
+
+    // One of the types in fieldTypes.
+    Type string `json:"type"`
+
+    // The transformation the field is encoded with, for example "a", "le" or "ime". Empty if it is not transformed.
+    Transform string `json:"transform"`
+}
+
+// A type of field that can be declared in a spec.
+type fieldType struct {
+    // The type of the field in the generated struct.
+    goType string
+
+    // The name of the ByteBuffer methods, without the Get or Put prefix and transformation suffix.
+    method string
+
+    // The number of bytes the field is encoded with, or -1 if it has a variable length.
+    width int
+
+    // The transformations that the buffer supports for the type.
+    transforms []string
+}
+
+var fieldTypes = map[string]fieldType{
+    "uint8":    {goType: "uint8", method: "Uint8", width: 1, transforms: []string{"a", "c", "s"}},
+    "uint16":   {goType: "uint16", method: "Uint16", width: 2, transforms: []string{"le", "a", "lea"}},
+    "uint32":   {goType: "uint32", method: "Uint32", width: 4, transforms: []string{"le", "me", "ime"}},
+    "uint64":   {goType: "uint64", method: "Uint64", width: 8},
+    "bool":     {goType: "bool", method: "Bool", width: 1},
+    "string":   {goType: "string", method: "CString", width: -1},
+    "smart":    {goType: "uint16", method: "Smart", width: -1},
+    "smartint": {goType: "uint32", method: "SmartInt", width: -1},
+}
+
+const (
+    inbound  = "inbound"
+    outbound = "outbound"
+)
+
+// Reads a spec from JSON and validates it.
+func ReadSpec(r io.Reader) (Spec, error) {
+    var spec Spec
+
+    decoder := json.NewDecoder(r)
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&spec); err != nil {
+        return Spec{}, fmt.Errorf("msggen: failed to read spec: %v", err)
+    }
+
+    if err := spec.Validate(); err != nil {
+        return Spec{}, err
+    }
+    return spec, nil
+}
+
+// Validates the spec. Messages of the same direction cannot share an identifier because the receiving end would not be
+// able to tell them apart.
+func (s Spec) Validate() error {
+    if !token.IsIdentifier(s.Package) {
+        return fmt.Errorf("msggen: package %q is not a valid package name", s.Package)
+    }
+
+    type key struct {
+        direction string
+        id        uint8
+    }
+
+    names := make(map[string]bool)
+    ids := make(map[key]string)
+
+    for _, msg := range s.Messages {
+        if !token.IsIdentifier(msg.Name) || !token.IsExported(msg.Name) {
+            return fmt.Errorf("msggen: message name %q is not an exported identifier", msg.Name)
+        }
+
+        if names[msg.Name] {
+            return fmt.Errorf("msggen: message %s is declared more than once", msg.Name)
+        }
+        names[msg.Name] = true
+
+        if msg.Direction != inbound && msg.Direction != outbound {
+            return fmt.Errorf("msggen: message %s has direction %q, expected %q or %q", msg.Name, msg.Direction,
+                inbound, outbound)
+        }
+
+        k := key{direction: msg.Direction, id: msg.Id}
+        if existing, ok := ids[k]; ok {
+            return fmt.Errorf("msggen: %s messages %s and %s have the same id %d", msg.Direction, existing,
+                msg.Name, msg.Id)
+        }
+        ids[k] = msg.Name
+
+        if err := msg.validate(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (m MessageSpec) validate() error {
+    switch m.Size {
+    case "", "byte", "short":
+    default:
+        return fmt.Errorf("msggen: message %s has size %q, expected \"byte\", \"short\" or none", m.Name, m.Size)
+    }
+
+    fields := make(map[string]bool)
+    for _, field := range m.Fields {
+        if !token.IsIdentifier(field.Name) || !token.IsExported(field.Name) {
+            return fmt.Errorf("msggen: field name %q of message %s is not an exported identifier", field.Name,
+                m.Name)
+        }
+
+        if fields[field.Name] {
+            return fmt.Errorf("msggen: field %s of message %s is declared more than once", field.Name, m.Name)
+        }
+        fields[field.Name] = true
+
+        t, ok := fieldTypes[field.Type]
+        if !ok {
+            return fmt.Errorf("msggen: field %s of message %s has unknown type %q", field.Name, m.Name, field.Type)
+        }
+
+        if field.Transform != "" && !contains(t.transforms, field.Transform) {
+            return fmt.Errorf("msggen: field %s of message %s has type %s which cannot be transformed with %q",
+                field.Name, m.Name, field.Type, field.Transform)
+        }
+
+        if t.width < 0 && m.Size == "" {
+            return fmt.Errorf("msggen: message %s has field %s of variable length so it needs a size", m.Name,
+                field.Name)
+        }
+    }
+    return nil
+}
+
+// Gets the expression for the size of the message in the generated configuration.
+func (m MessageSpec) sizeExpression() string {
+    switch m.Size {
+    case "byte":
+        return "message.SizeVariableByte"
+    case "short":
+        return "message.SizeVariableShort"
+    }
+
+    size := 0
+    for _, field := range m.Fields {
+        size += fieldTypes[field.Type].width
+    }
+    return fmt.Sprint(size)
+}
+
+// Gets a literal value for a field in the generated tests. The value depends on the position of the field so that
+// fields of the same type which are encoded in the wrong order are told apart, and uses every byte of the field so that
+// transformations and byte orders are told apart.
+func (f FieldSpec) sample(position int) string {
+    switch f.Type {
+    case "uint8":
+        return fmt.Sprintf("%#x", 0x81+position)
+    case "uint16":
+        return fmt.Sprintf("%#x", 0x8102+position)
+    case "uint32":
+        return fmt.Sprintf("%#x", 0x81020304+position)
+    case "uint64":
+        return fmt.Sprintf("%#x", 0x8102030405060708+uint64(position))
+    case "bool":
+        return fmt.Sprint(position%2 == 0)
+    case "string":
+        return fmt.Sprintf("%q", fmt.Sprint("field ", position))
+    case "smart":
+        return fmt.Sprintf("%#x", 0x1234+position)
+    default:
+        return fmt.Sprintf("%#x", 0x123456+position)
+    }
+}
+
+// Gets the name of the buffer method that gets or puts a field, the prefix is either Get or Put.
+func (f FieldSpec) method(prefix string) string {
+    return prefix + fieldTypes[f.Type].method + strings.ToUpper(f.Transform)
+}
+
+func contains(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,538 @@
+package config
+
+import (
+    "fmt"
+    "github.com/sprinkle-it/coffee"
+    "github.com/sprinkle-it/donut/file"
+    "github.com/sprinkle-it/donut/game"
+    "github.com/sprinkle-it/donut/logging"
+    "github.com/sprinkle-it/donut/message"
+    "github.com/sprinkle-it/donut/server"
+    "go.uber.org/zap"
+    "go.uber.org/zap/zapcore"
+    "math"
+    "os"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Prefix of the environment variables that override values from the configuration file. The name of each variable is
+// the prefix followed by the table path and key in upper case joined by underscores, for example DONUT_SERVER_PORT or
+// DONUT_FILE_SESSION_PASSIVE_REQUEST_CAPACITY.
+const EnvironmentPrefix = "DONUT"
+
+// Configuration for a deployment of the server.
+type Config struct {
+    Log     LogConfig     `toml:"log"`
+    Server  ServerConfig  `toml:"server"`
+    Client  ClientConfig  `toml:"client"`
+    File    FileConfig    `toml:"file"`
+    Metrics MetricsConfig `toml:"metrics"`
+    Admin   AdminConfig   `toml:"admin"`
+}
+
+type LogConfig struct {
+    // Whether to use zap's development logging configuration which logs human readable output.
+    Development bool `toml:"development"`
+
+    // The level of the root logger which subsystems without their own level inherit.
+    Level string `toml:"level"`
+
+    // The levels of subsystems by logger name, for example "server", "server.client", "file" or "game".
+    Levels map[string]string `toml:"levels"`
+}
+
+type ServerConfig struct {
+    Port           int `toml:"port"`
+    ClientCapacity int `toml:"client_capacity"`
+}
+
+type ClientConfig struct {
+    InputCapacity   int `toml:"input_capacity"`
+    OutputCapacity  int `toml:"output_capacity"`
+    MessageCapacity int `toml:"message_capacity"`
+    CommandCapacity int `toml:"command_capacity"`
+
+    // Unrecognized messages that are skipped rather than closing the client, keyed by "stage.opcode" with the size of
+    // the message, -1 or -2 for messages with a length of a variable byte or short. A stage of "*" matches clients in
+    // every stage.
+    DecodeSkip map[string]int `toml:"decode_skip"`
+
+    // Messages that are dropped rather than closing the client when their payload fails to decode, keyed by
+    // "stage.opcode".
+    DecodeDrop map[string]bool `toml:"decode_drop"`
+}
+
+type FileConfig struct {
+    // Path to the cache that archives are served from, in the format of the cache.
+    Cache    string            `toml:"cache"`
+    Format   string            `toml:"format"`
+    Capacity int               `toml:"capacity"`
+    Workers  int               `toml:"workers"`
+    Session  FileSessionConfig `toml:"session"`
+    HTTP     FileHTTPConfig    `toml:"http"`
+
+    // The compression of the release manifest, which is generated uncompressed. Either "none", "gzip" or "bzip2".
+    // Other archives are served as they are stored as clients check them against the checksums in the manifests.
+    Compression string `toml:"compression"`
+
+    // The rate in bytes per second that archives are written at across every session and the number of bytes that
+    // can be written at once. Not limited when the rate is zero.
+    Rate  int `toml:"rate"`
+    Burst int `toml:"burst"`
+
+    // The number of bytes of archives that are held in memory and the number of shards they are split over. Archives
+    // are not held in memory when the budget is zero.
+    CacheBudget int `toml:"cache_budget"`
+    CacheShards int `toml:"cache_shards"`
+
+    // A comma separated list of the indices whose archives are loaded into memory at startup, for example "0, 2".
+    CacheWarm string `toml:"cache_warm"`
+
+    // How often the cache directory or pack file is checked for changes, the archives are reloaded once the cache has
+    // changed and has then been left alone for an interval. The cache is not watched when zero.
+    CacheWatch time.Duration `toml:"cache_watch"`
+}
+
+// The formats of caches that archives can be served from.
+const (
+    // A cache in the format of the client, main_file_cache.dat2 and its idx files.
+    FormatCache = "cache"
+
+    // A directory tree where each archive is a file named index/id.dat.
+    FormatDirectory = "directory"
+
+    // A single pack file that is memory mapped.
+    FormatPack = "pack"
+)
+
+type FileHTTPConfig struct {
+    // The address to serve archives over HTTP on. Archives are not served over HTTP when empty.
+    Address string `toml:"address"`
+
+    // The rate in bytes per second that each response is written at and the number of bytes that can be written at
+    // once. Not limited when the rate is zero.
+    Rate  int `toml:"rate"`
+    Burst int `toml:"burst"`
+
+    // How long a request can take to be read and how long its response can take to be written.
+    ReadTimeout  time.Duration `toml:"read_timeout"`
+    WriteTimeout time.Duration `toml:"write_timeout"`
+}
+
+type FileSessionConfig struct {
+    PriorityRequestCapacity int `toml:"priority_request_capacity"`
+    PassiveRequestCapacity  int `toml:"passive_request_capacity"`
+
+    // The rate in bytes per second that passive requests are served at while clients are logged in to the game.
+    // Passive requests are not throttled when zero.
+    OnlinePassiveRate int `toml:"online_passive_rate"`
+
+    // The rate in bytes per second that archives are written to each session at and the number of bytes that can be
+    // written at once. Not limited when the rate is zero.
+    Rate  int `toml:"rate"`
+    Burst int `toml:"burst"`
+
+    // The number of bytes of each priority request that are written without waiting on the rate limits.
+    PriorityBurst int `toml:"priority_burst"`
+}
+
+type MetricsConfig struct {
+    // The address to serve metrics on. Metrics are not served when empty.
+    Address string `toml:"address"`
+}
+
+type AdminConfig struct {
+    // The address to serve the admin API on. The admin API is not served when empty.
+    Address string `toml:"address"`
+    Token   string `toml:"token"`
+}
+
+// Gets the default configuration.
+func Default() Config {
+    return Config{
+        Log: LogConfig{
+            Development: true,
+            Level:       "info",
+        },
+        Server: ServerConfig{
+            Port:           43594,
+            ClientCapacity: 2000,
+        },
+        Client: ClientConfig{
+            InputCapacity:   10240,
+            OutputCapacity:  10240,
+            MessageCapacity: 1000,
+            CommandCapacity: 64,
+        },
+        File: FileConfig{
+            Cache:       "cache",
+            Format:      "cache",
+            Capacity:    1000,
+            Workers:     2,
+            Compression: "gzip",
+            Burst:       1048576,
+            CacheBudget: 268435456,
+            CacheShards: 16,
+            Session: FileSessionConfig{
+                PriorityRequestCapacity: 200,
+                PassiveRequestCapacity:  200,
+                OnlinePassiveRate:       65536,
+                Burst:                   65536,
+                PriorityBurst:           65536,
+            },
+            HTTP: FileHTTPConfig{
+                Burst:        65536,
+                ReadTimeout:  10 * time.Second,
+                WriteTimeout: 5 * time.Minute,
+            },
+        },
+    }
+}
+
+// Loads the configuration. The configuration starts from the defaults, is overlaid with the configuration file at
+// the given path if the path is not empty and is then overridden by any environment variables that are set. The
+// resulting configuration is validated before it is returned.
+func Load(path string) (Config, error) {
+    cfg := Default()
+
+    if path != "" {
+        f, err := os.Open(path)
+        if err != nil {
+            return Config{}, err
+        }
+        defer f.Close()
+
+        if err := Decode(f, &cfg); err != nil {
+            return Config{}, err
+        }
+    }
+
+    if err := applyEnvironment(reflect.ValueOf(&cfg).Elem(), EnvironmentPrefix, os.LookupEnv); err != nil {
+        return Config{}, err
+    }
+
+    if err := cfg.Validate(); err != nil {
+        return Config{}, err
+    }
+
+    return cfg, nil
+}
+
+// Overrides the fields of a struct with the environment variables that are set for them.
+func applyEnvironment(table reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+    t := table.Type()
+    for i := 0; i < t.NumField(); i++ {
+        name := strings.Split(t.Field(i).Tag.Get("toml"), ",")[0]
+        if name == "" {
+            continue
+        }
+
+        key := prefix + "_" + strings.ToUpper(name)
+        field := table.Field(i)
+
+        if field.Kind() == reflect.Map {
+            // Maps have arbitrary keys so they can only be set from the configuration file.
+            continue
+        }
+
+        if field.Kind() == reflect.Struct && field.Type() != durationType {
+            if err := applyEnvironment(field, key, lookup); err != nil {
+                return err
+            }
+            continue
+        }
+
+        if value, ok := lookup(key); ok {
+            if err := setString(field, value); err != nil {
+                return fmt.Errorf("config: environment variable %s: %v", key, err)
+            }
+        }
+    }
+    return nil
+}
+
+// Validates that all of the values in the configuration are usable.
+func (c Config) Validate() error {
+    var level zapcore.Level
+    if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+        return fmt.Errorf("config: log.level must be a valid level, got %q", c.Log.Level)
+    }
+
+    for name, value := range c.Log.Levels {
+        if err := level.UnmarshalText([]byte(value)); err != nil {
+            return fmt.Errorf("config: log.levels.%s must be a valid level, got %q", name, value)
+        }
+    }
+
+    if c.Server.Port < 1 || c.Server.Port > 65535 {
+        return fmt.Errorf("config: server.port must be between 1 and 65535, got %d", c.Server.Port)
+    }
+
+    if err := positive("server.client_capacity", c.Server.ClientCapacity); err != nil {
+        return err
+    }
+
+    if err := positive("client.input_capacity", c.Client.InputCapacity); err != nil {
+        return err
+    }
+
+    if err := positive("client.output_capacity", c.Client.OutputCapacity); err != nil {
+        return err
+    }
+
+    if err := positive("client.message_capacity", c.Client.MessageCapacity); err != nil {
+        return err
+    }
+
+    if err := positive("client.command_capacity", c.Client.CommandCapacity); err != nil {
+        return err
+    }
+
+    if _, err := c.DecodePolicy(); err != nil {
+        return err
+    }
+
+    if c.File.Cache == "" {
+        return fmt.Errorf("config: file.cache must be the path to a cache")
+    }
+
+    switch c.File.Format {
+    case FormatCache, FormatDirectory, FormatPack:
+    default:
+        return fmt.Errorf("config: file.format must be cache, directory or pack, got %q", c.File.Format)
+    }
+
+    if err := positive("file.capacity", c.File.Capacity); err != nil {
+        return err
+    }
+
+    if err := positive("file.workers", c.File.Workers); err != nil {
+        return err
+    }
+
+    if _, ok := compressions[c.File.Compression]; !ok {
+        return fmt.Errorf("config: file.compression must be none, gzip or bzip2, got %q", c.File.Compression)
+    }
+
+    if err := positive("file.cache_shards", c.File.CacheShards); err != nil {
+        return err
+    }
+
+    if _, err := c.FileCacheWarm(); err != nil {
+        return err
+    }
+
+    for key, d := range map[string]time.Duration{
+        "file.cache_watch":        c.File.CacheWatch,
+        "file.http.read_timeout":  c.File.HTTP.ReadTimeout,
+        "file.http.write_timeout": c.File.HTTP.WriteTimeout,
+    } {
+        if d < 0 {
+            return fmt.Errorf("config: %s must not be negative, got %s", key, d)
+        }
+    }
+
+    if err := positive("file.session.priority_request_capacity", c.File.Session.PriorityRequestCapacity); err != nil {
+        return err
+    }
+
+    if err := positive("file.session.passive_request_capacity", c.File.Session.PassiveRequestCapacity); err != nil {
+        return err
+    }
+
+    for key, n := range map[string]int{
+        "file.rate":                        c.File.Rate,
+        "file.burst":                       c.File.Burst,
+        "file.cache_budget":                c.File.CacheBudget,
+        "file.session.online_passive_rate": c.File.Session.OnlinePassiveRate,
+        "file.session.rate":                c.File.Session.Rate,
+        "file.session.burst":               c.File.Session.Burst,
+        "file.session.priority_burst":      c.File.Session.PriorityBurst,
+        "file.http.rate":                   c.File.HTTP.Rate,
+        "file.http.burst":                  c.File.HTTP.Burst,
+    } {
+        if n < 0 {
+            return fmt.Errorf("config: %s must not be negative, got %d", key, n)
+        }
+    }
+
+    if c.Admin.Address != "" && c.Admin.Token == "" {
+        return fmt.Errorf("config: admin.token must be set when admin.address is set")
+    }
+
+    return nil
+}
+
+// Builds the subsystem log levels from the log configuration.
+func (c Config) LogLevels() *logging.Levels {
+    var root zapcore.Level
+    _ = root.UnmarshalText([]byte(c.Log.Level))
+
+    levels := logging.NewLevels(root)
+    for name, value := range c.Log.Levels {
+        var level zapcore.Level
+        _ = level.UnmarshalText([]byte(value))
+        levels.SetLevel(name, level)
+    }
+    return levels
+}
+
+// Builds the shared logger from the log configuration using the given levels.
+func (c Config) Logger(levels *logging.Levels) (*zap.Logger, error) {
+    cfg := zap.NewProductionConfig()
+    if c.Log.Development {
+        cfg = zap.NewDevelopmentConfig()
+        cfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+    }
+    cfg.DisableCaller = true
+    return logging.New(cfg, levels)
+}
+
+func positive(key string, n int) error {
+    if n < 1 {
+        return fmt.Errorf("config: %s must be greater than zero, got %d", key, n)
+    }
+    return nil
+}
+
+// Maps the client configuration onto a server client configuration.
+func (c Config) ClientConfig() server.ClientConfig {
+    cfg := server.NewDefaultClientConfig()
+    cfg.InputCapacity = c.Client.InputCapacity
+    cfg.OutputCapacity = c.Client.OutputCapacity
+    cfg.MessageCapacity = c.Client.MessageCapacity
+    cfg.CommandCapacity = c.Client.CommandCapacity
+
+    // The configuration has been validated so the policy is known to parse.
+    cfg.DecodePolicy, _ = c.DecodePolicy()
+    return cfg
+}
+
+// Gets the policy that decides whether clients survive messages that cannot be decoded.
+func (c Config) DecodePolicy() (server.DecodePolicy, error) {
+    policy := server.DecodePolicy{
+        Skip: make(map[server.Stage]map[uint8]message.Size),
+        Drop: make(map[server.Stage]map[uint8]bool),
+    }
+
+    for key, size := range c.Client.DecodeSkip {
+        stage, opcode, err := parseDecodeKey("client.decode_skip", key)
+        if err != nil {
+            return server.DecodePolicy{}, err
+        }
+
+        if size < message.SizeVariableShort || size > math.MaxUint16 {
+            return server.DecodePolicy{}, fmt.Errorf("config: client.decode_skip.%s must be a message size, got %d",
+                key, size)
+        }
+
+        if policy.Skip[stage] == nil {
+            policy.Skip[stage] = make(map[uint8]message.Size)
+        }
+        policy.Skip[stage][opcode] = message.Size(size)
+    }
+
+    for key, drop := range c.Client.DecodeDrop {
+        stage, opcode, err := parseDecodeKey("client.decode_drop", key)
+        if err != nil {
+            return server.DecodePolicy{}, err
+        }
+
+        if policy.Drop[stage] == nil {
+            policy.Drop[stage] = make(map[uint8]bool)
+        }
+        policy.Drop[stage][opcode] = drop
+    }
+    return policy, nil
+}
+
+// Parses a key of a decode policy table of the form "stage.opcode".
+func parseDecodeKey(table, key string) (server.Stage, uint8, error) {
+    separator := strings.LastIndex(key, ".")
+    if separator < 1 {
+        return "", 0, fmt.Errorf("config: %s keys must be of the form stage.opcode, got %q", table, key)
+    }
+
+    opcode, err := strconv.ParseUint(key[separator+1:], 10, 8)
+    if err != nil {
+        return "", 0, fmt.Errorf("config: %s keys must end with an opcode, got %q", table, key)
+    }
+    return server.Stage(key[:separator]), uint8(opcode), nil
+}
+
+// Maps the server configuration onto a server configuration. The logger, receivers and protocols are left for the
+// caller to set.
+func (c Config) ServerConfig() server.Config {
+    return server.Config{
+        ClientCapacity: c.Server.ClientCapacity,
+        ClientConfig:   c.ClientConfig(),
+    }
+}
+
+// Maps the file configuration onto a file service configuration. The logger, archive provider and protocols are left
+// for the caller to set.
+func (c Config) FileConfig() file.Config {
+    return file.Config{
+        Capacity: c.File.Capacity,
+        Workers:  c.File.Workers,
+        SessionConfig: file.SessionConfig{
+            PriorityRequestCapacity: c.File.Session.PriorityRequestCapacity,
+            PassiveRequestCapacity:  c.File.Session.PassiveRequestCapacity,
+            OnlinePassiveRate:       c.File.Session.OnlinePassiveRate,
+            Rate:                    c.File.Session.Rate,
+            Burst:                   c.File.Session.Burst,
+            PriorityBurst:           c.File.Session.PriorityBurst,
+        },
+        Rate:  c.File.Rate,
+        Burst: c.File.Burst,
+    }
+}
+
+var compressions = map[string]coffee.Compression{
+    "none":  coffee.Uncompressed,
+    "gzip":  coffee.Gzip,
+    "bzip2": coffee.Bzip2,
+}
+
+// Gets the compression of the release manifest.
+func (c Config) FileCompression() coffee.Compression {
+    return compressions[c.File.Compression]
+}
+
+// Maps the file HTTP configuration onto the configuration of the server that serves archives over HTTP. The logger and
+// file service are left for the caller to set.
+func (c Config) FileHTTPConfig() file.HTTPConfig {
+    return file.HTTPConfig{
+        Address:      c.File.HTTP.Address,
+        Rate:         c.File.HTTP.Rate,
+        Burst:        c.File.HTTP.Burst,
+        ReadTimeout:  c.File.HTTP.ReadTimeout,
+        WriteTimeout: c.File.HTTP.WriteTimeout,
+    }
+}
+
+// Gets the indices whose archives are loaded into memory at startup.
+func (c Config) FileCacheWarm() ([]uint8, error) {
+    var indices []uint8
+    for _, field := range strings.Split(c.File.CacheWarm, ",") {
+        field = strings.TrimSpace(field)
+        if field == "" {
+            continue
+        }
+
+        index, err := strconv.ParseUint(field, 10, 8)
+        if err != nil {
+            return nil, fmt.Errorf("config: file.cache_warm must be a comma separated list of indices, got %q", field)
+        }
+        indices = append(indices, uint8(index))
+    }
+    return indices, nil
+}
+
+// Maps the configuration onto a game service configuration. The logger is left for the caller to set.
+func (c Config) GameConfig() game.Config {
+    return game.Config{}
+}
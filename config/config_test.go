@@ -0,0 +1,196 @@
+package config
+
+import (
+    "github.com/sprinkle-it/donut/message"
+    "github.com/sprinkle-it/donut/server"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestDecode(t *testing.T) {
+    const document = `
+# Comment
+[server]
+port = 40000 # Trailing comment
+
+[file]
+cache = "/srv/cache#1"
+
+[file.session]
+passive_request_capacity = 1_000
+`
+
+    cfg := Default()
+    if err := Decode(strings.NewReader(document), &cfg); err != nil {
+        t.Fatal(err)
+    }
+
+    if cfg.Server.Port != 40000 {
+        t.Errorf("expected port to be 40000, got %d", cfg.Server.Port)
+    }
+
+    if cfg.File.Cache != "/srv/cache#1" {
+        t.Errorf("expected cache to be /srv/cache#1, got %s", cfg.File.Cache)
+    }
+
+    if cfg.File.Session.PassiveRequestCapacity != 1000 {
+        t.Errorf("expected passive request capacity to be 1000, got %d", cfg.File.Session.PassiveRequestCapacity)
+    }
+
+    if cfg.Server.ClientCapacity != Default().Server.ClientCapacity {
+        t.Errorf("expected client capacity to keep its default, got %d", cfg.Server.ClientCapacity)
+    }
+}
+
+func TestDecode_UnknownKey(t *testing.T) {
+    cfg := Default()
+    err := Decode(strings.NewReader("[server]\nprot = 1\n"), &cfg)
+    if err == nil || !strings.Contains(err.Error(), "server.prot") {
+        t.Errorf("expected an error naming the unknown key, got %v", err)
+    }
+}
+
+func TestDecode_Values(t *testing.T) {
+    tests := map[string]struct {
+        document string
+        check    func(cfg Config) bool
+    }{
+        "literal string": {
+            "[admin]\ntoken = 'se\\cr#et' # Comment",
+            func(cfg Config) bool { return cfg.Admin.Token == `se\cr#et` },
+        },
+        "basic string": {
+            "[admin]\ntoken = \"se\\\\cr#et\"",
+            func(cfg Config) bool { return cfg.Admin.Token == `se\cr#et` },
+        },
+        "quoted duration": {
+            "[file.http]\nread_timeout = \"3s\"",
+            func(cfg Config) bool { return cfg.File.HTTP.ReadTimeout == 3*time.Second },
+        },
+        "bare duration": {
+            "[file.http]\nread_timeout = 1m",
+            func(cfg Config) bool { return cfg.File.HTTP.ReadTimeout == time.Minute },
+        },
+        "boolean": {
+            "[log]\ndevelopment = false",
+            func(cfg Config) bool { return !cfg.Log.Development },
+        },
+    }
+
+    for name, test := range tests {
+        t.Run(name, func(t *testing.T) {
+            cfg := Default()
+            if err := Decode(strings.NewReader(test.document), &cfg); err != nil {
+                t.Fatal(err)
+            }
+
+            if !test.check(cfg) {
+                t.Errorf("expected %q to be decoded, got %+v", test.document, cfg)
+            }
+        })
+    }
+}
+
+func TestDecode_InvalidValues(t *testing.T) {
+    tests := map[string]string{
+        "quoted integer":             "[server]\nport = \"43594\"",
+        "literal integer":            "[server]\nport = '43594'",
+        "bare string":                "[admin]\ntoken = secret",
+        "unterminated literal":       "[admin]\ntoken = 'secret",
+        "quote in literal":           "[admin]\ntoken = 'sec'ret'",
+        "mismatched quotes":          "[admin]\ntoken = 'secret\"",
+        "boolean other than literal": "[log]\ndevelopment = 1",
+        "quoted boolean":             "[log]\ndevelopment = \"true\"",
+        "invalid bare duration":      "[file.http]\nread_timeout = soon",
+        "quoted map integer":         "[client.decode_skip]\n\"game.50\" = \"4\"",
+    }
+
+    for name, document := range tests {
+        t.Run(name, func(t *testing.T) {
+            cfg := Default()
+            if err := Decode(strings.NewReader(document), &cfg); err == nil {
+                t.Errorf("expected %q to fail to decode", document)
+            }
+        })
+    }
+}
+
+func TestApplyEnvironment(t *testing.T) {
+    env := map[string]string{
+        "DONUT_SERVER_PORT":                            "1234",
+        "DONUT_FILE_SESSION_PRIORITY_REQUEST_CAPACITY": "5",
+    }
+
+    lookup := func(key string) (string, bool) {
+        value, ok := env[key]
+        return value, ok
+    }
+
+    cfg := Default()
+    if err := applyEnvironment(reflect.ValueOf(&cfg).Elem(), EnvironmentPrefix, lookup); err != nil {
+        t.Fatal(err)
+    }
+
+    if cfg.Server.Port != 1234 {
+        t.Errorf("expected port to be 1234, got %d", cfg.Server.Port)
+    }
+
+    if cfg.File.Session.PriorityRequestCapacity != 5 {
+        t.Errorf("expected priority request capacity to be 5, got %d", cfg.File.Session.PriorityRequestCapacity)
+    }
+}
+
+func TestConfig_Validate(t *testing.T) {
+    cfg := Default()
+    cfg.File.Workers = 0
+
+    err := cfg.Validate()
+    if err == nil || !strings.Contains(err.Error(), "file.workers") {
+        t.Errorf("expected an error naming file.workers, got %v", err)
+    }
+}
+
+func TestConfig_DecodePolicy(t *testing.T) {
+    const document = `
+[client.decode_skip]
+"game.50" = 4
+"*.51" = -1
+
+[client.decode_drop]
+"*.12" = true
+`
+
+    cfg := Default()
+    if err := Decode(strings.NewReader(document), &cfg); err != nil {
+        t.Fatal(err)
+    }
+
+    if err := cfg.Validate(); err != nil {
+        t.Fatal(err)
+    }
+
+    policy := cfg.ClientConfig().DecodePolicy
+    if size, ok := policy.Skip["game"][50]; !ok || size != 4 {
+        t.Errorf("expected message 50 to be skipped with a size of 4 in the game stage, got %d %v", size, ok)
+    }
+
+    if size, ok := policy.Skip[server.StageAny][51]; !ok || size != message.SizeVariableByte {
+        t.Errorf("expected message 51 to be skipped with a variable byte size in every stage, got %d %v", size, ok)
+    }
+
+    if !policy.Drop[server.StageAny][12] {
+        t.Error("expected message 12 to be dropped in every stage")
+    }
+
+    for key, size := range map[string]int{"50": 4, "game.256": 4, "game.x": 4, "game.50": -3} {
+        cfg := Default()
+        cfg.Client.DecodeSkip = map[string]int{key: size}
+
+        err := cfg.Validate()
+        if err == nil || !strings.Contains(err.Error(), "client.decode_skip") {
+            t.Errorf("expected an error naming client.decode_skip for %q = %d, got %v", key, size, err)
+        }
+    }
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Decodes a TOML document into the value pointed to by v. Only the subset of TOML that is needed for configuration
+// files is supported: tables, dotted table headers, comments and key value pairs of strings, integers, floats and
+// booleans. Keys are matched against the toml tag of struct fields, tables that map to a map with string keys accept
+// any key. Strings and bare values are parsed into time.Duration values when the field being decoded to is a duration.
+func Decode(r io.Reader, v interface{}) error {
+    root := reflect.ValueOf(v)
+    if root.Kind() != reflect.Ptr || root.Elem().Kind() != reflect.Struct {
+        return fmt.Errorf("config: can only decode to a pointer to a struct, got %T", v)
+    }
+
+    table, path := root.Elem(), ""
+
+    scanner := bufio.NewScanner(r)
+    for line := 1; scanner.Scan(); line++ {
+        text := strings.TrimSpace(stripComment(scanner.Text()))
+        if text == "" {
+            continue
+        }
+
+        if strings.HasPrefix(text, "[") {
+            if !strings.HasSuffix(text, "]") {
+                return fmt.Errorf("config: line %d: unterminated table header", line)
+            }
+
+            path = strings.TrimSpace(text[1 : len(text)-1])
+
+            var err error
+            if table, err = lookupTable(root.Elem(), path); err != nil {
+                return fmt.Errorf("config: line %d: %v", line, err)
+            }
+            continue
+        }
+
+        separator := strings.Index(text, "=")
+        if separator < 0 {
+            return fmt.Errorf("config: line %d: expected a key value pair", line)
+        }
+
+        key := strings.TrimSpace(text[:separator])
+        raw := strings.TrimSpace(text[separator+1:])
+
+        // Quoted keys allow keys to contain dots, for example logger names.
+        if strings.HasPrefix(key, `"`) {
+            unquoted, err := strconv.Unquote(key)
+            if err != nil {
+                return fmt.Errorf("config: line %d: invalid key %s", line, key)
+            }
+            key = unquoted
+        }
+
+        if table.Kind() == reflect.Map {
+            if table.IsNil() {
+                table.Set(reflect.MakeMap(table.Type()))
+            }
+
+            value := reflect.New(table.Type().Elem()).Elem()
+            if err := decodeValue(value, raw); err != nil {
+                return fmt.Errorf("config: line %d: %s: %v", line, qualify(path, key), err)
+            }
+
+            table.SetMapIndex(reflect.ValueOf(key), value)
+            continue
+        }
+
+        field, ok := lookupField(table, key)
+        if !ok {
+            return fmt.Errorf("config: line %d: unknown key %s", line, qualify(path, key))
+        }
+
+        if err := decodeValue(field, raw); err != nil {
+            return fmt.Errorf("config: line %d: %s: %v", line, qualify(path, key), err)
+        }
+    }
+
+    return scanner.Err()
+}
+
+func qualify(path, key string) string {
+    if path == "" {
+        return key
+    }
+    return path + "." + key
+}
+
+// Strips a trailing comment from a line. Hashes within basic or literal strings are not treated as comments.
+func stripComment(line string) string {
+    var quote byte
+    for i := 0; i < len(line); i++ {
+        switch c := line[i]; {
+        case c == '\\' && quote == '"':
+            i++
+        case c == '"' || c == '\'':
+            if quote == 0 {
+                quote = c
+            } else if quote == c {
+                quote = 0
+            }
+        case c == '#' && quote == 0:
+            return line[:i]
+        }
+    }
+    return line
+}
+
+// Looks up the nested struct or map for a dotted table path. Maps can only be the last table in the path.
+func lookupTable(root reflect.Value, path string) (reflect.Value, error) {
+    table := root
+    for _, key := range strings.Split(path, ".") {
+        if table.Kind() != reflect.Struct {
+            return reflect.Value{}, fmt.Errorf("unknown table %s", path)
+        }
+
+        field, ok := lookupField(table, strings.TrimSpace(key))
+        if !ok {
+            return reflect.Value{}, fmt.Errorf("unknown table %s", path)
+        }
+
+        isMap := field.Kind() == reflect.Map && field.Type().Key().Kind() == reflect.String
+        if field.Kind() != reflect.Struct && !isMap {
+            return reflect.Value{}, fmt.Errorf("unknown table %s", path)
+        }
+        table = field
+    }
+    return table, nil
+}
+
+// Looks up the field of a struct that has the toml tag matching the key.
+func lookupField(table reflect.Value, key string) (reflect.Value, bool) {
+    t := table.Type()
+    for i := 0; i < t.NumField(); i++ {
+        if name := strings.Split(t.Field(i).Tag.Get("toml"), ",")[0]; name == key {
+            return table.Field(i), true
+        }
+    }
+    return reflect.Value{}, false
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Decodes a raw value to a field. The raw value can either be a basic string in double quotes, a literal string in
+// single quotes or a bare integer, float, boolean or duration. Strings can only be decoded to string and duration
+// fields and every other field must be given a bare value, so that a quoted number or a bare word is rejected rather
+// than silently converted.
+func decodeValue(field reflect.Value, raw string) error {
+    if strings.HasPrefix(raw, `"`) || strings.HasPrefix(raw, "'") {
+        s, err := unquote(raw)
+        if err != nil {
+            return err
+        }
+
+        if field.Kind() != reflect.String && field.Type() != durationType {
+            return fmt.Errorf("expected a bare %s, got the string %s", field.Type(), raw)
+        }
+        return setString(field, s)
+    }
+
+    switch {
+    case field.Kind() == reflect.String:
+        return fmt.Errorf("expected a quoted string, got %s", raw)
+    case field.Kind() == reflect.Bool && raw != "true" && raw != "false":
+        return fmt.Errorf("expected true or false, got %s", raw)
+    }
+    return setString(field, raw)
+}
+
+// Unquotes a basic string, which can contain escapes, or a literal string, which is taken as it is.
+func unquote(raw string) (string, error) {
+    quote := raw[:1]
+    if len(raw) < 2 || !strings.HasSuffix(raw, quote) {
+        return "", fmt.Errorf("unterminated string %s", raw)
+    }
+
+    if quote == "'" {
+        s := raw[1 : len(raw)-1]
+        if strings.Contains(s, "'") {
+            return "", fmt.Errorf("invalid literal string %s", raw)
+        }
+        return s, nil
+    }
+
+    s, err := strconv.Unquote(raw)
+    if err != nil {
+        return "", fmt.Errorf("invalid string %s", raw)
+    }
+    return s, nil
+}
+
+// Parses a string into a field based on the kind of the field.
+func setString(field reflect.Value, s string) error {
+    if field.Type() == durationType {
+        d, err := time.ParseDuration(s)
+        if err != nil {
+            return fmt.Errorf("invalid duration %q", s)
+        }
+        field.SetInt(int64(d))
+        return nil
+    }
+
+    switch field.Kind() {
+    case reflect.String:
+        field.SetString(s)
+    case reflect.Bool:
+        b, err := strconv.ParseBool(s)
+        if err != nil {
+            return fmt.Errorf("expected a boolean, got %s", s)
+        }
+        field.SetBool(b)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        n, err := strconv.ParseInt(strings.Replace(s, "_", "", -1), 0, field.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("expected an integer that fits in %s, got %s", field.Type(), s)
+        }
+        field.SetInt(n)
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        n, err := strconv.ParseUint(strings.Replace(s, "_", "", -1), 0, field.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("expected an unsigned integer that fits in %s, got %s", field.Type(), s)
+        }
+        field.SetUint(n)
+    case reflect.Float32, reflect.Float64:
+        n, err := strconv.ParseFloat(s, field.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("expected a float, got %s", s)
+        }
+        field.SetFloat(n)
+    default:
+        return fmt.Errorf("unsupported field type %s", field.Type())
+    }
+    return nil
+}
@@ -0,0 +1,166 @@
+package file
+
+import (
+    "encoding/binary"
+    "fmt"
+    "github.com/sprinkle-it/coffee"
+    "hash/crc32"
+    "sort"
+)
+
+// The length of the entry of each index in the release manifest, the checksum and version of its manifest.
+const releaseEntryLength = 8
+
+// Gets archives straight from the cache rather than through the storage, which holds on to every archive that it has
+// served. Only the release manifest is got from the storage as it is generated rather than read from the cache.
+func CacheProvider(cache *coffee.Cache, storage *coffee.Storage) ArchiveProvider {
+    return func(index uint8, id uint16) ([]byte, error) {
+        if index == coffee.ManifestPackage && id == coffee.ManifestPackage {
+            return storage.GetArchive(index, id)
+        }
+
+        b, err := cache.Get(index, id)
+        if err != nil {
+            return nil, err
+        }
+
+        // Trimming the archive removes the version footer that caches store after the archive.
+        return coffee.TrimArchive(b)
+    }
+}
+
+// Gets the indices that a provider serves, as listed by its release manifest.
+func ListIndices(provider ArchiveProvider) ([]uint8, error) {
+    packed, err := provider(coffee.ManifestPackage, coffee.ManifestPackage)
+    if err != nil {
+        return nil, err
+    }
+
+    b, err := decompress(packed)
+    if err != nil {
+        return nil, err
+    }
+
+    var indices []uint8
+    for index := 0; (index+1)*releaseEntryLength <= len(b) && index < coffee.ManifestPackage; index++ {
+        entry := b[index*releaseEntryLength : (index+1)*releaseEntryLength]
+
+        // Indices that are missing from the cache are left zeroed.
+        if binary.BigEndian.Uint64(entry) != 0 {
+            indices = append(indices, uint8(index))
+        }
+    }
+    return indices, nil
+}
+
+// Gets the identifiers of the archives of an index in ascending order, as listed by the manifest of the index.
+func ListArchives(provider ArchiveProvider, index uint8) ([]uint16, error) {
+    packed, err := provider(coffee.ManifestPackage, uint16(index))
+    if err != nil {
+        return nil, err
+    }
+
+    b, err := decompress(packed)
+    if err != nil {
+        return nil, err
+    }
+
+    manifest, err := coffee.DecodeManifest(b)
+    if err != nil {
+        return nil, err
+    }
+
+    ids := make([]uint16, 0, len(manifest.Groups))
+    for id := range manifest.Groups {
+        ids = append(ids, id)
+    }
+    sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+    return ids, nil
+}
+
+// Calls a function with every archive that a provider serves: the release manifest, the manifest of each index and
+// the archives listed by each manifest.
+func EachArchive(provider ArchiveProvider, fn func(index uint8, id uint16, archive []byte) error) error {
+    indices, err := ListIndices(provider)
+    if err != nil {
+        return err
+    }
+
+    visit := func(index uint8, id uint16) error {
+        archive, err := provider(index, id)
+        if err != nil {
+            return fmt.Errorf("file: archive %d:%d: %v", index, id, err)
+        }
+        return fn(index, id, archive)
+    }
+
+    if err := visit(coffee.ManifestPackage, coffee.ManifestPackage); err != nil {
+        return err
+    }
+
+    for _, index := range indices {
+        if err := visit(coffee.ManifestPackage, uint16(index)); err != nil {
+            return err
+        }
+
+        ids, err := ListArchives(provider, index)
+        if err != nil {
+            return err
+        }
+
+        for _, id := range ids {
+            if err := visit(index, id); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// Creates the packed release manifest for the given indices from the manifests that a provider serves. The release
+// manifest holds the checksum and version of the manifest of each index, clients compare it against their own cache.
+func CreateReleaseManifest(provider ArchiveProvider, indices []uint8) ([]byte, error) {
+    maximum := -1
+    for _, index := range indices {
+        if int(index) > maximum {
+            maximum = int(index)
+        }
+    }
+
+    b := make([]byte, (maximum+1)*releaseEntryLength)
+    for _, index := range indices {
+        packed, err := provider(coffee.ManifestPackage, uint16(index))
+        if err != nil {
+            return nil, err
+        }
+
+        manifest, err := decompress(packed)
+        if err != nil {
+            return nil, err
+        }
+
+        if len(manifest) < 1 || manifest[0] < coffee.MinimumFormat || manifest[0] > coffee.MaximumFormat {
+            return nil, fmt.Errorf("file: unsupported manifest format for index %d", index)
+        }
+
+        // Only manifests of the newer format have a version.
+        var version uint32
+        if manifest[0] > coffee.MinimumFormat && len(manifest) >= 5 {
+            version = binary.BigEndian.Uint32(manifest[1:])
+        }
+
+        entry := b[int(index)*releaseEntryLength:]
+        binary.BigEndian.PutUint32(entry, crc32.ChecksumIEEE(packed))
+        binary.BigEndian.PutUint32(entry[4:], version)
+    }
+    return coffee.CompressArchive(coffee.Uncompressed, b)
+}
+
+// Decompresses an archive, checking that it is long enough to have a header first as malformed archives would
+// otherwise panic.
+func decompress(packed []byte) ([]byte, error) {
+    if len(packed) < 5 {
+        return nil, fmt.Errorf("file: archive of %d bytes is too short to have a header", len(packed))
+    }
+    return coffee.DecompressArchive(packed)
+}
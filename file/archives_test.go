@@ -0,0 +1,194 @@
+package file
+
+import (
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "github.com/sprinkle-it/coffee"
+    "io/ioutil"
+    "math"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// Creates a provider of a small cache with the given indices, where each index has archives 0 and 2.
+func testCache(t *testing.T, indices ...uint8) ArchiveProvider {
+    archives := make(map[uint32][]byte)
+
+    for _, index := range indices {
+        // A manifest of format 5 without names listing groups 0 and 2, each with a single file.
+        manifest := []byte{5, 0, 0, 2, 0, 0, 0, 2}
+        manifest = append(manifest, make([]byte, 16)...)
+        manifest = append(manifest, 0, 1, 0, 1, 0, 0, 0, 0)
+
+        packed, err := coffee.CompressArchive(coffee.Uncompressed, manifest)
+        if err != nil {
+            t.Fatal(err)
+        }
+        archives[uint32(coffee.ManifestPackage)<<16|uint32(index)] = packed
+
+        for _, id := range []uint16{0, 2} {
+            archive, _ := coffee.CompressArchive(coffee.Uncompressed, []byte(fmt.Sprintf("archive %d:%d", index, id)))
+            archives[uint32(index)<<16|uint32(id)] = archive
+        }
+    }
+
+    provider := func(index uint8, id uint16) ([]byte, error) {
+        if archive, ok := archives[uint32(index)<<16|uint32(id)]; ok {
+            return archive, nil
+        }
+        return nil, fmt.Errorf("no archive %d:%d", index, id)
+    }
+
+    release, err := CreateReleaseManifest(provider, indices)
+    if err != nil {
+        t.Fatal(err)
+    }
+    archives[uint32(coffee.ManifestPackage)<<16|coffee.ManifestPackage] = release
+    return provider
+}
+
+// Checks that every archive of the expected provider is served the same by another provider.
+func sameArchives(t *testing.T, expected, actual ArchiveProvider) {
+    count := 0
+    err := EachArchive(expected, func(index uint8, id uint16, archive []byte) error {
+        count++
+
+        b, err := actual(index, id)
+        if err != nil {
+            return err
+        }
+
+        if !bytes.Equal(b, archive) {
+            t.Errorf("expected archive %d:%d to be %v, got %v", index, id, archive, b)
+        }
+        return nil
+    })
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // The release manifest and a manifest and two archives for each index.
+    if count != 1+3*2 {
+        t.Errorf("expected 7 archives, got %d", count)
+    }
+}
+
+func TestCreateReleaseManifest(t *testing.T) {
+    provider := testCache(t, 0, 2)
+
+    indices, err := ListIndices(provider)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if len(indices) != 2 || indices[0] != 0 || indices[1] != 2 {
+        t.Fatalf("expected indices 0 and 2, got %v", indices)
+    }
+
+    release, _ := provider(coffee.ManifestPackage, coffee.ManifestPackage)
+
+    // Entries follow the 5 byte header, the entry of index 2 is after the missing index 1.
+    if checksum := binary.BigEndian.Uint32(release[5+16:]); checksum == 0 {
+        t.Error("expected the checksum of index 2 to be set")
+    } else if !bytes.Equal(release[5+8:5+16], make([]byte, 8)) {
+        t.Error("expected the missing index to be zeroed")
+    }
+}
+
+func TestDirectoryProvider(t *testing.T) {
+    root, err := ioutil.TempDir("", "donut-directory")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(root)
+
+    provider := testCache(t, 0, 2)
+    if err := WriteDirectory(root, provider); err != nil {
+        t.Fatal(err)
+    }
+
+    if _, err := os.Stat(filepath.Join(root, "2", "2.dat")); err != nil {
+        t.Errorf("expected archives to be written as index/id.dat, got %v", err)
+    }
+
+    directory, err := OpenDirectory(root)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    sameArchives(t, provider, directory.GetArchive)
+}
+
+func TestPackFile(t *testing.T) {
+    root, err := ioutil.TempDir("", "donut-pack")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(root)
+
+    path := filepath.Join(root, "cache.pack")
+
+    provider := testCache(t, 0, 2)
+    if err := WritePackFile(path, provider); err != nil {
+        t.Fatal(err)
+    }
+
+    pack, err := OpenPackFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer pack.Close()
+
+    if pack.Len() != 7 {
+        t.Errorf("expected 7 archives in the pack file, got %d", pack.Len())
+    }
+
+    sameArchives(t, provider, pack.GetArchive)
+
+    if _, err := pack.GetArchive(1, 0); err == nil {
+        t.Error("expected an error for an archive that is not in the pack file")
+    }
+}
+
+func TestPackFile_Corrupt(t *testing.T) {
+    root, err := ioutil.TempDir("", "donut-pack")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(root)
+
+    path := filepath.Join(root, "cache.pack")
+    if err := WritePackFile(path, testCache(t, 0)); err != nil {
+        t.Fatal(err)
+    }
+
+    original, _ := ioutil.ReadFile(path)
+    table := binary.BigEndian.Uint64(original[9:])
+
+    corrupt := map[string]func(b []byte){
+        "a table out of bounds": func(b []byte) {
+            binary.BigEndian.PutUint64(b[9:], uint64(len(b)+1))
+        },
+        // The end of the archive wraps around to within the file if the start and length are added.
+        "an archive overflowing": func(b []byte) {
+            binary.BigEndian.PutUint64(b[table+4:], math.MaxUint64-1)
+        },
+    }
+
+    for name, fn := range corrupt {
+        b := append([]byte(nil), original...)
+        fn(b)
+
+        if err := ioutil.WriteFile(path, b, 0644); err != nil {
+            t.Fatal(err)
+        }
+
+        if pack, err := OpenPackFile(path); err == nil {
+            _ = pack.Close()
+            t.Errorf("expected a pack file with %s to be rejected", name)
+        }
+    }
+}
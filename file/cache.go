@@ -0,0 +1,167 @@
+package file
+
+import (
+    "container/list"
+    "fmt"
+    "sync"
+)
+
+// Caches the archives of a provider in memory up to a budget of bytes, evicting the least recently used archives once
+// the budget is exceeded. The cache is split into shards which each have their own lock and an equal share of the
+// budget, so that sessions being served by different workers rarely contend. Concurrent misses for the same archive
+// only get the archive from the provider once.
+type ArchiveCache struct {
+    provider ArchiveProvider
+    shards   []*cacheShard
+}
+
+type cacheShard struct {
+    // The number of bytes that the shard can hold and the number of bytes it currently holds.
+    budget int
+    size   int
+
+    // The cached archives by key, ordered from the most to the least recently used.
+    entries map[uint32]*list.Element
+    order   *list.List
+
+    // The archives that are currently being got from the provider by key.
+    calls map[uint32]*cacheCall
+
+    mutex sync.Mutex
+}
+
+type cacheEntry struct {
+    key     uint32
+    archive []byte
+}
+
+// A call to the provider for an archive that was missed. Callers that miss the same archive while the call is in
+// flight wait for its result.
+type cacheCall struct {
+    done    chan struct{}
+    archive []byte
+    err     error
+}
+
+// Creates a cache in front of a provider that holds up to a budget of bytes split over a number of shards. Archives
+// larger than the share of a shard are served without being cached.
+func NewArchiveCache(provider ArchiveProvider, budget int, shards int) (*ArchiveCache, error) {
+    if budget < 1 {
+        return nil, fmt.Errorf("file: archive cache budget must be greater than zero, got %d", budget)
+    }
+
+    if shards < 1 {
+        return nil, fmt.Errorf("file: archive cache shards must be greater than zero, got %d", shards)
+    }
+
+    cache := &ArchiveCache{
+        provider: provider,
+        shards:   make([]*cacheShard, shards),
+    }
+
+    for i := range cache.shards {
+        cache.shards[i] = &cacheShard{
+            budget:  budget / shards,
+            entries: make(map[uint32]*list.Element),
+            order:   list.New(),
+            calls:   make(map[uint32]*cacheCall),
+        }
+    }
+    return cache, nil
+}
+
+// Gets an archive from the cache, getting it from the provider if it is not cached. Implements ArchiveProvider.
+func (c *ArchiveCache) GetArchive(index uint8, id uint16) ([]byte, error) {
+    key := uint32(index)<<16 | uint32(id)
+    shard := c.shards[key%uint32(len(c.shards))]
+
+    shard.mutex.Lock()
+
+    if element, ok := shard.entries[key]; ok {
+        shard.order.MoveToFront(element)
+        shard.mutex.Unlock()
+
+        cacheHits.Inc()
+        return element.Value.(*cacheEntry).archive, nil
+    }
+
+    cacheMisses.Inc()
+
+    if call, ok := shard.calls[key]; ok {
+        shard.mutex.Unlock()
+
+        <-call.done
+        return call.archive, call.err
+    }
+
+    call := &cacheCall{done: make(chan struct{})}
+    shard.calls[key] = call
+    shard.mutex.Unlock()
+
+    call.archive, call.err = c.provider(index, id)
+
+    shard.mutex.Lock()
+    delete(shard.calls, key)
+    if call.err == nil {
+        shard.add(key, call.archive)
+    }
+    shard.mutex.Unlock()
+
+    close(call.done)
+    return call.archive, call.err
+}
+
+// Adds an archive to the shard, evicting the least recently used archives until it fits within the budget. Must be
+// called with the mutex held.
+func (s *cacheShard) add(key uint32, archive []byte) {
+    if s.budget == 0 || len(archive) > s.budget {
+        return
+    }
+
+    for s.size+len(archive) > s.budget {
+        oldest := s.order.Back()
+        entry := s.order.Remove(oldest).(*cacheEntry)
+        delete(s.entries, entry.key)
+
+        s.size -= len(entry.archive)
+        cacheBytes.Add(-int64(len(entry.archive)))
+        cacheEvictions.Inc()
+    }
+
+    s.entries[key] = s.order.PushFront(&cacheEntry{key: key, archive: archive})
+    s.size += len(archive)
+    cacheBytes.Add(int64(len(archive)))
+}
+
+// Warms the cache with every archive of the given indices. The archives of an index are listed by its manifest.
+// Archives are got in ascending order, so the first archives of an index are evicted first if the indices do not fit
+// within the budget.
+func (c *ArchiveCache) Warm(indices ...uint8) error {
+    for _, index := range indices {
+        ids, err := ListArchives(c.GetArchive, index)
+        if err != nil {
+            return err
+        }
+
+        for _, id := range ids {
+            if _, err := c.GetArchive(index, id); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// Drops every archive from the cache and stops it from caching any more archives, archives that are got afterwards
+// are got from the provider. Used to free the memory of a cache whose archives have been reloaded.
+func (c *ArchiveCache) Release() {
+    for _, shard := range c.shards {
+        shard.mutex.Lock()
+        cacheBytes.Add(-int64(shard.size))
+
+        shard.budget, shard.size = 0, 0
+        shard.entries = make(map[uint32]*list.Element)
+        shard.order.Init()
+        shard.mutex.Unlock()
+    }
+}
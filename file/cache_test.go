@@ -0,0 +1,94 @@
+package file
+
+import (
+    "sync"
+    "testing"
+)
+
+func TestArchiveCache_Evict(t *testing.T) {
+    requests := make(map[uint16]int)
+    provider := func(index uint8, id uint16) ([]byte, error) {
+        requests[id]++
+        return make([]byte, 10), nil
+    }
+
+    cache, err := NewArchiveCache(provider, 20, 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for _, id := range []uint16{1, 2, 1, 3, 1, 2} {
+        if _, err := cache.GetArchive(0, id); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    // Archive 2 is the least recently used when archive 3 is added, so it is the only archive got twice.
+    if requests[1] != 1 || requests[2] != 2 || requests[3] != 1 {
+        t.Errorf("expected the least recently used archive to be evicted, got requests %v", requests)
+    }
+}
+
+func TestArchiveCache_Singleflight(t *testing.T) {
+    entered := make(chan struct{}, 1)
+    release := make(chan struct{})
+
+    var mutex sync.Mutex
+    requests := 0
+
+    provider := func(index uint8, id uint16) ([]byte, error) {
+        mutex.Lock()
+        requests++
+        mutex.Unlock()
+
+        entered <- struct{}{}
+        <-release
+        return []byte{1}, nil
+    }
+
+    cache, err := NewArchiveCache(provider, 1024, 4)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var group sync.WaitGroup
+    get := func() {
+        defer group.Done()
+        if b, err := cache.GetArchive(2, 7); err != nil || len(b) != 1 {
+            t.Errorf("expected the archive to be got, got %v", err)
+        }
+    }
+
+    // Every caller that misses while the first miss is in flight waits for it, later callers hit the cache.
+    group.Add(1)
+    go get()
+    <-entered
+
+    for i := 0; i < 7; i++ {
+        group.Add(1)
+        go get()
+    }
+
+    close(release)
+    group.Wait()
+
+    if requests != 1 {
+        t.Errorf("expected concurrent misses to be de-duplicated, got %d requests", requests)
+    }
+}
+
+func TestArchiveCache_Oversized(t *testing.T) {
+    requests := 0
+    provider := func(index uint8, id uint16) ([]byte, error) {
+        requests++
+        return make([]byte, 100), nil
+    }
+
+    cache, _ := NewArchiveCache(provider, 64, 1)
+    _, _ = cache.GetArchive(0, 1)
+    _, _ = cache.GetArchive(0, 1)
+
+    if requests != 2 {
+        t.Errorf("expected archives larger than the budget not to be cached, got %d requests", requests)
+    }
+}
@@ -0,0 +1,156 @@
+package file
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/binary"
+    "fmt"
+    "github.com/dsnet/compress/bzip2"
+    "github.com/sprinkle-it/coffee"
+    "sync"
+)
+
+const (
+    uncompressedHeaderLength = 5
+    compressedHeaderLength   = 9
+)
+
+// The header that bzip2 streams start with for a block size of 900k. Clients prepend it to archives themselves so it
+// is stripped from compressed archives.
+var bzip2Header = []byte("BZh9")
+
+// Compresses an archive. The packed archive starts with the compression type and the length of the compressed payload,
+// compressed archives are followed by the uncompressed length. This is the layout that coffee.DecompressArchive reads.
+func CompressArchive(compression coffee.Compression, b []byte) ([]byte, error) {
+    if compression == coffee.Uncompressed {
+        packed := make([]byte, uncompressedHeaderLength+len(b))
+        packed[0] = byte(compression)
+        binary.BigEndian.PutUint32(packed[1:], uint32(len(b)))
+        copy(packed[uncompressedHeaderLength:], b)
+        return packed, nil
+    }
+
+    var payload bytes.Buffer
+    payload.Write(make([]byte, compressedHeaderLength))
+
+    switch compression {
+    case coffee.Bzip2:
+        w, err := bzip2.NewWriter(&payload, &bzip2.WriterConfig{Level: bzip2.BestCompression})
+        if err != nil {
+            return nil, err
+        }
+
+        if _, err := w.Write(b); err != nil {
+            return nil, err
+        }
+
+        if err := w.Close(); err != nil {
+            return nil, err
+        }
+    case coffee.Gzip:
+        w, err := gzip.NewWriterLevel(&payload, gzip.BestCompression)
+        if err != nil {
+            return nil, err
+        }
+
+        if _, err := w.Write(b); err != nil {
+            return nil, err
+        }
+
+        if err := w.Close(); err != nil {
+            return nil, err
+        }
+    default:
+        return nil, fmt.Errorf("file: unsupported archive compression %d", compression)
+    }
+
+    packed := payload.Bytes()
+
+    // Strip the bzip2 header by moving the archive header over it.
+    if compression == coffee.Bzip2 {
+        packed = packed[len(bzip2Header):]
+    }
+
+    packed[0] = byte(compression)
+    binary.BigEndian.PutUint32(packed[1:], uint32(len(packed)-compressedHeaderLength))
+    binary.BigEndian.PutUint32(packed[uncompressedHeaderLength:], uint32(len(b)))
+    return packed, nil
+}
+
+// Compresses the release manifest of a provider, which is generated uncompressed, before it is served and caches the
+// compressed manifest so that it is only compressed once. The manifest is only replaced when compressing it makes it
+// smaller. Every other archive is served as it is stored: clients verify the checksums of archives against the
+// manifests of their indices, so recompressing them would cause clients to reject them. The release manifest itself
+// is checked against nothing, so it is the only archive that can be compressed without regenerating checksums.
+type Compressor struct {
+    provider    ArchiveProvider
+    compression coffee.Compression
+
+    // The compressed release manifest, nil until it has been compressed.
+    releaseManifest []byte
+    mutex           sync.Mutex
+}
+
+// Creates a compressor for the release manifest of a provider.
+func NewCompressor(provider ArchiveProvider, compression coffee.Compression) (*Compressor, error) {
+    if compression != coffee.Bzip2 && compression != coffee.Gzip {
+        return nil, fmt.Errorf("file: unsupported archive compression %d", compression)
+    }
+
+    return &Compressor{
+        provider:    provider,
+        compression: compression,
+    }, nil
+}
+
+// Gets an archive, compressing it if it is the release manifest and is not already compressed. Implements
+// ArchiveProvider.
+func (c *Compressor) GetArchive(index uint8, id uint16) ([]byte, error) {
+    if index != coffee.ManifestPackage || id != coffee.ManifestPackage {
+        return c.provider(index, id)
+    }
+
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+
+    if c.releaseManifest != nil {
+        return c.releaseManifest, nil
+    }
+
+    b, err := c.provider(index, id)
+    if err != nil {
+        return nil, err
+    }
+
+    if len(b) > 0 && coffee.Compression(b[0]) == coffee.Uncompressed {
+        if b, err = c.compress(b); err != nil {
+            return nil, fmt.Errorf("file: failed to compress the release manifest: %v", err)
+        }
+    }
+
+    c.releaseManifest = b
+    return b, nil
+}
+
+// Compresses the release manifest ahead of time so that the first session to request it does not wait on it.
+func (c *Compressor) Precompress() error {
+    _, err := c.GetArchive(coffee.ManifestPackage, coffee.ManifestPackage)
+    return err
+}
+
+func (c *Compressor) compress(packed []byte) ([]byte, error) {
+    b, err := coffee.DecompressArchive(packed)
+    if err != nil {
+        return nil, err
+    }
+
+    compressed, err := CompressArchive(c.compression, b)
+    if err != nil {
+        return nil, err
+    }
+
+    if len(compressed) >= len(packed) {
+        return packed, nil
+    }
+    return compressed, nil
+}
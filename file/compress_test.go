@@ -0,0 +1,77 @@
+package file
+
+import (
+    "bytes"
+    "github.com/sprinkle-it/coffee"
+    "testing"
+)
+
+func TestCompressArchive(t *testing.T) {
+    b := bytes.Repeat([]byte("donut"), 1000)
+
+    for _, compression := range []coffee.Compression{coffee.Uncompressed, coffee.Bzip2, coffee.Gzip} {
+        packed, err := CompressArchive(compression, b)
+        if err != nil {
+            t.Fatalf("compression %d: %v", compression, err)
+        }
+
+        if coffee.Compression(packed[0]) != compression {
+            t.Errorf("compression %d: expected the archive to start with its compression, got %d", compression,
+                packed[0])
+        }
+
+        trimmed, err := coffee.TrimArchive(append(packed, 0, 1))
+        if err != nil || len(trimmed) != len(packed) {
+            t.Errorf("compression %d: expected the header to hold the length of the archive", compression)
+        }
+
+        unpacked, err := coffee.DecompressArchive(packed)
+        if err != nil {
+            t.Fatalf("compression %d: %v", compression, err)
+        }
+
+        if !bytes.Equal(unpacked, b) {
+            t.Errorf("compression %d: decompressed archive does not match", compression)
+        }
+    }
+
+    if _, err := CompressArchive(3, b); err == nil {
+        t.Error("expected an error for an unsupported compression")
+    }
+}
+
+func TestCompressor(t *testing.T) {
+    manifest, _ := CompressArchive(coffee.Uncompressed, make([]byte, 1024))
+    requests := 0
+
+    provider := func(index uint8, id uint16) ([]byte, error) {
+        requests++
+        return manifest, nil
+    }
+
+    compressor, err := NewCompressor(provider, coffee.Gzip)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if err := compressor.Precompress(); err != nil {
+        t.Fatal(err)
+    }
+
+    b, err := compressor.GetArchive(coffee.ManifestPackage, coffee.ManifestPackage)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if coffee.Compression(b[0]) != coffee.Gzip || len(b) >= len(manifest) {
+        t.Errorf("expected the release manifest to be compressed, got %d bytes of compression %d", len(b), b[0])
+    }
+
+    if requests != 1 {
+        t.Errorf("expected the compressed release manifest to be cached, got %d requests", requests)
+    }
+
+    if b, _ := compressor.GetArchive(2, 1); coffee.Compression(b[0]) != coffee.Uncompressed {
+        t.Error("expected archives other than the release manifest to be served as they are stored")
+    }
+}
@@ -0,0 +1,74 @@
+package file
+
+import (
+    "fmt"
+    "github.com/sprinkle-it/coffee"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strconv"
+)
+
+// Serves archives from a plain directory tree where each archive is a file named root/index/id.dat, which makes
+// changes to a cache easy to review. The release manifest is generated from the manifests in the tree when the
+// provider is opened, so it does not need to be kept in the tree.
+type DirectoryProvider struct {
+    root string
+
+    // The packed release manifest that was generated when the provider was opened.
+    releaseManifest []byte
+}
+
+// Opens a directory tree of archives. The indices that are served are the numbered directories of the root.
+func OpenDirectory(root string) (*DirectoryProvider, error) {
+    infos, err := ioutil.ReadDir(root)
+    if err != nil {
+        return nil, err
+    }
+
+    var indices []uint8
+    for _, info := range infos {
+        index, err := strconv.ParseUint(info.Name(), 10, 8)
+        if err != nil || !info.IsDir() || index == coffee.ManifestPackage {
+            continue
+        }
+        indices = append(indices, uint8(index))
+    }
+
+    provider := &DirectoryProvider{root: root}
+
+    provider.releaseManifest, err = CreateReleaseManifest(provider.GetArchive, indices)
+    if err != nil {
+        return nil, fmt.Errorf("file: failed to create release manifest for %s: %v", root, err)
+    }
+    return provider, nil
+}
+
+// Gets the path of the file that holds an archive in a directory tree.
+func ArchivePath(root string, index uint8, id uint16) string {
+    return filepath.Join(root, strconv.Itoa(int(index)), strconv.Itoa(int(id))+".dat")
+}
+
+// Gets an archive by reading its file. Implements ArchiveProvider.
+func (p *DirectoryProvider) GetArchive(index uint8, id uint16) ([]byte, error) {
+    if index == coffee.ManifestPackage && id == coffee.ManifestPackage && p.releaseManifest != nil {
+        return p.releaseManifest, nil
+    }
+    return ioutil.ReadFile(ArchivePath(p.root, index, id))
+}
+
+// Writes every archive that a provider serves into a directory tree that can be served by a DirectoryProvider. The
+// release manifest is not written as it is generated when the tree is opened.
+func WriteDirectory(root string, provider ArchiveProvider) error {
+    return EachArchive(provider, func(index uint8, id uint16, archive []byte) error {
+        if index == coffee.ManifestPackage && id == coffee.ManifestPackage {
+            return nil
+        }
+
+        path := ArchivePath(root, index, id)
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            return err
+        }
+        return ioutil.WriteFile(path, archive, 0644)
+    })
+}
@@ -0,0 +1,150 @@
+package file
+
+import (
+    "fmt"
+    "github.com/sprinkle-it/coffee"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// The layout of a cache on disk. Each index file holds a reference of the length and first block of every archive of
+// its index. The blocks of an archive are chained through the data file, each block starts with a header of the id of
+// the archive, the part of the archive that it holds, the next block and the index of the archive.
+const (
+    referenceLength    = 6
+    blockHeaderLength  = 8
+    blockPayloadLength = 512
+    blockLength        = blockHeaderLength + blockPayloadLength
+)
+
+// Serves archives from the data and index files of a cache on disk. Unlike coffee.Cache the provider owns the files
+// that it opens, so that a cache which has been reloaded can be closed. The release manifest is generated from the
+// manifests in the cache when it is opened. Safe to be shared by multiple go routines.
+type DiskCache struct {
+    blocks  *os.File
+    indexes map[uint8]*os.File
+
+    // The packed release manifest that was generated when the cache was opened.
+    releaseManifest []byte
+}
+
+// Opens the cache in a directory. The indices that are served are the index files in the directory other than the
+// manifest index.
+func OpenDiskCache(root string) (cache *DiskCache, err error) {
+    infos, err := ioutil.ReadDir(root)
+    if err != nil {
+        return nil, err
+    }
+
+    cache = &DiskCache{indexes: make(map[uint8]*os.File)}
+    defer func() {
+        if err != nil {
+            _ = cache.Close()
+        }
+    }()
+
+    if cache.blocks, err = os.Open(filepath.Join(root, "main_file_cache.dat2")); err != nil {
+        return nil, err
+    }
+
+    var indices []uint8
+    for _, info := range infos {
+        i := strings.Index(info.Name(), ".idx")
+        if info.IsDir() || i < 0 {
+            continue
+        }
+
+        index, err := strconv.ParseUint(info.Name()[i+len(".idx"):], 10, 8)
+        if err != nil {
+            return nil, fmt.Errorf("file: %s is not a numbered index file", info.Name())
+        }
+
+        if cache.indexes[uint8(index)], err = os.Open(filepath.Join(root, info.Name())); err != nil {
+            return nil, err
+        }
+
+        if index != coffee.ManifestPackage {
+            indices = append(indices, uint8(index))
+        }
+    }
+
+    if cache.indexes[coffee.ManifestPackage] == nil {
+        return nil, fmt.Errorf("file: %s has no manifest index", root)
+    }
+
+    cache.releaseManifest, err = CreateReleaseManifest(cache.GetArchive, indices)
+    if err != nil {
+        return nil, fmt.Errorf("file: failed to create release manifest for %s: %v", root, err)
+    }
+    return cache, nil
+}
+
+// Gets an archive by following its blocks through the data file. The version footer that caches store after an
+// archive is trimmed. Implements ArchiveProvider.
+func (c *DiskCache) GetArchive(index uint8, id uint16) ([]byte, error) {
+    if index == coffee.ManifestPackage && id == coffee.ManifestPackage && c.releaseManifest != nil {
+        return c.releaseManifest, nil
+    }
+
+    file, ok := c.indexes[index]
+    if !ok {
+        return nil, fmt.Errorf("file: index %d is not in the cache", index)
+    }
+
+    var reference [referenceLength]byte
+    if _, err := file.ReadAt(reference[:], int64(id)*referenceLength); err != nil {
+        return nil, fmt.Errorf("file: archive %d:%d is not in the cache", index, id)
+    }
+
+    length := int(reference[0])<<16 | int(reference[1])<<8 | int(reference[2])
+    block := int64(reference[3])<<16 | int64(reference[4])<<8 | int64(reference[5])
+
+    b := make([]byte, length)
+    var header [blockHeaderLength]byte
+
+    for part, offset := 0, 0; offset < length; part, offset = part+1, offset+blockPayloadLength {
+        // Block zero is never used, so it marks the end of the chain.
+        if block == 0 {
+            return nil, fmt.Errorf("file: archive %d:%d ends before its length of %d bytes", index, id, length)
+        }
+
+        end := offset + blockPayloadLength
+        if end > length {
+            end = length
+        }
+
+        if _, err := c.blocks.ReadAt(header[:], block*blockLength); err != nil {
+            return nil, fmt.Errorf("file: failed to read block %d of archive %d:%d: %v", block, index, id, err)
+        }
+
+        if _, err := c.blocks.ReadAt(b[offset:end], block*blockLength+blockHeaderLength); err != nil {
+            return nil, fmt.Errorf("file: failed to read block %d of archive %d:%d: %v", block, index, id, err)
+        }
+
+        if int(header[0])<<8|int(header[1]) != int(id) || int(header[2])<<8|int(header[3]) != part ||
+            header[7] != index {
+            return nil, fmt.Errorf("file: block %d does not hold part %d of archive %d:%d", block, part, index, id)
+        }
+
+        block = int64(header[4])<<16 | int64(header[5])<<8 | int64(header[6])
+    }
+    return coffee.TrimArchive(b)
+}
+
+// Closes the files of the cache. Archives can not be got from the cache afterwards.
+func (c *DiskCache) Close() error {
+    var err error
+    if c.blocks != nil {
+        err = c.blocks.Close()
+    }
+
+    for _, file := range c.indexes {
+        if e := file.Close(); e != nil && err == nil {
+            err = e
+        }
+    }
+    return err
+}
@@ -0,0 +1,149 @@
+package file
+
+import (
+    "bytes"
+    "fmt"
+    "github.com/sprinkle-it/coffee"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// Writes archives by key into the data and index files of a cache, returning the directory that they were written to.
+func writeDiskCache(t *testing.T, archives map[uint32][]byte) string {
+    root, err := ioutil.TempDir("", "donut-disk")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { _ = os.RemoveAll(root) })
+
+    // Block zero is never used, so the first archive starts at block one.
+    blocks := make([]byte, blockLength)
+    indexes := make(map[uint8][]byte)
+
+    for key, archive := range archives {
+        index, id := uint8(key>>16), uint16(key)
+        first := len(blocks) / blockLength
+
+        for part, offset := 0, 0; offset < len(archive); part, offset = part+1, offset+blockPayloadLength {
+            end := offset + blockPayloadLength
+            if end > len(archive) {
+                end = len(archive)
+            }
+
+            next := 0
+            if end < len(archive) {
+                next = len(blocks)/blockLength + 1
+            }
+
+            block := make([]byte, blockLength)
+            block[0], block[1], block[2], block[3] = uint8(id>>8), uint8(id), uint8(part>>8), uint8(part)
+            block[4], block[5], block[6], block[7] = uint8(next>>16), uint8(next>>8), uint8(next), index
+            copy(block[blockHeaderLength:], archive[offset:end])
+            blocks = append(blocks, block...)
+        }
+
+        b := indexes[index]
+        for len(b) < (int(id)+1)*referenceLength {
+            b = append(b, 0)
+        }
+
+        reference := b[int(id)*referenceLength:]
+        reference[0], reference[1], reference[2] = uint8(len(archive)>>16), uint8(len(archive)>>8), uint8(len(archive))
+        reference[3], reference[4], reference[5] = uint8(first>>16), uint8(first>>8), uint8(first)
+        indexes[index] = b
+    }
+
+    if err := ioutil.WriteFile(filepath.Join(root, "main_file_cache.dat2"), blocks, 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    for index, b := range indexes {
+        name := fmt.Sprintf("main_file_cache.idx%d", index)
+        if err := ioutil.WriteFile(filepath.Join(root, name), b, 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return root
+}
+
+func TestDiskCache(t *testing.T) {
+    provider := testCache(t, 0, 2)
+
+    archives := make(map[uint32][]byte)
+    err := EachArchive(provider, func(index uint8, id uint16, archive []byte) error {
+        if index != coffee.ManifestPackage || id != coffee.ManifestPackage {
+            // Archives are stored with a version footer which is trimmed when they are served.
+            archives[uint32(index)<<16|uint32(id)] = append(append([]byte(nil), archive...), 0, 7)
+        }
+        return nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // An archive that spans several blocks, which is not listed by the manifest of its index.
+    large, _ := coffee.CompressArchive(coffee.Uncompressed, bytes.Repeat([]byte("large"), 400))
+    archives[1] = append(append([]byte(nil), large...), 0, 7)
+
+    cache, err := OpenDiskCache(writeDiskCache(t, archives))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    sameArchives(t, provider, cache.GetArchive)
+
+    if b, err := cache.GetArchive(0, 1); err != nil || !bytes.Equal(b, large) {
+        t.Errorf("expected the archive that spans several blocks to be served, got %v", err)
+    }
+
+    if _, err := cache.GetArchive(2, 100); err == nil {
+        t.Error("expected an archive past the end of its index to fail")
+    }
+
+    if _, err := cache.GetArchive(3, 0); err == nil {
+        t.Error("expected an archive of an index that is not in the cache to fail")
+    }
+
+    if err := cache.Close(); err != nil {
+        t.Fatal(err)
+    }
+
+    if _, err := cache.GetArchive(0, 0); err == nil {
+        t.Error("expected getting an archive from a closed cache to fail")
+    }
+}
+
+func TestDiskCache_Corrupted(t *testing.T) {
+    archive, _ := coffee.CompressArchive(coffee.Uncompressed, make([]byte, 1000))
+    root := writeDiskCache(t, map[uint32][]byte{0: archive})
+
+    // The second block of the archive claims to hold a part of another archive.
+    path := filepath.Join(root, "main_file_cache.dat2")
+    b, err := ioutil.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    b[2*blockLength+1] = 1
+
+    if err := ioutil.WriteFile(path, b, 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    // The cache has no manifest index to be opened with, so its files are opened directly.
+    cache := &DiskCache{indexes: make(map[uint8]*os.File)}
+    defer cache.Close()
+
+    if cache.blocks, err = os.Open(path); err != nil {
+        t.Fatal(err)
+    }
+
+    if cache.indexes[0], err = os.Open(filepath.Join(root, "main_file_cache.idx0")); err != nil {
+        t.Fatal(err)
+    }
+
+    if _, err := cache.GetArchive(0, 0); err == nil {
+        t.Error("expected an archive with a block of another archive to fail")
+    }
+}
@@ -0,0 +1,288 @@
+package file
+
+import (
+    "bytes"
+    "compress/gzip"
+    "errors"
+    "fmt"
+    "github.com/sprinkle-it/coffee"
+    "github.com/sprinkle-it/donut/server"
+    "go.uber.org/zap"
+    "hash/crc32"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+type HTTPConfig struct {
+    // The shared logger, the HTTP server logs to a sub-logger named "file.http".
+    Logger *zap.Logger
+
+    // The address that archives are served over HTTP on.
+    Address string
+
+    // The file service that archives are served from. Archives are served from the same archives as the sessions of
+    // the service, including after the archives are reloaded, and share its global rate limit.
+    Service *Service
+
+    // The rate in bytes per second that each response is written at and the number of bytes that can be written at
+    // once. Not limited if the rate is zero.
+    Rate  int
+    Burst int
+
+    // How long a request can take to be read and how long its response can take to be written, so that stalled
+    // connections are closed. Responses that are limited need long enough to be written at the rate. Defaults are used
+    // if zero.
+    ReadTimeout  time.Duration
+    WriteTimeout time.Duration
+}
+
+// The timeouts that are used if none are configured.
+const (
+    defaultHTTPReadTimeout  = 10 * time.Second
+    defaultHTTPWriteTimeout = 5 * time.Minute
+)
+
+func (cfg HTTPConfig) Build() (*HTTPServer, error) {
+    if cfg.Logger == nil {
+        return nil, errors.New("file: a logger is required")
+    }
+
+    if cfg.Service == nil {
+        return nil, errors.New("file: a file service is required to serve archives over HTTP")
+    }
+
+    if cfg.ReadTimeout <= 0 {
+        cfg.ReadTimeout = defaultHTTPReadTimeout
+    }
+
+    if cfg.WriteTimeout <= 0 {
+        cfg.WriteTimeout = defaultHTTPWriteTimeout
+    }
+
+    return &HTTPServer{
+        logger:       cfg.Logger.Named("file.http"),
+        address:      cfg.Address,
+        service:      cfg.Service,
+        rate:         cfg.Rate,
+        burst:        cfg.Burst,
+        readTimeout:  cfg.ReadTimeout,
+        writeTimeout: cfg.WriteTimeout,
+    }, nil
+}
+
+// Serves archives over HTTP for launchers and web tools at /{index}/{id}, the release manifest is at /255/255.
+// Responses carry the CRC of the archive as their ETag so that unchanged archives are not fetched again, and support
+// range requests. Archives that are stored uncompressed are gzipped for clients that accept it.
+type HTTPServer struct {
+    logger       *zap.Logger
+    address      string
+    service      *Service
+    rate         int
+    burst        int
+    readTimeout  time.Duration
+    writeTimeout time.Duration
+}
+
+func NewHTTPServer(config HTTPConfig) (*HTTPServer, error) {
+    return config.Build()
+}
+
+// Listens on the configured address and serves archives. This function blocks until the listener fails.
+func (s *HTTPServer) Listen() error {
+    s.logger.Info("Serving archives over HTTP", zap.String("address", s.address))
+
+    srv := &http.Server{
+        Addr:              s.address,
+        Handler:           s,
+        ReadHeaderTimeout: s.readTimeout,
+        ReadTimeout:       s.readTimeout,
+        WriteTimeout:      s.writeTimeout,
+        IdleTimeout:       s.writeTimeout,
+    }
+    return srv.ListenAndServe()
+}
+
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        w.Header().Set("Allow", "GET, HEAD")
+        httpResponses.With(strconv.Itoa(http.StatusMethodNotAllowed)).Inc()
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    index, id, ok := parseArchivePath(r.URL.Path)
+    if !ok {
+        httpResponses.With(strconv.Itoa(http.StatusNotFound)).Inc()
+        http.NotFound(w, r)
+        return
+    }
+
+    start := time.Now()
+
+    // The snapshot is held until the response has been written, archives may be served straight from its memory.
+    snapshot := s.service.acquireSnapshot()
+    defer s.service.releaseSnapshot(snapshot)
+
+    archive, err := snapshot.provider(index, id)
+    if err != nil {
+        s.logger.Debug("Failed to get archive for HTTP request",
+            zap.Uint8("index", index),
+            zap.Uint16("id", id),
+            zap.Error(err),
+        )
+        httpResponses.With(strconv.Itoa(http.StatusNotFound)).Inc()
+        http.NotFound(w, r)
+        return
+    }
+
+    checksum := crc32.ChecksumIEEE(archive)
+    etag := fmt.Sprintf("\"%08x\"", checksum)
+
+    header := w.Header()
+    header.Set("Content-Type", "application/octet-stream")
+    header.Set("X-Archive-CRC32", strconv.FormatUint(uint64(checksum), 10))
+
+    body := archive
+    if len(archive) > 0 && coffee.Compression(archive[0]) == coffee.Uncompressed {
+        header.Set("Vary", "Accept-Encoding")
+
+        if acceptsGzip(r) {
+            if body, err = gzipBytes(archive); err != nil {
+                jobErrors.Inc()
+                httpResponses.With(strconv.Itoa(http.StatusInternalServerError)).Inc()
+                http.Error(w, "failed to compress archive", http.StatusInternalServerError)
+                return
+            }
+
+            // The gzipped body is a different representation of the archive so it has its own entity tag.
+            etag = fmt.Sprintf("\"%08x-gzip\"", checksum)
+            header.Set("Content-Encoding", "gzip")
+        }
+    }
+    header.Set("ETag", etag)
+
+    writer := &throttledWriter{
+        ResponseWriter: w,
+        limiters: []*server.TokenBucket{
+            server.NewTokenBucket(s.rate, s.burst),
+            s.service.scheduler.limiter,
+        },
+        quit: r.Context().Done(),
+    }
+
+    // Serving the content handles conditional requests and ranges against the entity tag.
+    http.ServeContent(writer, r, "", time.Time{}, bytes.NewReader(body))
+
+    if writer.err != nil {
+        if writer.err != server.ErrClosed {
+            jobErrors.Inc()
+        }
+        return
+    }
+
+    httpResponses.With(strconv.Itoa(writer.status())).Inc()
+    if writer.status() < 300 {
+        jobsServed.Inc()
+        jobDuration.ObserveSince(start)
+    }
+}
+
+// Parses the index and identifier of an archive from a path of the form /{index}/{id}.
+func parseArchivePath(path string) (uint8, uint16, bool) {
+    parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+    if len(parts) != 2 {
+        return 0, 0, false
+    }
+
+    index, err := strconv.ParseUint(parts[0], 10, 8)
+    if err != nil {
+        return 0, 0, false
+    }
+
+    id, err := strconv.ParseUint(parts[1], 10, 16)
+    if err != nil {
+        return 0, 0, false
+    }
+    return uint8(index), uint16(id), true
+}
+
+// Checks if a request accepts gzip encoded responses, gzip is not accepted if it is given a quality of zero.
+func acceptsGzip(r *http.Request) bool {
+    for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+        params := strings.Split(encoding, ";")
+        if strings.TrimSpace(params[0]) != "gzip" {
+            continue
+        }
+
+        for _, param := range params[1:] {
+            param = strings.TrimSpace(param)
+            if strings.HasPrefix(param, "q=") {
+                if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+                    return false
+                }
+            }
+        }
+        return true
+    }
+    return false
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+    var buf bytes.Buffer
+    w := gzip.NewWriter(&buf)
+    if _, err := w.Write(b); err != nil {
+        return nil, err
+    }
+
+    if err := w.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// Writes a response a chunk at a time, waiting on the rate limits before each chunk. Records the status of the
+// response and the first error that writing it failed with.
+type throttledWriter struct {
+    http.ResponseWriter
+    limiters []*server.TokenBucket
+    quit     <-chan struct{}
+    code     int
+    err      error
+}
+
+func (w *throttledWriter) WriteHeader(code int) {
+    w.code = code
+    w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *throttledWriter) Write(b []byte) (int, error) {
+    written := 0
+    for written < len(b) {
+        n := len(b) - written
+        if n > chunkLength {
+            n = chunkLength
+        }
+
+        if err := server.WaitAll(n, w.quit, w.limiters...); err != nil {
+            w.err = err
+            return written, err
+        }
+
+        n, err := w.ResponseWriter.Write(b[written : written+n])
+        written += n
+        if err != nil {
+            w.err = err
+            return written, err
+        }
+    }
+    return written, nil
+}
+
+func (w *throttledWriter) status() int {
+    if w.code == 0 {
+        return http.StatusOK
+    }
+    return w.code
+}
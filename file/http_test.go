@@ -0,0 +1,149 @@
+package file
+
+import (
+    "bytes"
+    "compress/gzip"
+    "errors"
+    "github.com/sprinkle-it/coffee"
+    "github.com/sprinkle-it/donut/message"
+    "go.uber.org/zap"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+// Creates a server for a service with the given configuration, the logger, workers and protocols are filled in.
+func newTestHTTPServer(t *testing.T, cfg Config) *HTTPServer {
+    protocols, err := message.NewRegistry(Definitions...)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    cfg.Logger, cfg.Workers, cfg.Protocols = zap.NewNop(), 1, protocols
+
+    service, err := New(cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    srv, err := NewHTTPServer(HTTPConfig{Logger: zap.NewNop(), Service: service})
+    if err != nil {
+        t.Fatal(err)
+    }
+    return srv
+}
+
+func serve(srv *HTTPServer, path string, header http.Header) *httptest.ResponseRecorder {
+    r := httptest.NewRequest(http.MethodGet, path, nil)
+    for key, values := range header {
+        r.Header[key] = values
+    }
+
+    w := httptest.NewRecorder()
+    srv.ServeHTTP(w, r)
+    return w
+}
+
+func TestHTTPServer(t *testing.T) {
+    srv := newTestHTTPServer(t, Config{ArchiveProvider: testCache(t, 0, 2)})
+
+    archive, _ := testCache(t, 0, 2)(2, 2)
+
+    w := serve(srv, "/2/2", nil)
+    if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), archive) {
+        t.Fatalf("expected the archive to be served, got %d %v", w.Code, w.Body.Bytes())
+    }
+
+    etag := w.Header().Get("ETag")
+    if etag == "" || w.Header().Get("X-Archive-CRC32") == "" {
+        t.Error("expected the CRC of the archive in the headers")
+    }
+
+    w = serve(srv, "/2/2", http.Header{"If-None-Match": {etag}})
+    if w.Code != http.StatusNotModified {
+        t.Errorf("expected an unchanged archive not to be served again, got %d", w.Code)
+    }
+
+    w = serve(srv, "/2/2", http.Header{"Range": {"bytes=1-3"}})
+    if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), archive[1:4]) {
+        t.Errorf("expected a range of the archive to be served, got %d %v", w.Code, w.Body.Bytes())
+    }
+
+    for _, path := range []string{"/1/0", "/2", "/256/0", "/2/x"} {
+        if w := serve(srv, path, nil); w.Code != http.StatusNotFound {
+            t.Errorf("expected %s not to be found, got %d", path, w.Code)
+        }
+    }
+}
+
+func TestHTTPServer_Gzip(t *testing.T) {
+    srv := newTestHTTPServer(t, Config{ArchiveProvider: testCache(t, 0)})
+
+    // The archives of the test cache are uncompressed so they are gzipped for clients that accept it.
+    w := serve(srv, "/0/0", http.Header{"Accept-Encoding": {"gzip, deflate"}})
+    if w.Header().Get("Content-Encoding") != "gzip" {
+        t.Fatal("expected an uncompressed archive to be gzipped")
+    }
+
+    r, err := gzip.NewReader(w.Body)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    b, err := ioutil.ReadAll(r)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if coffee.Compression(b[0]) != coffee.Uncompressed || string(b[5:]) != "archive 0:0" {
+        t.Errorf("expected the gzipped body to be the archive, got %v", b)
+    }
+
+    w = serve(srv, "/0/0", http.Header{"Accept-Encoding": {"gzip;q=0"}})
+    if w.Header().Get("Content-Encoding") != "" {
+        t.Error("expected the archive not to be gzipped when gzip is refused")
+    }
+}
+
+func TestHTTPServer_Reload(t *testing.T) {
+    archive := []byte{0, 0, 0, 0, 1, 2}
+    opened := make(chan struct{})
+    served := make(chan struct{})
+    released := make(chan struct{})
+
+    srv := newTestHTTPServer(t, Config{
+        ArchiveProvider: func(uint8, uint16) ([]byte, error) {
+            close(opened)
+            <-served
+            return archive, nil
+        },
+        ReleaseArchives: func() { close(released) },
+    })
+    srv.service.Process()
+
+    done := make(chan *httptest.ResponseRecorder)
+    go func() { done <- serve(srv, "/0/0", nil) }()
+
+    // The archives are reloaded while the request is being served from them.
+    <-opened
+    srv.service.Reload(func(uint8, uint16) ([]byte, error) { return nil, errors.New("reloaded") }, nil)
+
+    select {
+    case <-released:
+        t.Fatal("expected the previous archives not to be released while a request is served from them")
+    default:
+    }
+
+    close(served)
+    if w := <-done; w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), archive) {
+        t.Errorf("expected the request to be served from the previous archives, got %d %v", w.Code, w.Body.Bytes())
+    }
+
+    select {
+    case <-released:
+    case <-time.After(5 * time.Second):
+        t.Error("expected the previous archives to be released once the request was served")
+    }
+}
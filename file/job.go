@@ -1,8 +1,8 @@
 package file
 
 import (
-    "github.com/sprinkle-it/donut/buffer"
     "github.com/sprinkle-it/donut/server"
+    "time"
 )
 
 var (
@@ -16,71 +16,149 @@ var (
     chunkLength = 2048
 )
 
-// Type declaration for channels which are used to queue jobs for workers.
-type JobQueue chan Job
+// The parts that an archive is written to a session in.
+type piece int
+
+const (
+    // The index and identifier of the archive.
+    pieceHeader piece = iota
+
+    // The end of chunk byte that is written before every chunk but the first.
+    pieceEndOfChunk
+
+    // A chunk of the archive.
+    pieceChunk
+
+    // The archive has been written and the session needs to be flushed.
+    pieceFlush
+)
 
 // A job serves an archive to a session. Archives are served by first writing the archive we are writing, then
-// chunks of the archive thereafter until the entire archive is written to the session.
+// chunks of the archive thereafter until the entire archive is written to the session. Jobs are written a piece at a
+// time so that workers can interleave the chunks of many sessions, and are only used by the worker that is currently
+// serving their session.
 type Job struct {
-    session *Session
     request Request
-    done    chan error
-}
 
-func NewJob(session *Session, request Request) Job {
-    return Job{
-        session: session,
-        request: request,
-        done:    session.done,
-    }
+    // Whether the job is for a priority request. Passive jobs are halted at a chunk boundary when the session has a
+    // priority request queued, the end of chunk byte is not written which causes the client to drop the request.
+    priority bool
+
+    // The archive being written, the offset of the next chunk and whether its end of chunk byte has been written.
+    archive []byte
+    offset  int
+    marked  bool
+
+    // Whether the header has been written.
+    started bool
+
+    // The next piece of the job and its bytes. Bytes are kept until the client accepts them so that the same bytes
+    // are retried when the client is busy.
+    next    piece
+    pending []byte
+
+    // The key that the bytes written by the job are XORed with, zero if they are not encrypted.
+    key uint8
+
+    // The header and end of chunk byte of the job, encrypted with the key. Bytes are queued by the client rather than
+    // copied straight away, so every slice that the job writes must be left unmodified.
+    header [3]byte
+    marker [1]byte
+
+    writer server.FlushWriter
+    start  time.Time
 }
 
-func (j Job) Execute(worker *Worker) {
-    bytes, err := worker.archiveProvider(j.request.Index, j.request.Id)
-    if err != nil {
-        j.done <- err
-        return
+// Creates a job for a request of a session. Priority jobs are allowed the priority burst of the session.
+func NewJob(session *Session, request Request, priority bool) *Job {
+    job := &Job{
+        request:  request,
+        priority: priority,
+        key:      session.EncryptionKey(),
+        writer: server.FlushWriter{
+            Client:   session.Client,
+            Limiters: []*server.TokenBucket{session.limiter, session.scheduler.limiter},
+            Wake:     session.writable,
+        },
+        start: time.Now(),
     }
 
-    writer := server.FlushWriter{Client: j.session.Client}
+    job.header = [3]byte{request.Index, uint8(request.Id >> 8), uint8(request.Id)}
+    job.marker = [1]byte{endOfChunk[0]}
+    encrypt(job.header[:], job.header[:], job.key)
+    encrypt(job.marker[:], job.marker[:], job.key)
 
-    buf := buffer.ByteBuffer{Bytes: worker.buffer[:]}
+    if priority {
+        job.writer.Burst = session.priorityBurst
+    }
+    return job
+}
 
-    _ = buf.PutUint8(j.request.Index)
-    _ = buf.PutUint16(j.request.Id)
+// Gets the archive of the job from the provider. Archives are shared so the archive is copied if it is encrypted.
+func (j *Job) open(provider ArchiveProvider) error {
+    archive, err := provider(j.request.Index, j.request.Id)
+    if err != nil {
+        return err
+    }
 
-    if err := writer.Write(worker.buffer[:buf.Offset]); err != nil {
-        j.done <- err
-        return
+    if j.key != 0 {
+        encrypted := make([]byte, len(archive))
+        encrypt(encrypted, archive, j.key)
+        archive = encrypted
     }
 
-    offset := 0
+    j.archive = archive
+    return nil
+}
 
-    for offset < len(bytes) {
-        if offset > 0 {
-            if err := writer.Write(endOfChunk); err != nil {
-                j.done <- err
-                return
-            }
-        }
+// Gets the next piece of the job and the bytes to write for it.
+func (j *Job) piece() (piece, []byte) {
+    if j.pending != nil {
+        return j.next, j.pending
+    }
 
-        write := len(bytes) - offset
+    var b []byte
+    switch {
+    case !j.started:
+        j.next, b = pieceHeader, j.header[:]
+    case j.offset >= len(j.archive):
+        return pieceFlush, nil
+    case j.offset > 0 && !j.marked:
+        j.next, b = pieceEndOfChunk, j.marker[:]
+    default:
+        write := len(j.archive) - j.offset
         if write > chunkLength {
             write = chunkLength
         }
+        j.next, b = pieceChunk, j.archive[j.offset:j.offset+write]
+    }
 
-        if err := writer.Write(bytes[offset : offset+write]); err != nil {
-            j.done <- err
-            return
-        }
+    j.pending = b
+    return j.next, b
+}
 
-        offset += write
+// Advances the job past the pending piece once the client has accepted it.
+func (j *Job) wrote() {
+    switch j.next {
+    case pieceHeader:
+        j.started = true
+    case pieceEndOfChunk:
+        j.marked = true
+    case pieceChunk:
+        j.offset += len(j.pending)
+        j.marked = false
     }
+    j.pending = nil
+}
 
-    if err := j.session.Flush(); err != nil {
-        j.done <- err
+// XORs the bytes of src with a key into dst, which may be src.
+func encrypt(dst, src []byte, key uint8) {
+    if key == 0 {
+        copy(dst, src)
         return
     }
 
-    j.done <- nil
+    for i := range src {
+        dst[i] = src[i] ^ key
+    }
 }
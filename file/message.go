@@ -7,33 +7,60 @@ import (
 
 var (
     passiveRequestConfig = message.Config{
-        Id:   0,
-        Size: 3,
-        New:  func() message.Message { return &PassiveRequest{} },
+        Id:        0,
+        Size:      3,
+        Direction: message.DirectionInbound,
+        New:       func() message.Message { return &PassiveRequest{} },
     }
 
     priorityRequestConfig = message.Config{
-        Id:   1,
-        Size: 3,
-        New:  func() message.Message { return &PriorityRequest{} },
+        Id:        1,
+        Size:      3,
+        Direction: message.DirectionInbound,
+        New:       func() message.Message { return &PriorityRequest{} },
     }
 
     onlineStatusUpdateConfig = message.Config{
-        Id:   2,
-        Size: 3,
-        New:  message.Singleton(OnlineStatusUpdate),
+        Id:        2,
+        Size:      3,
+        Direction: message.DirectionInbound,
+        New:       message.Singleton(OnlineStatusUpdate),
     }
 
     offlineStatusUpdateConfig = message.Config{
-        Id:   3,
-        Size: 3,
-        New:  message.Singleton(OfflineStatusUpdate),
+        Id:        3,
+        Size:      3,
+        Direction: message.DirectionInbound,
+        New:       message.Singleton(OfflineStatusUpdate),
+    }
+
+    encryptionKeyUpdateConfig = message.Config{
+        Id:        4,
+        Size:      3,
+        Direction: message.DirectionInbound,
+        New:       func() message.Message { return &EncryptionKeyUpdate{} },
     }
 
     handshakeConfig = message.Config{
-        Id:   15,
-        Size: 4,
-        New:  func() message.Message { return &Handshake{} },
+        Id:        15,
+        Size:      4,
+        Direction: message.DirectionInbound,
+        New:       func() message.Message { return &Handshake{} },
+    }
+
+    // All of the messages that the file service accepts.
+    inbound = []message.Config{
+        passiveRequestConfig,
+        priorityRequestConfig,
+        onlineStatusUpdateConfig,
+        offlineStatusUpdateConfig,
+        encryptionKeyUpdateConfig,
+        handshakeConfig,
+    }
+
+    // The messages of the file service for each supported client revision.
+    Definitions = []message.Definition{
+        {Revision: 177, Inbound: inbound},
     }
 )
 
@@ -43,6 +70,8 @@ type Handshake struct {
 
 func (Handshake) Config() message.Config { return handshakeConfig }
 
+func (h Handshake) Revision() uint32 { return h.Version }
+
 func (h *Handshake) Decode(buf *buffer.ByteBuffer, length int) error {
     var err error
     if h.Version, err = buf.GetUint32(); err != nil {
@@ -108,4 +137,22 @@ type offlineStatusUpdate struct{}
 
 func (offlineStatusUpdate) Config() message.Config { return offlineStatusUpdateConfig }
 
-func (offlineStatusUpdate) Decode(buf *buffer.ByteBuffer, length int) error { return nil }
\ No newline at end of file
+func (offlineStatusUpdate) Decode(buf *buffer.ByteBuffer, length int) error { return nil }
+
+// Sets the key that every byte written to the client after it is XORed with. A key of zero disables encryption.
+type EncryptionKeyUpdate struct {
+    Key uint8
+}
+
+func (EncryptionKeyUpdate) Config() message.Config { return encryptionKeyUpdateConfig }
+
+func (u *EncryptionKeyUpdate) Decode(buf *buffer.ByteBuffer, length int) error {
+    var err error
+
+    if u.Key, err = buf.GetUint8(); err != nil {
+        return err
+    }
+
+    // The key is followed by two unused bytes.
+    return buf.Skip(2)
+}
@@ -0,0 +1,76 @@
+package file
+
+import (
+    "github.com/sprinkle-it/donut/buffer"
+    "github.com/sprinkle-it/donut/message"
+    "github.com/sprinkle-it/donut/message/messagetest"
+    "reflect"
+    "testing"
+)
+
+// Decodes a single framed inbound message.
+func decode(t *testing.T, frame []byte) message.Message {
+    configs := make(map[uint8]message.Config)
+    for _, config := range inbound {
+        configs[config.Id] = config
+    }
+
+    input := buffer.NewRingBuffer(len(frame))
+    _, _ = input.Write(frame)
+
+    decoder := message.NewStreamDecoder(configs, 16)
+
+    msg, err := decoder.Decode(&input)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if msg == nil || buffer.HasReadable(&input) {
+        t.Fatalf("expected %v to be decoded as a single message", frame)
+    }
+    return msg
+}
+
+func TestDefinitions(t *testing.T) {
+    if _, err := message.NewRegistry(Definitions...); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestInbound_Decode(t *testing.T) {
+    tests := map[string]struct {
+        frame    []byte
+        expected message.Message
+    }{
+        "passive request":       {[]byte{0, 2, 0x01, 0x2c}, &PassiveRequest{Request{Index: 2, Id: 300}}},
+        "priority request":      {[]byte{1, 255, 0, 7}, &PriorityRequest{Request{Index: 255, Id: 7}}},
+        "online status update":  {[]byte{2, 0, 0, 0}, OnlineStatusUpdate},
+        "offline status update": {[]byte{3, 0, 0, 0}, OfflineStatusUpdate},
+        "encryption key update": {[]byte{4, 0xff, 0, 0}, &EncryptionKeyUpdate{Key: 0xff}},
+        "handshake":             {[]byte{15, 0, 0, 0, 177}, &Handshake{Version: 177}},
+    }
+
+    for name, test := range tests {
+        t.Run(name, func(t *testing.T) {
+            if msg := decode(t, test.frame); !reflect.DeepEqual(msg, test.expected) {
+                t.Errorf("expected %#v, got %#v", test.expected, msg)
+            }
+        })
+    }
+}
+
+func TestEncryptionKeyUpdate_Decode(t *testing.T) {
+    // The key is followed by two unused bytes.
+    msg := decode(t, []byte{4, 0x5a, 0, 0})
+
+    if update, ok := msg.(*EncryptionKeyUpdate); !ok || update.Key != 0x5a {
+        t.Errorf("expected an encryption key update with a key of 0x5a, got %#v", msg)
+    }
+}
+
+func FuzzInbound_Decode(f *testing.F) {
+    messagetest.Seed(f, inbound)
+    f.Fuzz(func(t *testing.T, index uint8, payload []byte) {
+        messagetest.Decode(t, inbound, index, payload)
+    })
+}
@@ -0,0 +1,87 @@
+package file
+
+import "github.com/sprinkle-it/donut/metrics"
+
+var (
+    jobsServed = metrics.NewCounter(metrics.Opts{
+        Name: "donut_file_jobs_served_total",
+        Help: "Number of archives that have been entirely written to sessions.",
+    })
+
+    jobErrors = metrics.NewCounter(metrics.Opts{
+        Name: "donut_file_job_errors_total",
+        Help: "Number of jobs that failed to serve an archive to a session.",
+    })
+
+    jobsPreempted = metrics.NewCounter(metrics.Opts{
+        Name: "donut_file_jobs_preempted_total",
+        Help: "Number of passive jobs that were halted to serve a priority request and were requeued.",
+    })
+
+    sessionsReady = metrics.NewGauge(metrics.Opts{
+        Name: "donut_file_sessions_ready",
+        Help: "Number of sessions that are waiting for a worker to serve them.",
+    })
+
+    sessionsParked = metrics.NewGauge(metrics.Opts{
+        Name: "donut_file_sessions_parked",
+        Help: "Number of sessions that are waiting on a slow client, a rate limit or the online passive rate.",
+    })
+
+    workerBusyTime = metrics.NewCounter(metrics.Opts{
+        Name: "donut_file_worker_busy_microseconds_total",
+        Help: "Time that workers have spent serving sessions. The rate divided by donut_file_workers is the " +
+            "utilisation of the workers.",
+    })
+
+    jobDuration = metrics.NewHistogram(metrics.Opts{
+        Name: "donut_file_job_duration_seconds",
+        Help: "Time from a request being taken until its archive has been entirely written to the session.",
+    }, metrics.DefaultBuckets)
+
+    httpResponses = metrics.NewCounterVec(metrics.Opts{
+        Name: "donut_file_http_responses_total",
+        Help: "Number of responses to requests for archives over HTTP by status code. Archives that are served in " +
+            "full are also counted by donut_file_jobs_served_total.",
+    }, "code")
+
+    reloads = metrics.NewCounter(metrics.Opts{
+        Name: "donut_file_reloads_total",
+        Help: "Number of times that the archives were reloaded.",
+    })
+
+    sessionsReconnected = metrics.NewCounter(metrics.Opts{
+        Name: "donut_file_sessions_reconnected_total",
+        Help: "Number of sessions that were closed so that their client reconnects after the archives were reloaded.",
+    })
+
+    cacheHits = metrics.NewCounter(metrics.Opts{
+        Name: "donut_file_cache_hits_total",
+        Help: "Number of archives that were served from the archive cache.",
+    })
+
+    cacheMisses = metrics.NewCounter(metrics.Opts{
+        Name: "donut_file_cache_misses_total",
+        Help: "Number of archives that were not in the archive cache, including misses that waited on another miss.",
+    })
+
+    cacheEvictions = metrics.NewCounter(metrics.Opts{
+        Name: "donut_file_cache_evictions_total",
+        Help: "Number of archives that were evicted from the archive cache to stay within its budget.",
+    })
+
+    cacheBytes = metrics.NewGauge(metrics.Opts{
+        Name: "donut_file_cache_bytes",
+        Help: "Number of bytes of archives that are held by the archive cache.",
+    })
+
+    workersTotal = metrics.NewGauge(metrics.Opts{
+        Name: "donut_file_workers",
+        Help: "Number of workers that serve sessions.",
+    })
+
+    workersBusy = metrics.NewGauge(metrics.Opts{
+        Name: "donut_file_workers_busy",
+        Help: "Number of workers that are currently serving a session. Saturated when equal to donut_file_workers.",
+    })
+)
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package file
+
+import (
+    "os"
+    "syscall"
+)
+
+// Maps a file into memory read only.
+func mapFile(f *os.File, size int) ([]byte, error) {
+    return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func unmapFile(b []byte) error {
+    return syscall.Munmap(b)
+}
@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package file
+
+import (
+    "io"
+    "os"
+)
+
+// Reads a file into memory, files are not mapped on Windows.
+func mapFile(f *os.File, size int) ([]byte, error) {
+    b := make([]byte, size)
+    if _, err := io.ReadFull(f, b); err != nil {
+        return nil, err
+    }
+    return b, nil
+}
+
+func unmapFile(b []byte) error {
+    return nil
+}
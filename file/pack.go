@@ -0,0 +1,211 @@
+package file
+
+import (
+    "bufio"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "os"
+    "sort"
+)
+
+// Pack files hold every archive of a cache in a single file that is memory mapped when it is served. A pack file
+// starts with a header of the magic, the format version, the number of archives and the offset of the table of
+// archives. The archives follow the header and the table follows the archives, it has an entry of the key, offset and
+// length of each archive sorted by key. Every number is big endian.
+var packMagic = []byte("DPAK")
+
+const (
+    packVersion = 1
+
+    packHeaderLength = 17
+    packEntryLength  = 16
+)
+
+// Serves archives from a memory mapped pack file. Archives are served straight from the mapping without being copied.
+// Safe to be shared by multiple go routines.
+type PackFile struct {
+    data  []byte
+    table []byte
+}
+
+// Opens a pack file and maps it into memory.
+func OpenPackFile(path string) (*PackFile, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    info, err := f.Stat()
+    if err != nil {
+        return nil, err
+    }
+
+    if info.Size() < packHeaderLength {
+        return nil, fmt.Errorf("file: %s is too short to be a pack file", path)
+    }
+
+    data, err := mapFile(f, int(info.Size()))
+    if err != nil {
+        return nil, err
+    }
+
+    pack, err := readPack(data)
+    if err != nil {
+        _ = unmapFile(data)
+        return nil, fmt.Errorf("file: %s: %v", path, err)
+    }
+    return pack, nil
+}
+
+// Reads the header and table of a pack file, checking that every archive is within the file.
+func readPack(data []byte) (*PackFile, error) {
+    if string(data[:len(packMagic)]) != string(packMagic) {
+        return nil, errors.New("not a pack file")
+    }
+
+    if version := data[4]; version != packVersion {
+        return nil, fmt.Errorf("unsupported pack file version %d", version)
+    }
+
+    count := uint64(binary.BigEndian.Uint32(data[5:]))
+    offset := binary.BigEndian.Uint64(data[9:])
+
+    if offset < packHeaderLength || offset > uint64(len(data)) || uint64(len(data))-offset != count*packEntryLength {
+        return nil, errors.New("pack file table is out of bounds")
+    }
+
+    pack := &PackFile{data: data, table: data[offset:]}
+    for i := 0; i < int(count); i++ {
+        entry := pack.entry(i)
+
+        key := binary.BigEndian.Uint32(entry)
+        if i > 0 && key <= binary.BigEndian.Uint32(pack.entry(i-1)) {
+            return nil, errors.New("pack file table is not sorted")
+        }
+
+        start, length := binary.BigEndian.Uint64(entry[4:]), uint64(binary.BigEndian.Uint32(entry[12:]))
+        // Checked without adding the start and length as their sum can overflow.
+        if start < packHeaderLength || length > offset || start > offset-length {
+            return nil, fmt.Errorf("archive %d:%d is out of bounds", key>>16, uint16(key))
+        }
+    }
+    return pack, nil
+}
+
+func (p *PackFile) entry(i int) []byte {
+    return p.table[i*packEntryLength : (i+1)*packEntryLength]
+}
+
+// Gets the number of archives in the pack file.
+func (p *PackFile) Len() int {
+    return len(p.table) / packEntryLength
+}
+
+// Gets an archive from the mapping. The archive must not be modified. Implements ArchiveProvider.
+func (p *PackFile) GetArchive(index uint8, id uint16) ([]byte, error) {
+    key := uint32(index)<<16 | uint32(id)
+
+    count := p.Len()
+    i := sort.Search(count, func(i int) bool { return binary.BigEndian.Uint32(p.entry(i)) >= key })
+    if i == count || binary.BigEndian.Uint32(p.entry(i)) != key {
+        return nil, fmt.Errorf("file: archive %d:%d is not in the pack file", index, id)
+    }
+
+    entry := p.entry(i)
+    start := binary.BigEndian.Uint64(entry[4:])
+    end := start + uint64(binary.BigEndian.Uint32(entry[12:]))
+    return p.data[start:end:end], nil
+}
+
+// Unmaps the pack file. Archives that were got from the pack file must not be used afterwards.
+func (p *PackFile) Close() error {
+    return unmapFile(p.data)
+}
+
+// Writes every archive that a provider serves into a pack file. The pack file is written next to the path and then
+// renamed over it, so a pack file that is being served or watched is never seen half written.
+func WritePackFile(path string, provider ArchiveProvider) (err error) {
+    f, err := os.Create(path + ".tmp")
+    if err != nil {
+        return err
+    }
+
+    defer func() {
+        if err != nil {
+            _ = f.Close()
+            _ = os.Remove(f.Name())
+        }
+    }()
+
+    w := bufio.NewWriter(f)
+    if _, err := w.Write(make([]byte, packHeaderLength)); err != nil {
+        return err
+    }
+
+    var table []byte
+    offset := uint64(packHeaderLength)
+
+    err = EachArchive(provider, func(index uint8, id uint16, archive []byte) error {
+        if _, err := w.Write(archive); err != nil {
+            return err
+        }
+
+        var entry [packEntryLength]byte
+        binary.BigEndian.PutUint32(entry[:], uint32(index)<<16|uint32(id))
+        binary.BigEndian.PutUint64(entry[4:], offset)
+        binary.BigEndian.PutUint32(entry[12:], uint32(len(archive)))
+        table = append(table, entry[:]...)
+
+        offset += uint64(len(archive))
+        return nil
+    })
+    if err != nil {
+        return err
+    }
+
+    // Archives are visited index by index with the manifests first, the table is sorted by key for lookups.
+    count := len(table) / packEntryLength
+    sort.Sort(packTable(table))
+
+    if _, err := w.Write(table); err != nil {
+        return err
+    }
+
+    if err := w.Flush(); err != nil {
+        return err
+    }
+
+    header := make([]byte, packHeaderLength)
+    copy(header, packMagic)
+    header[4] = packVersion
+    binary.BigEndian.PutUint32(header[5:], uint32(count))
+    binary.BigEndian.PutUint64(header[9:], offset)
+
+    if _, err := f.WriteAt(header, 0); err != nil {
+        return err
+    }
+
+    if err := f.Close(); err != nil {
+        return err
+    }
+    return os.Rename(f.Name(), path)
+}
+
+// Sorts the entries of a pack file table by key.
+type packTable []byte
+
+func (t packTable) Len() int { return len(t) / packEntryLength }
+
+func (t packTable) Less(i, j int) bool {
+    return binary.BigEndian.Uint32(t[i*packEntryLength:]) < binary.BigEndian.Uint32(t[j*packEntryLength:])
+}
+
+func (t packTable) Swap(i, j int) {
+    var entry [packEntryLength]byte
+    a, b := t[i*packEntryLength:(i+1)*packEntryLength], t[j*packEntryLength:(j+1)*packEntryLength]
+    copy(entry[:], a)
+    copy(a, b)
+    copy(b, entry[:])
+}
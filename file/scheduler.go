@@ -0,0 +1,276 @@
+package file
+
+import (
+    "github.com/sprinkle-it/donut/server"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// The classes that sessions are scheduled in. Sessions with a priority request to serve are always scheduled before
+// sessions that only have passive requests, sessions within a class are served round robin.
+const (
+    classPriority = iota
+    classPassive
+    classes
+)
+
+// Returned by a session as its wait when its client was too busy to accept bytes, such as when it is flushing to a
+// slow connection. The session is parked until the client wakes it once it can accept bytes again.
+const untilWritable time.Duration = -1
+
+// The state of a session in the scheduler.
+type sessionState int
+
+const (
+    // The session has nothing to serve.
+    sessionIdle sessionState = iota
+
+    // The session is waiting to be served by a worker.
+    sessionReady
+
+    // The session is being served by a worker.
+    sessionRunning
+
+    // The session is waiting for a timer or for its client before it can be served again.
+    sessionParked
+)
+
+// The archives that sessions are served from. Sessions are served from the snapshot that was current when they were
+// created, so that the archives they are served match the release manifest that they were served.
+type snapshot struct {
+    provider ArchiveProvider
+
+    // The number of requests being served from the snapshot outside of a session, such as over HTTP. Accessed
+    // atomically.
+    references int32
+}
+
+// Schedules sessions onto a bounded pool of workers. Workers serve up to a chunk of a session at a time before moving
+// on to the next session, so that many sessions are interleaved fairly and a session whose client is slow to accept
+// bytes is parked rather than holding on to a worker.
+type Scheduler struct {
+    workers int
+
+    // The snapshot that new sessions are served from. Holds a *snapshot.
+    current atomic.Value
+
+    // Limits the rate that archives are written at across every session, nil if it is not limited.
+    limiter *server.TokenBucket
+
+    // The sessions that are waiting to be served by class. Administered by the mutex, as are the states of sessions.
+    ready [classes][]*Session
+    busy  int
+    mutex sync.Mutex
+    cond  *sync.Cond
+}
+
+func NewScheduler(workers int, provider ArchiveProvider, limiter *server.TokenBucket) *Scheduler {
+    scheduler := &Scheduler{
+        workers: workers,
+        limiter: limiter,
+    }
+    scheduler.current.Store(&snapshot{provider: provider})
+    scheduler.cond = sync.NewCond(&scheduler.mutex)
+    return scheduler
+}
+
+// Starts the workers.
+func (s *Scheduler) Process() {
+    for i := 0; i < s.workers; i++ {
+        workersTotal.Inc()
+        go func() {
+            for {
+                session := s.next()
+
+                start := time.Now()
+                workersBusy.Inc()
+                wait, idle := session.serve()
+                workersBusy.Dec()
+                workerBusyTime.Add(uint64(time.Since(start) / time.Microsecond))
+
+                s.done(session, wait, idle)
+            }
+        }()
+    }
+}
+
+// Gets the snapshot that new sessions are served from.
+func (s *Scheduler) snapshot() *snapshot {
+    return s.current.Load().(*snapshot)
+}
+
+// Replaces the snapshot that new sessions are served from, returning the snapshot that was replaced.
+func (s *Scheduler) swap(next *snapshot) *snapshot {
+    previous := s.snapshot()
+    s.current.Store(next)
+    return previous
+}
+
+// Gets the number of workers that are currently waiting for a session to serve.
+func (s *Scheduler) Idle() int {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    return s.workers - s.busy
+}
+
+// Schedules a session that has something to serve. Sessions that are already scheduled are left as they are, except
+// sessions that are throttled by a limiter which are woken for a priority request, so that priority requests are not
+// held back by the limiters of passive bytes. Passive requests never cut the wait of a throttled session short.
+func (s *Scheduler) schedule(session *Session) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    switch session.state {
+    case sessionIdle:
+        if session.pending() {
+            s.push(session)
+        }
+    case sessionParked:
+        if session.throttled && session.priorityQueued() {
+            s.push(session)
+        }
+    }
+}
+
+// Schedules a session whose wait no longer applies, such as a session that was throttled while its client was online
+// which went offline. Throttled sessions are woken straight away.
+func (s *Scheduler) resume(session *Session) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    switch session.state {
+    case sessionIdle:
+        if session.pending() {
+            s.push(session)
+        }
+    case sessionParked:
+        if session.throttled {
+            s.push(session)
+        }
+    }
+}
+
+// Adds a session to the back of the queue of its class and wakes a worker.
+func (s *Scheduler) push(session *Session) {
+    if session.state == sessionParked {
+        sessionsParked.Dec()
+    }
+
+    // Invalidates the timer of a parked session.
+    session.generation++
+    session.state = sessionReady
+    session.woken = false
+
+    class := session.class()
+    s.ready[class] = append(s.ready[class], session)
+    sessionsReady.Inc()
+    s.cond.Signal()
+}
+
+// Waits for the next session to serve, taking from the highest class first.
+func (s *Scheduler) next() *Session {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    for {
+        for class := range s.ready {
+            if queue := s.ready[class]; len(queue) > 0 {
+                session := queue[0]
+                queue[0] = nil
+                s.ready[class] = queue[1:]
+
+                sessionsReady.Dec()
+                session.state = sessionRunning
+                s.busy++
+                return session
+            }
+        }
+        s.cond.Wait()
+    }
+}
+
+// Wakes a session whose client can accept bytes again. Sessions that are still being served are pushed once the
+// worker is done with them, as their client may have been woken before they were parked. Sessions that are throttled
+// by a limiter are left to their timer.
+func (s *Scheduler) wake(session *Session) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    switch session.state {
+    case sessionParked:
+        if !session.throttled {
+            s.push(session)
+        }
+    case sessionRunning:
+        session.woken = true
+    }
+}
+
+// Waits until no worker is serving a session. Closed sessions are not served again once the worker serving them is
+// done, so the archives of a closed session are no longer read once this returns.
+func (s *Scheduler) stop(session *Session) {
+    s.mutex.Lock()
+    if session.state != sessionRunning {
+        s.mutex.Unlock()
+        return
+    }
+
+    if session.stopped == nil {
+        session.stopped = make(chan struct{})
+    }
+    stopped := session.stopped
+    s.mutex.Unlock()
+
+    <-stopped
+}
+
+// Reschedules a session after a worker has served it. Sessions that have to wait are parked until their wait is over
+// or until their client wakes them, idle sessions are only rescheduled if a request arrived while they were being
+// served.
+func (s *Scheduler) done(session *Session, wait time.Duration, idle bool) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    s.busy--
+
+    if session.stopped != nil {
+        close(session.stopped)
+        session.stopped = nil
+    }
+
+    switch {
+    case session.Closed():
+        session.state = sessionIdle
+    case idle:
+        session.state = sessionIdle
+        if session.pending() {
+            s.push(session)
+        }
+    case wait == untilWritable:
+        if session.woken {
+            s.push(session)
+            return
+        }
+
+        session.state = sessionParked
+        session.throttled = false
+        sessionsParked.Inc()
+    case wait > 0:
+        session.state = sessionParked
+        session.throttled = true
+        sessionsParked.Inc()
+
+        generation := session.generation
+        time.AfterFunc(wait, func() {
+            s.mutex.Lock()
+            defer s.mutex.Unlock()
+
+            if session.state == sessionParked && session.generation == generation {
+                s.push(session)
+            }
+        })
+    default:
+        s.push(session)
+    }
+}
@@ -0,0 +1,472 @@
+package file
+
+import (
+    "bytes"
+    "github.com/sprinkle-it/donut/server"
+    "go.uber.org/zap"
+    "io"
+    "net"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// Creates a session for a client connected over a pipe, returning the session and the remote end of the pipe.
+func pipeSession(t *testing.T, scheduler *Scheduler) (*Session, net.Conn) {
+    return pipeSessionConfig(t, scheduler, SessionConfig{PriorityRequestCapacity: 4, PassiveRequestCapacity: 4})
+}
+
+// Creates a session with the given configuration for a client connected over a pipe.
+func pipeSessionConfig(t *testing.T, scheduler *Scheduler, sessionConfig SessionConfig) (*Session, net.Conn) {
+    local, remote := net.Pipe()
+    t.Cleanup(func() { _ = remote.Close() })
+
+    cfg := server.NewDefaultClientConfig()
+    client := cfg.Build(local, zap.NewNop(), server.MailRouter{})
+    client.Process()
+    t.Cleanup(func() { client.Close() })
+
+    return sessionConfig.Build(client, scheduler), remote
+}
+
+// Gets the bytes that an archive is expected to be written to a session as.
+func expectedArchive(request Request, archive []byte, key uint8) []byte {
+    expected := []byte{request.Index, uint8(request.Id >> 8), uint8(request.Id)}
+    for offset := 0; offset < len(archive); offset += chunkLength {
+        if offset > 0 {
+            expected = append(expected, endOfChunk...)
+        }
+
+        end := offset + chunkLength
+        if end > len(archive) {
+            end = len(archive)
+        }
+        expected = append(expected, archive[offset:end]...)
+    }
+
+    for i := range expected {
+        expected[i] ^= key
+    }
+    return expected
+}
+
+func readArchive(t *testing.T, conn net.Conn, length int) []byte {
+    _ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+    b := make([]byte, length)
+    if _, err := io.ReadFull(conn, b); err != nil {
+        t.Fatal(err)
+    }
+    return b
+}
+
+func TestScheduler_Encrypted(t *testing.T) {
+    archive := bytes.Repeat([]byte{1, 2, 3, 4, 5}, 1000)
+    provider := func(uint8, uint16) ([]byte, error) { return archive, nil }
+
+    scheduler := NewScheduler(1, provider, nil)
+    scheduler.Process()
+
+    session, remote := pipeSession(t, scheduler)
+    session.SetEncryptionKey(0x5a)
+
+    request := Request{Index: 2, Id: 300}
+    session.enqueuePassive(request)
+
+    expected := expectedArchive(request, archive, 0x5a)
+    if !bytes.Equal(readArchive(t, remote, len(expected)), expected) {
+        t.Error("expected the archive to be written in encrypted chunks")
+    }
+}
+
+func TestScheduler_EncryptionKeyChange(t *testing.T) {
+    archive := bytes.Repeat([]byte{1, 2, 3, 4, 5}, 1000)
+
+    opened := make(chan struct{})
+    release := make(chan struct{})
+    var once sync.Once
+
+    provider := func(uint8, uint16) ([]byte, error) {
+        once.Do(func() {
+            close(opened)
+            <-release
+        })
+        return archive, nil
+    }
+
+    scheduler := NewScheduler(1, provider, nil)
+    scheduler.Process()
+
+    session, remote := pipeSession(t, scheduler)
+
+    first, second := Request{Index: 2, Id: 1}, Request{Index: 2, Id: 2}
+    session.enqueuePassive(first)
+
+    // The key changes while the first job is being served, only jobs created afterwards are encrypted with it.
+    <-opened
+    session.SetEncryptionKey(0x5a)
+    session.enqueuePassive(second)
+    close(release)
+
+    expected := append(expectedArchive(first, archive, 0), expectedArchive(second, archive, 0x5a)...)
+    if !bytes.Equal(readArchive(t, remote, len(expected)), expected) {
+        t.Error("expected the key to only apply to the job created after it changed")
+    }
+}
+
+func TestScheduler_SlowClient(t *testing.T) {
+    large := make([]byte, 50000)
+    small := []byte{9, 8, 7}
+
+    provider := func(index uint8, id uint16) ([]byte, error) {
+        if index == 1 {
+            return large, nil
+        }
+        return small, nil
+    }
+
+    scheduler := NewScheduler(1, provider, nil)
+    scheduler.Process()
+
+    slow, slowRemote := pipeSession(t, scheduler)
+    fast, fastRemote := pipeSession(t, scheduler)
+
+    // The slow client does not read until the fast client has been served, with a single worker the fast client is
+    // only served if the worker is not held up by the slow client.
+    slow.enqueuePassive(Request{Index: 1, Id: 1})
+    fast.enqueuePriority(Request{Index: 2, Id: 1})
+
+    expected := expectedArchive(Request{Index: 2, Id: 1}, small, 0)
+    if !bytes.Equal(readArchive(t, fastRemote, len(expected)), expected) {
+        t.Error("expected the archive of the fast client to be written")
+    }
+
+    expected = expectedArchive(Request{Index: 1, Id: 1}, large, 0)
+    if !bytes.Equal(readArchive(t, slowRemote, len(expected)), expected) {
+        t.Error("expected the archive of the slow client to be written")
+    }
+}
+
+func TestScheduler_Preempt(t *testing.T) {
+    passive := bytes.Repeat([]byte{1, 2, 3}, 2000)
+    priority := []byte{4, 5, 6}
+
+    opened := make(chan struct{})
+    release := make(chan struct{})
+    var once sync.Once
+
+    provider := func(index uint8, id uint16) ([]byte, error) {
+        if index == 2 {
+            return priority, nil
+        }
+
+        // The priority request is queued while the first chunk of the passive archive is being served.
+        once.Do(func() {
+            close(opened)
+            <-release
+        })
+        return passive, nil
+    }
+
+    scheduler := NewScheduler(1, provider, nil)
+    scheduler.Process()
+
+    session, remote := pipeSession(t, scheduler)
+
+    passiveRequest, priorityRequest := Request{Index: 1, Id: 1}, Request{Index: 2, Id: 1}
+    session.enqueuePassive(passiveRequest)
+
+    <-opened
+    session.enqueuePriority(priorityRequest)
+    close(release)
+
+    // The passive archive is halted at the end of its first chunk without the end of chunk byte, which makes the
+    // client drop it, and is served again from the start once the priority archive has been served.
+    passiveExpected := expectedArchive(passiveRequest, passive, 0)
+    expected := append([]byte(nil), passiveExpected[:3+chunkLength]...)
+    expected = append(expected, expectedArchive(priorityRequest, priority, 0)...)
+    expected = append(expected, passiveExpected...)
+
+    if b := readArchive(t, remote, len(expected)); !bytes.Equal(b, expected) {
+        t.Error("expected the passive archive to be halted by the priority archive and then served again")
+    }
+}
+
+func TestScheduler_Reload(t *testing.T) {
+    old := bytes.Repeat([]byte{1}, 5000)
+    opened := make(chan struct{})
+    release := make(chan struct{})
+
+    provider := func(uint8, uint16) ([]byte, error) {
+        close(opened)
+        <-release
+        return old, nil
+    }
+
+    scheduler := NewScheduler(1, provider, nil)
+    scheduler.Process()
+
+    session, remote := pipeSession(t, scheduler)
+
+    request := Request{Index: 3, Id: 1}
+    session.enqueuePassive(request)
+    session.enqueuePassive(Request{Index: 3, Id: 2})
+
+    // The archives are reloaded while the first request is being served.
+    <-opened
+    scheduler.swap(&snapshot{provider: func(uint8, uint16) ([]byte, error) { return []byte{2}, nil }})
+    scheduler.schedule(session)
+    close(release)
+
+    expected := expectedArchive(request, old, 0)
+    if !bytes.Equal(readArchive(t, remote, len(expected)), expected) {
+        t.Error("expected the job in flight to finish on the previous archives")
+    }
+
+    _ = remote.SetReadDeadline(time.Now().Add(5 * time.Second))
+    if n, err := remote.Read(make([]byte, 1)); err != io.EOF {
+        t.Errorf("expected the stale session to be closed, read %d bytes with error %v", n, err)
+    }
+}
+
+func TestScheduler_Throughput(t *testing.T) {
+    archive := make([]byte, 1<<20)
+    provider := func(uint8, uint16) ([]byte, error) { return archive, nil }
+
+    scheduler := NewScheduler(1, provider, nil)
+    scheduler.Process()
+
+    session, remote := pipeSession(t, scheduler)
+
+    request := Request{Index: 1, Id: 1}
+    session.enqueuePassive(request)
+
+    // A client that reads as fast as it can must not be held back by the scheduler, at 10 milliseconds a chunk a
+    // mebibyte takes over 5 seconds.
+    start := time.Now()
+    expected := expectedArchive(request, archive, 0)
+    if !bytes.Equal(readArchive(t, remote, len(expected)), expected) {
+        t.Fatal("expected the archive to be written")
+    }
+
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Errorf("expected a mebibyte to be served within a second, took %v", elapsed)
+    }
+}
+
+func TestScheduler_Stop(t *testing.T) {
+    opened := make(chan struct{})
+    release := make(chan struct{})
+
+    provider := func(uint8, uint16) ([]byte, error) {
+        close(opened)
+        <-release
+        return []byte{1}, nil
+    }
+
+    scheduler := NewScheduler(1, provider, nil)
+    scheduler.Process()
+
+    session, _ := pipeSession(t, scheduler)
+    session.enqueuePassive(Request{Index: 1, Id: 1})
+
+    // The session is closed while a worker is reading its archive.
+    <-opened
+    session.Close()
+
+    stopped := make(chan struct{})
+    go func() {
+        scheduler.stop(session)
+        close(stopped)
+    }()
+
+    select {
+    case <-stopped:
+        t.Fatal("expected stop to wait for the worker that is serving the session")
+    case <-time.After(50 * time.Millisecond):
+    }
+
+    close(release)
+
+    select {
+    case <-stopped:
+    case <-time.After(5 * time.Second):
+        t.Error("expected stop to return once the worker was done with the session")
+    }
+}
+
+func TestScheduler_ThrottledRequests(t *testing.T) {
+    archive := make([]byte, 2*chunkLength)
+    provider := func(uint8, uint16) ([]byte, error) { return archive, nil }
+
+    scheduler := NewScheduler(1, provider, nil)
+    scheduler.Process()
+
+    session, remote := pipeSessionConfig(t, scheduler, SessionConfig{
+        PriorityRequestCapacity: 4,
+        PassiveRequestCapacity:  128,
+        Rate:                    4 * chunkLength,
+        Burst:                   chunkLength,
+    })
+
+    var received int64
+    go func() {
+        b := make([]byte, chunkLength)
+        for {
+            n, err := remote.Read(b)
+            if err != nil {
+                return
+            }
+            atomic.AddInt64(&received, int64(n))
+        }
+    }()
+
+    // Requests keep arriving while the session is throttled, none of them may let bytes out ahead of the limiter.
+    start := time.Now()
+    for i := 0; i < 100; i++ {
+        session.enqueuePassive(Request{Index: 1, Id: uint16(i)})
+        time.Sleep(10 * time.Millisecond)
+    }
+
+    // The burst and the rate over the elapsed time, plus the chunk that is written before the session waits for it
+    // and the headers and markers of the archives.
+    elapsed := time.Since(start).Seconds()
+    limit := int64(float64(4*chunkLength)*elapsed) + 3*int64(chunkLength)
+
+    if n := atomic.LoadInt64(&received); n > limit {
+        t.Errorf("expected at most %d bytes to be written in %.1f seconds at the rate, got %d", limit, elapsed, n)
+    }
+}
+
+func TestScheduler_Paced(t *testing.T) {
+    archive := make([]byte, 8*chunkLength)
+    provider := func(uint8, uint16) ([]byte, error) { return archive, nil }
+
+    scheduler := NewScheduler(1, provider, nil)
+    scheduler.Process()
+
+    session, remote := pipeSessionConfig(t, scheduler, SessionConfig{
+        PriorityRequestCapacity: 4,
+        PassiveRequestCapacity:  4,
+        Rate:                    8 * chunkLength,
+        Burst:                   chunkLength,
+    })
+
+    request := Request{Index: 1, Id: 1}
+    session.enqueuePassive(request)
+
+    // Records when each byte of the archive arrived.
+    expected := expectedArchive(request, archive, 0)
+    arrived := make([]time.Duration, 0, len(expected))
+
+    start := time.Now()
+    _ = remote.SetReadDeadline(start.Add(5 * time.Second))
+
+    b := make([]byte, chunkLength)
+    for len(arrived) < len(expected) {
+        n, err := remote.Read(b)
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        for i := 0; i < n; i++ {
+            arrived = append(arrived, time.Since(start))
+        }
+    }
+
+    // The offset of the first byte of a chunk, after the header of the archive and the markers of earlier chunks.
+    offset := func(chunk int) int { return 3 + chunk*(chunkLength+len(endOfChunk)) }
+
+    // At the rate a chunk is written every 125 milliseconds, so the bytes must arrive as they are written rather than
+    // in a burst once the output buffer of the client fills up or the archive ends.
+    if third := arrived[offset(2)]; third > 250*time.Millisecond {
+        t.Errorf("expected the first chunks to arrive straight away, the third chunk arrived after %v", third)
+    }
+
+    for chunk := 3; chunk < 8; chunk++ {
+        if gap := arrived[offset(chunk)] - arrived[offset(chunk-1)]; gap > 250*time.Millisecond {
+            t.Errorf("expected chunk %d to arrive within 250ms of the previous chunk, took %v", chunk, gap)
+        }
+    }
+
+    if last := arrived[len(arrived)-1]; last < 600*time.Millisecond {
+        t.Errorf("expected the archive to be paced at the rate, the last byte arrived after %v", last)
+    }
+}
+
+func TestScheduler_OnlinePassiveRate(t *testing.T) {
+    archive := make([]byte, 5*chunkLength)
+    provider := func(uint8, uint16) ([]byte, error) { return archive, nil }
+
+    scheduler := NewScheduler(1, provider, nil)
+    scheduler.Process()
+
+    session, remote := pipeSessionConfig(t, scheduler, SessionConfig{
+        PriorityRequestCapacity: 4,
+        PassiveRequestCapacity:  4,
+        OnlinePassiveRate:       8 * chunkLength,
+    })
+    session.SetOnline(true)
+
+    // Priority requests are not throttled while the client is online.
+    request := Request{Index: 1, Id: 1}
+    session.enqueuePriority(request)
+
+    start := time.Now()
+    expected := expectedArchive(request, archive, 0)
+    if !bytes.Equal(readArchive(t, remote, len(expected)), expected) {
+        t.Fatal("expected the priority archive to be written")
+    }
+
+    if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+        t.Errorf("expected the priority archive not to be throttled, took %v", elapsed)
+    }
+
+    // The chunks of a passive archive are paced at the rate, the first two chunks are written straight away as the
+    // first is allowed as a burst and the limiter is only waited on after writing.
+    session.enqueuePassive(request)
+
+    start = time.Now()
+    if !bytes.Equal(readArchive(t, remote, len(expected)), expected) {
+        t.Fatal("expected the passive archive to be written")
+    }
+
+    if elapsed := time.Since(start); elapsed < 350*time.Millisecond {
+        t.Errorf("expected the passive archive to be paced at the online passive rate, took %v", elapsed)
+    }
+}
+
+func TestScheduler_SetOnline(t *testing.T) {
+    archive := make([]byte, 5*chunkLength)
+    provider := func(uint8, uint16) ([]byte, error) { return archive, nil }
+
+    scheduler := NewScheduler(1, provider, nil)
+    scheduler.Process()
+
+    session, remote := pipeSessionConfig(t, scheduler, SessionConfig{
+        PriorityRequestCapacity: 4,
+        PassiveRequestCapacity:  4,
+        OnlinePassiveRate:       chunkLength / 10,
+    })
+    session.SetOnline(true)
+
+    request := Request{Index: 1, Id: 1}
+    session.enqueuePassive(request)
+
+    // At the rate the archive would take 40 seconds, the session is parked after the first chunk until the client
+    // goes offline which reschedules it straight away.
+    time.Sleep(50 * time.Millisecond)
+
+    start := time.Now()
+    session.SetOnline(false)
+
+    expected := expectedArchive(request, archive, 0)
+    if !bytes.Equal(readArchive(t, remote, len(expected)), expected) {
+        t.Fatal("expected the passive archive to be written")
+    }
+
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Errorf("expected the passive archive to be served once the client went offline, took %v", elapsed)
+    }
+}
@@ -6,34 +6,54 @@ import (
     "github.com/sprinkle-it/donut/server"
     "github.com/sprinkle-it/donut/status"
     "go.uber.org/zap"
+    "sync/atomic"
 )
 
+// The stage clients are in once they have been registered to the file service.
+const Stage server.Stage = "file"
+
 type ArchiveProvider func(uint8, uint16) ([]byte, error)
 
 type Config struct {
-    LoggerConfig     zap.Config
+    // The shared logger, the service logs to a sub-logger named "file".
+    Logger           *zap.Logger
     Capacity         int
     Workers          int
-    SupportedVersion uint32
     ArchiveProvider  ArchiveProvider
     SessionConfig    SessionConfig
+
+    // Called once no session is served from the archive provider after the archives have been reloaded, such as to
+    // free the memory that the provider holds. Optional.
+    ReleaseArchives func()
+
+    // The rate in bytes per second that archives are written at across every session and the number of bytes that
+    // can be written at once. Not limited if the rate is zero.
+    Rate  int
+    Burst int
+
+    // The protocols of the client revisions that are supported. Clients that connect with any other revision are
+    // rejected.
+    Protocols *message.Registry
 }
 
 func (cfg Config) Build() (*Service, error) {
-    logger, err := cfg.LoggerConfig.Build()
-    if err != nil {
-        return nil, err
+    if cfg.Logger == nil {
+        return nil, errors.New("file: a logger is required")
+    }
+
+    if cfg.Protocols == nil {
+        return nil, errors.New("file: protocols are required")
     }
 
     return &Service{
-        logger:          logger,
-        capacity:        cfg.Capacity,
-        commands:        make(chan command),
-        sessions:        make(map[uint64]*Session, cfg.Capacity),
-        newSession:      cfg.SessionConfig.Build,
-        version:         cfg.SupportedVersion,
-        workers:         make(WorkerPool, cfg.Workers),
-        archiveProvider: cfg.ArchiveProvider,
+        logger:     cfg.Logger.Named("file"),
+        capacity:   cfg.Capacity,
+        commands:   make(chan command),
+        sessions:   make(map[uint64]*Session, cfg.Capacity),
+        newSession: cfg.SessionConfig.Build,
+        protocols:  cfg.Protocols,
+        scheduler:  NewScheduler(cfg.Workers, cfg.ArchiveProvider, server.NewTokenBucket(cfg.Rate, cfg.Burst)),
+        release:    cfg.ReleaseArchives,
     }, nil
 }
 
@@ -50,13 +70,20 @@ type Service struct {
     // Factory function to create new sessions for this service.
     newSession SessionFactory
 
-    // The client version that the service supports. If a client attempts to connect to this service with any other
-    // version then the service will reply with a status message of UnsupportedVersion and close the client.
-    version uint32
+    // The protocols of the client revisions that the service supports. If a client attempts to connect to this service
+    // with any other revision then the service will reply with a status message of UnsupportedVersion.
+    protocols *message.Registry
+
+    // Schedules the sessions onto the workers that serve archives.
+    scheduler *Scheduler
+
+    // Releases the archives of the current snapshot once they have been replaced, nil if there is nothing to release.
+    release func()
+
+    // The snapshots that have been replaced but still have sessions that are served from them.
+    retired []retiredSnapshot
 
-    archiveProvider ArchiveProvider
-    commands        chan command
-    workers         WorkerPool
+    commands chan command
 }
 
 func New(config Config) (*Service, error) {
@@ -65,28 +92,73 @@ func New(config Config) (*Service, error) {
 
 func (s *Service) execute(cmd command) { s.commands <- cmd }
 
+// A point in time view of a session for inspection.
+type SessionInfo struct {
+    Id             uint64
+    Address        string
+    PriorityQueued int
+    PassiveQueued  int
+    Online         bool
+
+    // Whether the session is served from archives that have since been reloaded. Stale sessions are closed once they
+    // have finished the archive they are serving.
+    Stale bool
+}
+
+// Gets information about each of the sessions that are currently registered to the service.
+func (s *Service) Sessions() []SessionInfo {
+    reply := make(chan []SessionInfo, 1)
+    s.execute(listSessions{reply: reply})
+    return <-reply
+}
+
+// Replaces the archives that sessions are served from. Sessions that are serving an archive finish serving it from the
+// previous archives and are then closed so that their clients reconnect, new sessions are served from the new
+// archives. The release function is called once no session is served from the new archives after they are replaced in
+// turn, it may be nil.
+func (s *Service) Reload(provider ArchiveProvider, release func()) {
+    done := make(chan struct{})
+    s.execute(reloadArchives{provider: provider, release: release, done: done})
+    <-done
+}
+
+// Acquires the current snapshot for a request that is served outside of a session, such as over HTTP. The snapshot is
+// not released while it is held, it must be given back with releaseSnapshot once the request has been served.
+func (s *Service) acquireSnapshot() *snapshot {
+    for {
+        current := s.scheduler.snapshot()
+        atomic.AddInt32(&current.references, 1)
+
+        // The snapshot may have been retired and released before the reference was taken, in which case the
+        // reference is given back and the new snapshot is acquired instead.
+        if current == s.scheduler.snapshot() {
+            return current
+        }
+        s.releaseSnapshot(current)
+    }
+}
+
+// Gives back a snapshot that was acquired, releasing it if it has been retired and nothing else is using it.
+func (s *Service) releaseSnapshot(snapshot *snapshot) {
+    if atomic.AddInt32(&snapshot.references, -1) == 0 && snapshot != s.scheduler.snapshot() {
+        s.execute(releaseSnapshots{})
+    }
+}
+
+// Gets the number of workers that are currently waiting for a session to serve.
+func (s *Service) IdleWorkers() int {
+    return s.scheduler.Idle()
+}
+
 func (s *Service) MailReceiver() server.MailReceiver {
     return server.MailReceiver {
         Handler: s.handleMail,
-        Accept: []message.Config{
-            passiveRequestConfig,
-            priorityRequestConfig,
-            onlineStatusUpdateConfig,
-            offlineStatusUpdateConfig,
-            handshakeConfig,
-        },
+        Accept:  inbound,
     }
 }
 
 func (s *Service) Process() {
-    for i := 0; i < cap(s.workers); i++ {
-        worker := Worker{
-            pool:            s.workers,
-            jobs:            make(JobQueue),
-            archiveProvider: s.archiveProvider,
-        }
-        worker.Process()
-    }
+    s.scheduler.Process()
 
     go func() {
         for command := range s.commands {
@@ -111,7 +183,7 @@ func (c handleMessage) execute(s *Service) {
     source := c.mail.Source
     switch msg := c.mail.Message.(type) {
     case *Handshake:
-        if msg.Version != s.version {
+        if !s.protocols.Supports(msg.Version) {
             _ = source.SendNow(status.UnsupportedVersion)
             return
         }
@@ -121,11 +193,16 @@ func (c handleMessage) execute(s *Service) {
             return
         }
 
-        session := s.newSession(source, s.workers)
+        session := s.newSession(source, s.scheduler)
         s.sessions[source.Id()] = session
+        source.SetStage(Stage)
 
-        session.OnClosed(func(cli *server.Client) { s.execute(unregisterSession{cli: cli}) })
-        session.Process()
+        session.OnClosed(func(cli *server.Client) {
+            // The session is only unregistered once its archives are no longer read, so that they can be released.
+            <-cli.OutputStopped()
+            s.scheduler.stop(session)
+            s.execute(unregisterSession{cli: cli})
+        })
 
         session.Info("Registered client to file service")
 
@@ -144,6 +221,22 @@ func (c handleMessage) execute(s *Service) {
             return
         }
         session.enqueuePriority(msg.Request)
+    case onlineStatusUpdate, offlineStatusUpdate:
+        session, exists := s.sessions[source.Id()]
+        if !exists {
+            source.Fatal(errors.New("fileservice: received status update from client that does not have active " +
+                "session"))
+            return
+        }
+        session.SetOnline(msg == OnlineStatusUpdate)
+    case *EncryptionKeyUpdate:
+        session, exists := s.sessions[source.Id()]
+        if !exists {
+            source.Fatal(errors.New("fileservice: received encryption key from client that does not have active " +
+                "session"))
+            return
+        }
+        session.SetEncryptionKey(msg.Key)
     }
 }
 
@@ -154,4 +247,89 @@ type unregisterSession struct {
 func (cmd unregisterSession) execute(service *Service) {
     delete(service.sessions, cmd.cli.Id())
     cmd.cli.Info("Unregistered file session")
+
+    service.releaseRetired()
+}
+
+// A snapshot that has been replaced and the function that releases it.
+type retiredSnapshot struct {
+    snapshot *snapshot
+    release  func()
+}
+
+type reloadArchives struct {
+    provider ArchiveProvider
+    release  func()
+    done     chan<- struct{}
+}
+
+func (cmd reloadArchives) execute(service *Service) {
+    defer close(cmd.done)
+
+    previous := service.scheduler.swap(&snapshot{provider: cmd.provider})
+    service.retired = append(service.retired, retiredSnapshot{snapshot: previous, release: service.release})
+    service.release = cmd.release
+
+    // Stale sessions are scheduled so that idle sessions are closed straight away rather than on their next request.
+    for _, session := range service.sessions {
+        service.scheduler.schedule(session)
+    }
+
+    reloads.Inc()
+    service.logger.Info("Reloaded archives", zap.Int("sessions", len(service.sessions)))
+
+    service.releaseRetired()
+}
+
+type releaseSnapshots struct{}
+
+func (cmd releaseSnapshots) execute(service *Service) {
+    service.releaseRetired()
+}
+
+// Releases the retired snapshots that no session or request is served from anymore.
+func (s *Service) releaseRetired() {
+    retired := s.retired[:0]
+    for _, r := range s.retired {
+        used := atomic.LoadInt32(&r.snapshot.references) > 0
+        for _, session := range s.sessions {
+            if session.snapshot == r.snapshot {
+                used = true
+                break
+            }
+        }
+
+        if used {
+            retired = append(retired, r)
+            continue
+        }
+
+        if r.release != nil {
+            r.release()
+        }
+    }
+
+    for i := len(retired); i < len(s.retired); i++ {
+        s.retired[i] = retiredSnapshot{}
+    }
+    s.retired = retired
+}
+
+type listSessions struct {
+    reply chan<- []SessionInfo
+}
+
+func (cmd listSessions) execute(service *Service) {
+    sessions := make([]SessionInfo, 0, len(service.sessions))
+    for _, session := range service.sessions {
+        sessions = append(sessions, SessionInfo{
+            Id:             session.Id(),
+            Address:        session.RemoteAddress().String(),
+            PriorityQueued: len(session.priority),
+            PassiveQueued:  len(session.passive),
+            Online:         session.Online(),
+            Stale:          session.stale(),
+        })
+    }
+    cmd.reply <- sessions
 }
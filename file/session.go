@@ -3,22 +3,42 @@ package file
 import (
     "errors"
     "github.com/sprinkle-it/donut/server"
+    "sync/atomic"
+    "time"
 )
 
-type SessionFactory func(*server.Client, WorkerPool) *Session
+type SessionFactory func(*server.Client, *Scheduler) *Session
 
 type SessionConfig struct {
     PriorityRequestCapacity int
     PassiveRequestCapacity  int
+
+    // The rate in bytes per second that passive requests are served at while the client is logged in to the game, so
+    // that downloads do not compete with game traffic. Passive requests are not throttled if zero. Priority requests
+    // and clients that are at the login screen are always served at full throughput. Passive bytes are paced a chunk
+    // at a time.
+    OnlinePassiveRate int
+
+    // The rate in bytes per second that archives are written to each session at and the number of bytes that can be
+    // written at once. Sessions are not limited if the rate is zero.
+    Rate  int
+    Burst int
+
+    // The number of bytes of each priority request that are written without waiting on the limiters, so that
+    // archives that clients critically need are not held back by passive requests.
+    PriorityBurst int
 }
 
-func (cfg SessionConfig) Build(cli *server.Client, workers WorkerPool) *Session {
+func (cfg SessionConfig) Build(cli *server.Client, scheduler *Scheduler) *Session {
     return &Session{
-        Client:   cli,
-        workers:  workers,
-        priority: make(chan Request, cfg.PassiveRequestCapacity),
-        passive:  make(chan Request, cfg.PassiveRequestCapacity),
-        done:     make(chan error, 1),
+        Client:            cli,
+        scheduler:         scheduler,
+        snapshot:          scheduler.snapshot(),
+        priority:          make(chan Request, cfg.PriorityRequestCapacity),
+        passive:           make(chan Request, cfg.PassiveRequestCapacity),
+        onlineLimiter:     server.NewTokenBucket(cfg.OnlinePassiveRate, chunkLength),
+        limiter:           server.NewTokenBucket(cfg.Rate, cfg.Burst),
+        priorityBurst:     cfg.PriorityBurst,
     }
 }
 
@@ -28,8 +48,24 @@ func (cfg SessionConfig) Build(cli *server.Client, workers WorkerPool) *Session
 type Session struct {
     *server.Client
 
-    // The pool of workers that the session can utilize to submit jobs.
-    workers WorkerPool
+    // The scheduler that serves the session on its workers.
+    scheduler *Scheduler
+
+    // The archives that the session is served from. Once the scheduler has moved on to a newer snapshot the session
+    // finishes the job it is serving and is closed, so that its client reconnects and is served the new release
+    // manifest. Whether the session is being closed is only used by the worker that is serving the session.
+    snapshot     *snapshot
+    reconnecting bool
+
+    // The state of the session in the scheduler, the number of times it has been scheduled, which invalidates the
+    // timers of parked sessions, and whether its client was woken while it was being served. Throttled is whether a
+    // parked session is waiting on a limiter rather than on its client. Stopped is closed once the worker that is
+    // serving the session is done with it, if something is waiting for it. Administered by the mutex of the scheduler.
+    state      sessionState
+    generation uint64
+    woken      bool
+    throttled  bool
+    stopped    chan struct{}
 
     // The queue of priority requests that the session has received. Priority requests are for archives that are
     // critically needed in order for the client to function and should be handled before passive requests.
@@ -39,36 +75,84 @@ type Session struct {
     // critically needed at the present time but will be needed eventually.
     passive chan Request
 
-    // Channel used to signal when a job has been completed.
-    done chan error
+    // The job that is being served, nil if the session is between jobs. The fields from here on are only used by the
+    // worker that is serving the session.
+    job *Job
+
+    // A passive request that was preempted by a priority request. The client drops requests that are halted so it is
+    // served again from the start once the queued priority requests have been served.
+    preempted   Request
+    interrupted bool
+
+    // Whether the client is logged in to the game, set from the status updates that the client sends. Accessed
+    // atomically as it is set by the service and read by the session.
+    online int32
+
+    // The key that bytes written to the client are XORed with, zero if they are not encrypted. Accessed atomically as
+    // it is set by the service and read when jobs are created.
+    encryptionKey uint32
+
+    // Limits the rate that passive requests are served at while the client is online, nil if passive requests are
+    // not throttled.
+    onlineLimiter *server.TokenBucket
+
+    // Limits the rate that archives are written to the session at, nil if the session is not limited.
+    limiter *server.TokenBucket
+
+    // The number of bytes of each priority request that are written without waiting on the limiters.
+    priorityBurst int
 }
 
-func (s *Session) Process() {
-    go func() {
-        for {
-            // Attempt to select from the priority requests so we can assure that the client is receiving files that it
-            // immediately needs. If the session has been closed just return.
-            select {
-            case <-s.Quit():
-                return
-            case request := <-s.priority:
-                s.submit(request)
-                continue
-            default:
-                // No priority requests currently.
-            }
+// Sets whether the client is logged in to the game. A passive request that was held back while the client was online
+// is served straight away once it goes offline.
+func (s *Session) SetOnline(online bool) {
+    var v int32
+    if online {
+        v = 1
+    }
+    atomic.StoreInt32(&s.online, v)
 
-            // If there was no priority request take either a request from the passive or priority queue.
-            select {
-            case <-s.Quit():
-                return
-            case request := <-s.priority:
-                s.submit(request)
-            case request := <-s.passive:
-                s.submit(request)
-            }
-        }
-    }()
+    if !online {
+        s.scheduler.resume(s)
+    }
+}
+
+// Sets the key that bytes written to the client by jobs created after this call are XORed with.
+func (s *Session) SetEncryptionKey(key uint8) {
+    atomic.StoreUint32(&s.encryptionKey, uint32(key))
+}
+
+// Gets the key that bytes written to the client are XORed with, zero if they are not encrypted.
+func (s *Session) EncryptionKey() uint8 {
+    return uint8(atomic.LoadUint32(&s.encryptionKey))
+}
+
+// Checks if the client is logged in to the game.
+func (s *Session) Online() bool {
+    return atomic.LoadInt32(&s.online) == 1
+}
+
+// Checks if the archives have been reloaded since the session was created.
+func (s *Session) stale() bool {
+    return s.snapshot != s.scheduler.snapshot()
+}
+
+// Checks if there is a priority request waiting to be served.
+func (s *Session) priorityQueued() bool {
+    return len(s.priority) > 0
+}
+
+// Checks if the session has anything to serve. Must only be called while the session is not being served.
+func (s *Session) pending() bool {
+    return s.job != nil || s.interrupted || len(s.priority) > 0 || len(s.passive) > 0 || s.stale() && !s.reconnecting
+}
+
+// Gets the class that the session is scheduled in. Must only be called while the session is not being served.
+func (s *Session) class() int {
+    if s.priorityQueued() || s.job != nil && s.job.priority {
+        return classPriority
+    }
+    return classPassive
 }
 
 // Enqueues a request to the priority queue.
@@ -77,29 +161,164 @@ func (s *Session) enqueuePriority(request Request) { s.enqueue(request, s.priori
 // Enqueues a request to the passive queue.
 func (s *Session) enqueuePassive(request Request) { s.enqueue(request, s.passive) }
 
-// Enqueues a request to the provided channel. If the channel cannot immediately accept the request then the session
-// will be closed with a fatal error.
+// Enqueues a request to the provided channel and schedules the session. If the channel cannot immediately accept the
+// request then the session will be closed with a fatal error.
 func (s *Session) enqueue(request Request, queue chan Request) {
     select {
     case queue <- request:
+        s.scheduler.schedule(s)
     default:
         s.Fatal(errors.New("file: request queue is full"))
     }
 }
 
-// Submits a request to a worker.
-func (s *Session) submit(request Request) {
-    select {
-    case <-s.Quit():
-        return
-    case worker := <-s.workers:
-        worker <- NewJob(s, request)
-
-        select {
-        case err := <-s.done:
-            if err != nil && err != server.ErrClosed {
-                s.Fatal(err)
+// Serves the session for a turn of a worker, writing up to a chunk of bytes. Returns how long the session has to wait
+// before it is served again, or if it has nothing left to serve.
+func (s *Session) serve() (time.Duration, bool) {
+    written := 0
+    for written < chunkLength {
+        if s.Closed() {
+            return 0, true
+        }
+
+        if s.job == nil {
+            if s.reconnecting {
+                return 0, true
+            }
+
+            if s.stale() {
+                return s.reconnect()
+            }
+
+            request, priority, ok := s.nextRequest()
+            if !ok {
+                return 0, true
+            }
+
+            s.job = NewJob(s, request, priority)
+            if err := s.job.open(s.snapshot.provider); err != nil {
+                s.fail(err)
+                return 0, true
+            }
+        }
+
+        piece, b := s.job.piece()
+        switch piece {
+        case pieceFlush:
+            ok, err := s.job.writer.TryFlush()
+            if err != nil {
+                s.fail(err)
+                return 0, true
+            }
+
+            if !ok {
+                return untilWritable, false
             }
+
+            jobsServed.Inc()
+            jobDuration.ObserveSince(s.job.start)
+            s.finish()
+            continue
+        case pieceEndOfChunk:
+            if !s.job.priority && s.priorityQueued() {
+                jobsPreempted.Inc()
+                s.preempted, s.interrupted = s.job.request, true
+                s.finish()
+                continue
+            }
+        }
+
+        ok, wait, err := s.job.writer.TryWrite(b)
+        if err != nil {
+            s.fail(err)
+            return 0, true
+        }
+
+        if !ok {
+            return untilWritable, false
         }
+
+        s.job.wrote()
+        written += len(b)
+
+        // Passive bytes are paced while the client is online, the limiter is not touched while it is offline so
+        // that bytes written at full throughput do not hold back the session once the client logs in.
+        if !s.job.priority && s.Online() {
+            if delay := s.onlineLimiter.Reserve(len(b)); delay > wait {
+                wait = delay
+            }
+        }
+
+        if wait > 0 {
+            // The bytes written so far are flushed before waiting, so that they reach the client at the pace of the
+            // limiters rather than in a burst once the output buffer fills up.
+            if _, err := s.job.writer.TryFlush(); err != nil {
+                s.fail(err)
+                return 0, true
+            }
+            return wait, false
+        }
+    }
+    return 0, false
+}
+
+// Takes the next request to serve. Priority requests are served first, then a passive request that was preempted
+// and then the next passive request.
+func (s *Session) nextRequest() (Request, bool, bool) {
+    select {
+    case request := <-s.priority:
+        return request, true, true
+    default:
+        // No priority requests currently.
+    }
+
+    if s.interrupted {
+        s.interrupted = false
+        return s.preempted, false, true
     }
+
+    select {
+    case request := <-s.passive:
+        return request, false, true
+    default:
+        return Request{}, false, false
+    }
+}
+
+// Finishes the current job.
+func (s *Session) finish() {
+    s.job = nil
+}
+
+// Closes the session once the bytes written to it have been flushed after the archives were reloaded, so that the
+// client reconnects. Clients download the release manifest again when they reconnect and only request the archives
+// that changed. Returns the same as serve.
+func (s *Session) reconnect() (time.Duration, bool) {
+    ok, err := s.TryCloseAfterFlush(s.writable)
+    if err != nil {
+        return 0, true
+    }
+
+    if !ok {
+        return untilWritable, false
+    }
+
+    s.reconnecting = true
+    sessionsReconnected.Inc()
+    s.Info("Closing file session to reconnect after archives were reloaded")
+    return 0, true
+}
+
+// Abandons the current job after it failed, closing the session unless it was already closed.
+func (s *Session) fail(err error) {
+    s.job = nil
+    if err != server.ErrClosed {
+        jobErrors.Inc()
+        s.Fatal(err)
+    }
+}
+
+// Wakes the session once its client can accept bytes again.
+func (s *Session) writable() {
+    s.scheduler.wake(s)
 }
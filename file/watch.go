@@ -0,0 +1,79 @@
+package file
+
+import (
+    "os"
+    "path/filepath"
+    "time"
+)
+
+// The size and modification time of a watched file.
+type fileStamp struct {
+    size    int64
+    modTime time.Time
+}
+
+// Polls a file or a directory at an interval and calls changed once the file, or the files in the directory and its
+// subdirectories, have changed and have then been left alone for an interval, so that a cache that is being copied is
+// not picked up halfway. A file that is replaced by renaming another file over it is seen as changed. Blocks until the
+// quit channel is closed.
+func Watch(path string, interval time.Duration, quit <-chan struct{}, changed func()) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    last, _ := stampPath(path)
+    settling := false
+
+    for {
+        select {
+        case <-quit:
+            return
+        case <-ticker.C:
+        }
+
+        stamps, err := stampPath(path)
+        if err != nil {
+            // The path may be in the middle of being replaced, it is stamped again on the next tick.
+            settling = true
+            continue
+        }
+
+        if !sameStamps(stamps, last) {
+            last, settling = stamps, true
+            continue
+        }
+
+        if settling {
+            settling = false
+            changed()
+        }
+    }
+}
+
+// Stamps the file at a path, or every file under it if it is a directory.
+func stampPath(root string) (map[string]fileStamp, error) {
+    stamps := make(map[string]fileStamp)
+    err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+
+        if !info.IsDir() {
+            stamps[path] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+        }
+        return nil
+    })
+    return stamps, err
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+    if len(a) != len(b) {
+        return false
+    }
+
+    for name, stamp := range a {
+        if other, ok := b[name]; !ok || other.size != stamp.size || !other.modTime.Equal(stamp.modTime) {
+            return false
+        }
+    }
+    return true
+}
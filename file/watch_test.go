@@ -0,0 +1,83 @@
+package file
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// Watches a path, returning a channel that receives each reported change.
+func watch(t *testing.T, path string) <-chan struct{} {
+    quit := make(chan struct{})
+    t.Cleanup(func() { close(quit) })
+
+    changed := make(chan struct{}, 1)
+    go Watch(path, 10*time.Millisecond, quit, func() { changed <- struct{}{} })
+    return changed
+}
+
+func expectChange(t *testing.T, changed <-chan struct{}, expected bool, description string) {
+    timeout := 50 * time.Millisecond
+    if expected {
+        timeout = 5 * time.Second
+    }
+
+    select {
+    case <-changed:
+        if !expected {
+            t.Fatalf("expected %s not to be reported", description)
+        }
+    case <-time.After(timeout):
+        if expected {
+            t.Fatalf("expected %s to be reported once it settled", description)
+        }
+    }
+}
+
+func TestWatch_Directory(t *testing.T) {
+    dir, err := ioutil.TempDir("", "donut-watch")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+
+    changed := watch(t, dir)
+    expectChange(t, changed, false, "an unchanged directory")
+
+    if err := ioutil.WriteFile(filepath.Join(dir, "main_file_cache.dat2"), []byte{1, 2, 3}, 0644); err != nil {
+        t.Fatal(err)
+    }
+    expectChange(t, changed, true, "a new file in the directory")
+}
+
+func TestWatch_File(t *testing.T) {
+    dir, err := ioutil.TempDir("", "donut-watch")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+
+    path := filepath.Join(dir, "cache.pack")
+    if err := ioutil.WriteFile(path, []byte{1, 2, 3}, 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    changed := watch(t, path)
+
+    if err := ioutil.WriteFile(filepath.Join(dir, "unrelated"), []byte{1, 2, 3}, 0644); err != nil {
+        t.Fatal(err)
+    }
+    expectChange(t, changed, false, "a change to another file in the directory")
+
+    // Pack files are replaced by renaming a new file over them.
+    if err := ioutil.WriteFile(path+".tmp", []byte{4, 5, 6, 7}, 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    if err := os.Rename(path+".tmp", path); err != nil {
+        t.Fatal(err)
+    }
+    expectChange(t, changed, true, "a file that was renamed over the watched file")
+}
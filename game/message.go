@@ -7,18 +7,51 @@ import (
 
 var (
     handshakeConfig = message.Config{
-        Id:   14,
-        Size: 0,
-        New:  message.Singleton(Handshake),
+        Id:        14,
+        Size:      0,
+        Direction: message.DirectionInbound,
+        New:       message.Singleton(Handshake),
     }
 
     authenticateConfig = message.Config{
-        Id:   16,
-        Size: message.SizeVariableShort,
-        New:  func() message.Message { return &Authenticate{} },
+        Id:        16,
+        Size:      message.SizeVariableShort,
+        Direction: message.DirectionInbound,
+        New:       func() message.Message { return &Authenticate{} },
+    }
+
+    displaySystemMessageConfig = message.Config{
+        Id:        3,
+        Size:      message.SizeVariableByte,
+        Direction: message.DirectionOutbound,
+        New:       func() message.Message { return &DisplaySystemMessage{} },
+    }
+
+    setSystemUpdateTimerConfig = message.Config{
+        Id:        72,
+        Size:      2,
+        Direction: message.DirectionOutbound,
+        New:       func() message.Message { return &SetSystemUpdateTimer{} },
     }
 
     Handshake    = &handshake{}
+
+    // All of the messages that the game service accepts.
+    inbound = []message.Config{
+        handshakeConfig,
+        authenticateConfig,
+    }
+
+    // All of the messages that the game service sends.
+    outbound = []message.Config{
+        displaySystemMessageConfig,
+        setSystemUpdateTimerConfig,
+    }
+
+    // The messages of the game service for each supported client revision.
+    Definitions = []message.Definition{
+        {Revision: 177, Inbound: inbound, Outbound: outbound},
+    }
 )
 
 type handshake struct{}
@@ -39,3 +72,43 @@ func (Authenticate) Config() message.Config { return authenticateConfig }
 func (Authenticate) Decode(buf *buffer.ByteBuffer, length int) error {
     return nil
 }
+
+// Displays a message in the chat box of a player.
+type DisplaySystemMessage struct {
+    Type uint8
+
+    // The display name of the player that the message is about, empty if it is not about a player.
+    InteractingWith string
+
+    Text string
+}
+
+func (DisplaySystemMessage) Config() message.Config { return displaySystemMessageConfig }
+
+func (d DisplaySystemMessage) Encode(buf *buffer.ByteBuffer) error {
+    if err := buf.PutSmart(uint16(d.Type)); err != nil {
+        return err
+    }
+
+    if err := buf.PutBool(d.InteractingWith != ""); err != nil {
+        return err
+    }
+
+    if d.InteractingWith != "" {
+        if err := buf.PutCString(d.InteractingWith); err != nil {
+            return err
+        }
+    }
+    return buf.PutCString(d.Text)
+}
+
+// Starts the countdown to a system update for a player, in game ticks.
+type SetSystemUpdateTimer struct {
+    Ticks uint16
+}
+
+func (SetSystemUpdateTimer) Config() message.Config { return setSystemUpdateTimerConfig }
+
+func (t SetSystemUpdateTimer) Encode(buf *buffer.ByteBuffer) error {
+    return buf.PutUint16(t.Ticks)
+}
@@ -0,0 +1,93 @@
+package game
+
+import (
+    "bytes"
+    "github.com/sprinkle-it/donut/buffer"
+    "github.com/sprinkle-it/donut/message"
+    "github.com/sprinkle-it/donut/message/messagetest"
+    "reflect"
+    "testing"
+)
+
+func TestDefinitions(t *testing.T) {
+    if _, err := message.NewRegistry(Definitions...); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestInbound_Decode(t *testing.T) {
+    configs := make(map[uint8]message.Config)
+    for _, config := range inbound {
+        configs[config.Id] = config
+    }
+
+    tests := map[string]struct {
+        frame    []byte
+        expected message.Message
+    }{
+        "handshake":    {[]byte{14}, Handshake},
+        "authenticate": {[]byte{16, 0, 0}, &Authenticate{}},
+    }
+
+    for name, test := range tests {
+        t.Run(name, func(t *testing.T) {
+            input := buffer.NewRingBuffer(len(test.frame))
+            _, _ = input.Write(test.frame)
+
+            decoder := message.NewStreamDecoder(configs, 16)
+
+            msg, err := decoder.Decode(&input)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            if buffer.HasReadable(&input) || !reflect.DeepEqual(msg, test.expected) {
+                t.Errorf("expected %v to be decoded as %#v, got %#v", test.frame, test.expected, msg)
+            }
+        })
+    }
+}
+
+func TestOutbound_Encode(t *testing.T) {
+    tests := map[string]struct {
+        msg      message.Outbound
+        expected []byte
+    }{
+        "system message": {
+            &DisplaySystemMessage{Type: 0, Text: "hi"},
+            []byte{0, 0, 'h', 'i', 0},
+        },
+        "system message about a player": {
+            &DisplaySystemMessage{Type: 200, InteractingWith: "bob", Text: "hi"},
+            []byte{0x80, 200, 1, 'b', 'o', 'b', 0, 'h', 'i', 0},
+        },
+        "system update timer": {
+            &SetSystemUpdateTimer{Ticks: 500},
+            []byte{0x01, 0xf4},
+        },
+    }
+
+    for name, test := range tests {
+        t.Run(name, func(t *testing.T) {
+            buf := buffer.NewByteBuffer(64)
+            if err := test.msg.Encode(&buf); err != nil {
+                t.Fatal(err)
+            }
+
+            if !bytes.Equal(buf.Bytes[:buf.Offset], test.expected) {
+                t.Errorf("expected %x, got %x", test.expected, buf.Bytes[:buf.Offset])
+            }
+        })
+    }
+}
+
+func TestOutbound_RoundTrip(t *testing.T) {
+    messagetest.RoundTrip(t, outbound)
+}
+
+func FuzzInbound_Decode(f *testing.F) {
+    messagetest.Seed(f, inbound)
+    f.Fuzz(func(t *testing.T, index uint8, payload []byte) {
+        messagetest.Decode(t, inbound, index, payload)
+    })
+}
@@ -1,14 +1,17 @@
 package game
 
 import (
-    "github.com/sprinkle-it/donut/message"
+    "errors"
     "github.com/sprinkle-it/donut/server"
     "go.uber.org/zap"
 )
 
+// The stage clients are in once they have logged in to the game.
+const Stage server.Stage = "game"
+
 type Config struct {
-    LoggerConfig     zap.Config
-    SupportedVersion uint32
+    // The shared logger, the service logs to a sub-logger named "game".
+    Logger *zap.Logger
 }
 
 type Service struct {
@@ -17,13 +20,13 @@ type Service struct {
 }
 
 func New(config Config) (*Service, error) {
-    logger, err := config.LoggerConfig.Build()
-    if err != nil {
-        return nil, err
+    if config.Logger == nil {
+        return nil, errors.New("game: a logger is required")
     }
 
     return &Service{
-        logger: logger,
+        logger:   config.Logger.Named("game"),
+        commands: make(chan command),
     }, nil
 }
 
@@ -34,9 +37,7 @@ func (s *Service) handleMail(mail server.Mail) {
 func (s *Service) MailReceiver() server.MailReceiver {
     return server.MailReceiver{
         Handler: s.handleMail,
-        Accept: []message.Config{
-            handshakeConfig,
-        },
+        Accept:  inbound,
     }
 }
 
@@ -57,6 +58,11 @@ type handleMessage struct {
 }
 
 func (c handleMessage) execute(s *Service) {
+    source := c.mail.Source
     switch c.mail.Message.(type) {
+    case *Authenticate:
+        // Clients are in the game once they have logged in, which is what broadcasts to players are sent to.
+        source.SetStage(Stage)
+        source.Info("Logged in client to game service")
     }
 }
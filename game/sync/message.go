@@ -7,9 +7,10 @@ import (
 
 var (
     messageConfig = message.Config{
-        Id:   79,
-        Size: message.SizeVariableShort,
-        New:  func() message.Message { return &Message{} },
+        Id:        79,
+        Size:      message.SizeVariableShort,
+        Direction: message.DirectionOutbound,
+        New:       func() message.Message { return &Message{} },
     }
 )
 
@@ -24,15 +25,13 @@ var EndList = endList{}
 type startList struct{}
 
 func (startList) Encode(buf *buffer.ByteBuffer) error {
-    buf.StartBitAccess()
-    return nil
+    return buf.StartBitAccess()
 }
 
 type endList struct{}
 
 func (endList) Encode(buf *buffer.ByteBuffer) error {
-    buf.FinishBitAccess()
-    return nil
+    return buf.FinishBitAccess()
 }
 
 type Skip struct {
@@ -41,24 +40,29 @@ type Skip struct {
 
 func (b Skip) Encode(buf *buffer.ByteBuffer) error {
     // Flag for if the block is a descriptor, skips blocks are not descriptors.
-    buf.PutBits(0, 1)
+    if err := buf.PutBits(0, 1); err != nil {
+        return err
+    }
 
     // Write out the amount of players in the list to skip.
     switch {
     case b.Count < 1:
-        buf.PutBits(0, 2)
-        return nil
+        return buf.PutBits(0, 2)
     case b.Count >= 1 && b.Count <= 31:
-        buf.PutBits(1, 2)
-        buf.PutBits(uint32(b.Count), 5)
+        return putCount(buf, 1, b.Count, 5)
     case b.Count >= 32 && b.Count <= 255:
-        buf.PutBits(2, 2)
-        buf.PutBits(uint32(b.Count), 8)
+        return putCount(buf, 2, b.Count, 8)
     default:
-        buf.PutBits(3, 2)
-        buf.PutBits(uint32(b.Count), 11)
+        return putCount(buf, 3, b.Count, 11)
     }
-    return nil
+}
+
+// Puts the type of a count followed by the count encoded with the number of bits for the type.
+func putCount(buf *buffer.ByteBuffer, kind uint32, count int, bits int) error {
+    if err := buf.PutBits(kind, 2); err != nil {
+        return err
+    }
+    return buf.PutBits(uint32(count), bits)
 }
 
 type BlockList []Block
@@ -7,9 +7,10 @@ type Walk struct {
 }
 
 func (w Walk) Encode(buf *buffer.ByteBuffer) error {
-    buf.PutBits(1, 2)
-    buf.PutBits(uint32(w.Direction), 3)
-    return nil
+    if err := buf.PutBits(1, 2); err != nil {
+        return err
+    }
+    return buf.PutBits(uint32(w.Direction), 3)
 }
 
 type Run struct {
@@ -17,17 +18,44 @@ type Run struct {
 }
 
 func (r Run) Encode(buf *buffer.ByteBuffer) error {
-    buf.PutBits(1, 2)
-    buf.PutBits(uint32(r.Direction), 4)
-    return nil
+    if err := buf.PutBits(2, 2); err != nil {
+        return err
+    }
+    return buf.PutBits(uint32(r.Direction), 4)
 }
 
+// The largest distance that a player can be teleported on either axis for it to be encoded as a nearby teleport.
+const nearbyTeleport = 15
+
+// Teleported moves a player by the given deltas. The client wraps the deltas around the region so they are encoded
+// as two's complement values masked to the number of bits for either a nearby or a distant teleport.
 type Teleported struct {
+    DeltaHeight int
+    DeltaX      int
+    DeltaZ      int
+}
 
+func (t Teleported) Encode(buf *buffer.ByteBuffer) error {
+    if err := buf.PutBits(3, 2); err != nil {
+        return err
+    }
+
+    if t.nearby() {
+        if err := buf.PutBits(0, 1); err != nil {
+            return err
+        }
+        return buf.PutBits(uint32(t.DeltaHeight&0x3)<<10|uint32(t.DeltaX&0x1f)<<5|uint32(t.DeltaZ&0x1f), 12)
+    }
+
+    if err := buf.PutBits(1, 1); err != nil {
+        return err
+    }
+    return buf.PutBits(uint32(t.DeltaHeight&0x3)<<28|uint32(t.DeltaX&0x3fff)<<14|uint32(t.DeltaZ&0x3fff), 30)
 }
 
-func (Teleported) Encode(*buffer.ByteBuffer) error {
-    panic("implement me")
+func (t Teleported) nearby() bool {
+    return t.DeltaX >= -nearbyTeleport && t.DeltaX <= nearbyTeleport &&
+        t.DeltaZ >= -nearbyTeleport && t.DeltaZ <= nearbyTeleport
 }
 
 var Updated = updated{}
@@ -37,8 +65,7 @@ type updated struct {
 }
 
 func (u updated) Encode(buf *buffer.ByteBuffer) error {
-    buf.PutBits(1, 1)
-    return nil
+    return buf.PutBits(1, 1)
 }
 
 
@@ -49,8 +76,7 @@ type notUpdated struct {
 }
 
 func (u notUpdated) Encode(buf *buffer.ByteBuffer) error {
-    buf.PutBits(0, 1)
-    return nil
+    return buf.PutBits(0, 1)
 }
 
 var Remove = remove{}
@@ -60,7 +86,8 @@ type remove struct {
 }
 
 func (remove) Encode(buf *buffer.ByteBuffer) error {
-    buf.PutBits(0, 1)
-    buf.PutBits(0, 2)
-    return nil
+    if err := buf.PutBits(0, 1); err != nil {
+        return err
+    }
+    return buf.PutBits(0, 2)
 }
\ No newline at end of file
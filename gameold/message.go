@@ -1,5 +1,7 @@
 package gameold
 
+//go:generate go run ../cmd/msggen -spec messages.json
+
 import (
 	"fmt"
 	"github.com/sprinkle-it/donut/account"
@@ -11,290 +13,208 @@ import (
 
 var (
 	HandshakeConfig = message.Config{
-		Id:   14,
-		Size: 0,
-		New:  message.Singleton(Handshake),
+		Id:        14,
+		Size:      0,
+		Direction: message.DirectionInbound,
+		New:       message.Singleton(Handshake),
 	}
 
 	NewLoginConfig = message.Config{
-		Id:   16,
-		Size: message.SizeVariableShort,
-		New:  func() message.Message { return &NewLogin{} },
+		Id:        16,
+		Size:      message.SizeVariableShort,
+		Direction: message.DirectionInbound,
+		New:       func() message.Message { return &NewLogin{} },
 	}
 
 	ReconnectConfig = message.Config{
-		Id:   18,
-		Size: message.SizeVariableShort,
-		New:  func() message.Message { return &Reconnect{} },
+		Id:        18,
+		Size:      message.SizeVariableShort,
+		Direction: message.DirectionInbound,
+		New:       func() message.Message { return &Reconnect{} },
 	}
 
 	WindowUpdateConfig = message.Config{
-		Id:   35,
-		Size: 5,
-		New:  func() message.Message { return &WindowUpdate{} },
-	}
-
-	FocusChangedConfig = message.Config{
-		Id:   73,
-		Size: 1,
-		New:  func() message.Message { return &FocusChanged{} },
+		Id:        35,
+		Size:      5,
+		Direction: message.DirectionInbound,
+		New:       func() message.Message { return &WindowUpdate{} },
 	}
 
 	SceneRebuiltConfig = message.Config{
-		Id:   76,
-		Size: 0,
-		New:  message.Singleton(SceneRebuilt),
+		Id:        76,
+		Size:      0,
+		Direction: message.DirectionInbound,
+		New:       message.Singleton(SceneRebuilt),
 	}
 
 	HeartbeatConfig = message.Config{
-		Id:   122,
-		Size: 0,
-		New:  message.Singleton(Heartbeat),
+		Id:        122,
+		Size:      0,
+		Direction: message.DirectionInbound,
+		New:       message.Singleton(Heartbeat),
 	}
 
 	MouseClickedConfig = message.Config{
-		Id:   41,
-		Size: 6,
-		New:  func() message.Message { return &MouseClicked{} },
+		Id:        41,
+		Size:      6,
+		Direction: message.DirectionInbound,
+		New:       func() message.Message { return &MouseClicked{} },
 	}
 
 	MouseActivityRecordedConfig = message.Config{
-		Id:   34,
-		Size: message.SizeVariableByte,
-		New:  func() message.Message { return &MouseActivityRecorded{} },
-	}
-
-	ClientPerformanceMeasuredConfig = message.Config{
-		Id:   111,
-		Size: 10,
-		New:  func() message.Message { return &ClientPerformanceMeasured{} },
+		Id:        34,
+		Size:      message.SizeVariableByte,
+		Direction: message.DirectionInbound,
+		New:       func() message.Message { return &MouseActivityRecorded{} },
 	}
 
 	KeyTypedConfig = message.Config{
-		Id:   67,
-		Size: message.SizeVariableShort,
-		New:  func() message.Message { return &KeyTyped{} },
-	}
-
-	CameraRotatedConfig = message.Config{
-		Id:   39,
-		Size: 4,
-		New:  func() message.Message { return &CameraRotated{} },
+		Id:        67,
+		Size:      message.SizeVariableShort,
+		Direction: message.DirectionInbound,
+		New:       func() message.Message { return &KeyTyped{} },
 	}
 
 	MinimapWalkConfig = message.Config{
-		Id:   52,
-		Size: message.SizeVariableByte,
-		New:  func() message.Message { return &MinimapWalk{} },
+		Id:        52,
+		Size:      message.SizeVariableByte,
+		Direction: message.DirectionInbound,
+		New:       func() message.Message { return &MinimapWalk{} },
 	}
 
 	WalkHereConfig = message.Config{
-		Id:   96,
-		Size: message.SizeVariableByte,
-		New:  func() message.Message { return &WalkHere{} },
-	}
-
-	ExamineObjectConfig = message.Config{
-		Id:   36,
-		Size: 2,
-		New:  func() message.Message { return &ExamineObject{} },
+		Id:        96,
+		Size:      message.SizeVariableByte,
+		Direction: message.DirectionInbound,
+		New:       func() message.Message { return &WalkHere{} },
 	}
 
 	ButtonPressedConfig = message.Config{
-		Id:   68,
-		Size: 9,
-		New:  func() message.Message { return &ButtonPressed{} },
+		Id:        68,
+		Size:      9,
+		Direction: message.DirectionInbound,
+		New:       func() message.Message { return &ButtonPressed{} },
 	}
 
 	ReadyConfig = message.Config{
-		Id:   0,
-		Size: 8,
-		New:  func() message.Message { return &Ready{} },
+		Id:        0,
+		Size:      8,
+		Direction: message.DirectionOutbound,
+		New:       func() message.Message { return &Ready{} },
 	}
 
 	InitializeSceneConfig = message.Config{
-		Id:   0,
-		Size: message.SizeVariableShort,
-		New:  func() message.Message { return &InitializeScene{} },
+		Id:        0,
+		Size:      message.SizeVariableShort,
+		Direction: message.DirectionOutbound,
+		New:       func() message.Message { return &InitializeScene{} },
 	}
 
 	SuccessConfig = message.Config{
-		Id:   2,
-		Size: message.SizeVariableByte,
-		New:  func() message.Message { return &Success{} },
-	}
-
-	SetHudConfig = message.Config{
-		Id:   84,
-		Size: 2,
-		New:  func() message.Message { return &SetHud{} },
-	}
-
-	SetPlayerContextMenuOptionConfig = message.Config{
-		Id:   66,
-		Size: message.SizeVariableByte,
-		New:  func() message.Message { return &SetPlayerContextMenuOption{} },
-	}
-
-	OpenChildInterfaceConfig = message.Config{
-		Id:   77,
-		Size: 7,
-		New:  func() message.Message { return &OpenChildInterface{} },
-	}
-
-	RelocateChildInterfaceConfig = message.Config{
-		Id:   82,
-		Size: 8,
-		New:  func() message.Message { return &RelocateChildInterface{} },
-	}
-
-	CloseChildInterfaceConfig = message.Config{
-		Id:   9,
-		Size: 4,
-		New:  func() message.Message { return &CloseChildInterface{} },
-	}
-
-	ClearInputBoxConfig = message.Config{
-		Id:   52,
-		Size: 0,
-		New:  func() message.Message { return &ClearInputBox{} },
+		Id:        2,
+		Size:      message.SizeVariableByte,
+		Direction: message.DirectionOutbound,
+		New:       func() message.Message { return &Success{} },
 	}
 
 	DisplaySystemMessageConfig = message.Config{
-		Id:   3,
-		Size: message.SizeVariableByte,
-		New:  func() message.Message { return &DisplaySystemMessage{} },
-	}
-
-	SetEnergyConfig = message.Config{
-		Id:   60,
-		Size: 1,
-		New:  func() message.Message { return &SetEnergy{} },
-	}
-
-	SetWeightConfig = message.Config{
-		Id:   71,
-		Size: 2,
-		New:  func() message.Message { return &SetWeight{} },
-	}
-
-	SetMinimapStateConfig = message.Config{
-		Id:   74,
-		Size: 1,
-		New:  func() message.Message { return &SetMinimapState{} },
-	}
-
-	SetSystemUpdateTimerConfig = message.Config{
-		Id:   72,
-		Size: 2,
-		New:  func() message.Message { return &SetSystemUpdateTimer{} },
-	}
-
-	ClearPerspectiveCameraConfig = message.Config{
-		Id:   2,
-		Size: 0,
-		New:  func() message.Message { return &ClearPerspectiveCamera{} },
-	}
-
-	ClearInventoryConfig = message.Config{
-		Id:   7,
-		Size: 4,
-		New:  func() message.Message { return &ClearInventory{} },
-	}
-
-	LogoutConfig = message.Config{
-		Id:   1,
-		Size: 0,
-		New:  func() message.Message { return &Logout{} },
-	}
-
-	TargetPatchConfig = message.Config{
-		Id:   64,
-		Size: 2,
-		New:  func() message.Message { return &TargetPatch{} },
-	}
-
-	ClearPatchConfig = message.Config{
-		Id:   25,
-		Size: 2,
-		New:  func() message.Message { return &ClearPatch{} },
-	}
-
-	Set32BitVariableConfig = message.Config{
-		Id:   4,
-		Size: 6,
-		New:  func() message.Message { return &Set32BitVariable{} },
-	}
-
-	Set8BitVariableConfig = message.Config{
-		Id:   63,
-		Size: 3,
-		New:  func() message.Message { return &Set8BitVariable{} },
-	}
-
-	ClearVariablesConfig = message.Config{
-		Id:   78,
-		Size: 0,
-		New:  func() message.Message { return &ClearVariables{} },
-	}
-
-	RevertVariablesConfig = message.Config{
-		Id:   73,
-		Size: 0,
-		New:  func() message.Message { return &RevertVariables{} },
-	}
-
-	SetSkillConfig = message.Config{
-		Id:   22,
-		Size: 6,
-		New:  func() message.Message { return &SetSkill{} },
-	}
-
-	ModifyLabelTextConfig = message.Config{
-		Id:   19,
-		Size: message.SizeVariableShort,
-		New:  func() message.Message { return &ModifyLabelText{} },
+		Id:        3,
+		Size:      message.SizeVariableByte,
+		Direction: message.DirectionOutbound,
+		New:       func() message.Message { return &DisplaySystemMessage{} },
 	}
 
 	ModifyLabelColourConfig = message.Config{
-		Id:   24,
-		Size: 6,
-		New:  func() message.Message { return &ModifyLabelColour{} },
+		Id:        24,
+		Size:      6,
+		Direction: message.DirectionOutbound,
+		New:       func() message.Message { return &ModifyLabelColour{} },
 	}
 
 	InvokeInterfaceScriptConfig = message.Config{
-		Id:   62,
-		Size: message.SizeVariableShort,
-		New:  func() message.Message { return &InvokeInterfaceScript{} },
-	}
-
-	ToggleComponentVisibilityConfig = message.Config{
-		Id:   21,
-		Size: 5,
-		New:  func() message.Message { return &ToggleComponentVisibility{} },
-	}
-
-	RequestClientPerformanceConfig = message.Config{
-		Id:   69,
-		Size: 8,
-		New:  func() message.Message { return &RequestClientPerformance{} },
+		Id:        62,
+		Size:      message.SizeVariableShort,
+		Direction: message.DirectionOutbound,
+		New:       func() message.Message { return &InvokeInterfaceScript{} },
 	}
 
 	GroupedEntityUpdateConfig = message.Config{
-		Id:   17,
-		Size: message.SizeVariableShort,
-		New:  func() message.Message { return &GroupedEntityUpdate{} },
+		Id:        17,
+		Size:      message.SizeVariableShort,
+		Direction: message.DirectionOutbound,
+		New:       func() message.Message { return &GroupedEntityUpdate{} },
 	}
 
 	PlayerUpdateConfig = message.Config{
-		Id:   79,
-		Size: message.SizeVariableShort,
-		New:  func() message.Message { return &PlayerUpdate{} },
+		Id:        79,
+		Size:      message.SizeVariableShort,
+		Direction: message.DirectionOutbound,
+		New:       func() message.Message { return &PlayerUpdate{} },
 	}
 
 	Handshake    = &handshake{}
 	Heartbeat    = &heartbeat{}
 	SceneRebuilt = &sceneRebuilt{}
+
+	// All of the messages that the game service accepts from clients.
+	inbound = []message.Config{
+		HandshakeConfig,
+		NewLoginConfig,
+		ReconnectConfig,
+		WindowUpdateConfig,
+		FocusChangedConfig,
+		SceneRebuiltConfig,
+		HeartbeatConfig,
+		MouseClickedConfig,
+		MouseActivityRecordedConfig,
+		ClientPerformanceMeasuredConfig,
+		KeyTypedConfig,
+		CameraRotatedConfig,
+		MinimapWalkConfig,
+		WalkHereConfig,
+		ExamineObjectConfig,
+		ButtonPressedConfig,
+	}
+
+	// All of the messages that the game service sends to clients.
+	outbound = []message.Config{
+		ReadyConfig,
+		InitializeSceneConfig,
+		SuccessConfig,
+		SetHudConfig,
+		SetPlayerContextMenuOptionConfig,
+		OpenChildInterfaceConfig,
+		RelocateChildInterfaceConfig,
+		CloseChildInterfaceConfig,
+		ClearInputBoxConfig,
+		DisplaySystemMessageConfig,
+		SetEnergyConfig,
+		SetWeightConfig,
+		SetMinimapStateConfig,
+		SetSystemUpdateTimerConfig,
+		ClearPerspectiveCameraConfig,
+		ClearInventoryConfig,
+		LogoutConfig,
+		TargetPatchConfig,
+		ClearPatchConfig,
+		Set32BitVariableConfig,
+		Set8BitVariableConfig,
+		ClearVariablesConfig,
+		RevertVariablesConfig,
+		SetSkillConfig,
+		ModifyLabelTextConfig,
+		ModifyLabelColourConfig,
+		InvokeInterfaceScriptConfig,
+		ToggleComponentVisibilityConfig,
+		RequestClientPerformanceConfig,
+		GroupedEntityUpdateConfig,
+		PlayerUpdateConfig,
+	}
+
+	// The messages of the game service for each supported client revision.
+	Definitions = []message.Definition{
+		{Revision: 177, Inbound: inbound, Outbound: outbound},
+	}
 )
 
 type handshake struct{}
@@ -329,6 +249,9 @@ type Authenticate struct {
 	ResizableMode bool
 }
 
+// Gets the revision of the client, which selects the protocol used once the client has logged in.
+func (a Authenticate) Revision() uint32 { return a.ClientVersion }
+
 func (NewLogin) Config() message.Config {
 	return NewLoginConfig
 }
@@ -431,15 +354,23 @@ type InitializeScene struct {
 func (InitializeScene) Config() message.Config { return InitializeSceneConfig }
 
 func (init InitializeScene) Encode(buf *buffer.ByteBuffer) error {
-	buf.StartBitAccess()
+	if err := buf.StartBitAccess(); err != nil {
+		return err
+	}
 
-	init.Position.EncodeHash(buf)
+	if err := init.Position.EncodeHash(buf); err != nil {
+		return err
+	}
 
 	for i := 0; i < len(init.PlayerPositions); i++ {
-		init.PlayerPositions[i].EncodeBlockHash(buf)
+		if err := init.PlayerPositions[i].EncodeBlockHash(buf); err != nil {
+			return err
+		}
 	}
 
-    buf.FinishBitAccess()
+	if err := buf.FinishBitAccess(); err != nil {
+		return err
+	}
 
 	if err := buf.PutUint16(init.Position.ChunkX()); err != nil {
 		return err
@@ -452,13 +383,40 @@ func (init InitializeScene) Encode(buf *buffer.ByteBuffer) error {
 	return nil
 }
 
+// The window modes that the client sends in a window update.
+const (
+	windowFixed     = 1
+	windowResizable = 2
+)
+
 type WindowUpdate struct {
+	Resizable bool
+	Width     uint16
+	Height    uint16
 }
 
 func (WindowUpdate) Config() message.Config { return WindowUpdateConfig }
 
-func (WindowUpdate) Decode(buf *buffer.ByteBuffer, length int) error {
-	return nil
+func (w *WindowUpdate) Decode(buf *buffer.ByteBuffer, length int) (err error) {
+	var mode uint8
+	if mode, err = buf.GetUint8(); err != nil {
+		return
+	}
+
+	if mode != windowFixed && mode != windowResizable {
+		return fmt.Errorf("gameold: unexpected window mode %d", mode)
+	}
+	w.Resizable = mode == windowResizable
+
+	if w.Width, err = buf.GetUint16(); err != nil {
+		return
+	}
+
+	if w.Height, err = buf.GetUint16(); err != nil {
+		return
+	}
+
+	return
 }
 
 type heartbeat struct {
@@ -481,38 +439,39 @@ type ButtonPressed struct {
 	// be used for something else. Something... custom.
 	Option uint8
 
-	Interface    int
-	Button       int
-	Component    uint16
-	SubComponent uint16 // TODO is truly a subcomponent? need confirmation or proper identification
+	// The interface and the component of it that was pressed, which the client sends packed into a single int.
+	Interface uint16
+	Component uint16
+
+	// The slot of the component that was pressed and the item in the slot, 65535 if the component has no slots or the
+	// slot is empty.
+	Slot uint16
+	Item uint16
 }
 
 func (ButtonPressed) Config() message.Config { return ButtonPressedConfig }
 
-func (b *ButtonPressed) Decode(buf *buffer.ByteBuffer, length int) error {
-	var err error
-
+// Decodes the button from the fields as the client writes them after the option, which are not transformed.
+func (b *ButtonPressed) Decode(buf *buffer.ByteBuffer, length int) (err error) {
 	if b.Option, err = buf.GetUint8(); err != nil {
-		return err
+		return
 	}
 
-	var pack uint32
-	if pack, err = buf.GetUint32(); err != nil {
-		return err
+	var widget uint32
+	if widget, err = buf.GetUint32(); err != nil {
+		return
 	}
+	b.Interface, b.Component = uint16(widget>>16), uint16(widget)
 
-	b.Interface = int(pack >> 16)
-	b.Button = int(pack & 0xFFFF)
-
-	if b.Component, err = buf.GetUint16(); err != nil {
-		return err
+	if b.Slot, err = buf.GetUint16(); err != nil {
+		return
 	}
 
-	if b.SubComponent, err = buf.GetUint16(); err != nil {
-		return err
+	if b.Item, err = buf.GetUint16(); err != nil {
+		return
 	}
 
-	return err
+	return
 }
 
 type MouseClicked struct {
@@ -557,35 +516,6 @@ func (MouseActivityRecorded) Decode(buf *buffer.ByteBuffer, length int) error {
 	return buf.Skip(length)
 }
 
-type ClientPerformanceMeasured struct {
-	FirstKey  uint32
-	SecondKey uint32
-	FPS       uint8
-	GCTime    uint8
-}
-
-func (ClientPerformanceMeasured) Config() message.Config { return ClientPerformanceMeasuredConfig }
-
-func (c *ClientPerformanceMeasured) Decode(buf *buffer.ByteBuffer, length int) (err error) {
-	if c.GCTime, err = buf.GetUint8(); err != nil {
-		return
-	}
-
-	if c.FPS, err = buf.GetUint8(); err != nil {
-		return
-	}
-
-	if c.FirstKey, err = buf.GetUint32(); err != nil {
-		return
-	}
-
-	if c.SecondKey, err = buf.GetUint32(); err != nil {
-		return
-	}
-
-	return
-}
-
 // Inbound message from the client that lets the server know that the scene has been successfully rebuilt.
 type sceneRebuilt struct{}
 
@@ -595,30 +525,6 @@ func (sceneRebuilt) Decode(buf *buffer.ByteBuffer, length int) error {
 	return nil
 }
 
-type FocusChanged struct {
-	Focused bool
-}
-
-func (*FocusChanged) Config() message.Config { return FocusChangedConfig }
-
-func (f *FocusChanged) Decode(buf *buffer.ByteBuffer, length int) error {
-	var err error
-	if f.Focused, err = buf.GetBool(); err != nil {
-		return err
-	}
-	return nil
-}
-
-type SetHud struct {
-	Id uint16
-}
-
-func (SetHud) Config() message.Config { return SetHudConfig }
-
-func (s SetHud) Encode(buf *buffer.ByteBuffer) error {
-	return buf.PutUint16(s.Id)
-}
-
 type GroupedEntityUpdate struct {
 	X        uint8
 	Z        uint8
@@ -649,25 +555,6 @@ func (g GroupedEntityUpdate) Encode(buf *buffer.ByteBuffer) error {
 	return nil
 }
 
-type ModifyLabelText struct {
-	Parent uint32
-	Text   string
-}
-
-func (ModifyLabelText) Config() message.Config { return ModifyLabelTextConfig }
-
-func (m ModifyLabelText) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint32(m.Parent); err != nil {
-		return err
-	}
-
-	if err := buf.PutCString(m.Text); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 type ModifyLabelColour struct {
 	Parent uint32
 	R      int
@@ -689,76 +576,6 @@ func (m ModifyLabelColour) Encode(buf *buffer.ByteBuffer) error {
 	return nil
 }
 
-type SetPlayerContextMenuOption struct {
-	Slot        uint8
-	Label       string
-	Prioritized bool
-}
-
-func (SetPlayerContextMenuOption) Config() message.Config { return SetPlayerContextMenuOptionConfig }
-
-func (s SetPlayerContextMenuOption) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutBool(s.Prioritized); err != nil {
-		return err
-	}
-
-	if err := buf.PutUint8(s.Slot); err != nil {
-		return err
-	}
-
-	if err := buf.PutCString(s.Label); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type ClearInputBox struct{}
-
-func (ClearInputBox) Config() message.Config { return ClearInputBoxConfig }
-
-func (ClearInputBox) Encode(buf *buffer.ByteBuffer) error {
-	return nil
-}
-
-type ToggleComponentVisibility struct {
-	Parent uint32
-	Hidden bool
-}
-
-func (ToggleComponentVisibility) Config() message.Config { return ToggleComponentVisibilityConfig }
-
-func (t ToggleComponentVisibility) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint32(t.Parent); err != nil {
-		return err
-	}
-
-	if err := buf.PutBool(t.Hidden); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type RequestClientPerformance struct {
-	FirstKey  uint32
-	SecondKey uint32
-}
-
-func (RequestClientPerformance) Config() message.Config { return RequestClientPerformanceConfig }
-
-func (r RequestClientPerformance) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint32(r.FirstKey); err != nil {
-		return err
-	}
-
-	if err := buf.PutUint32(r.SecondKey); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 type ScriptArgument interface{}
 
 type InvokeInterfaceScript struct {
@@ -825,8 +642,8 @@ type DisplaySystemMessage struct {
 func (DisplaySystemMessage) Config() message.Config { return DisplaySystemMessageConfig }
 
 func (d DisplaySystemMessage) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint8(d.Type); err != nil { // TODO encode this as a smart
-		return nil
+	if err := buf.PutSmart(uint16(d.Type)); err != nil {
+		return err
 	}
 
 	if err := buf.PutBool(d.InteractingWith != nil); err != nil {
@@ -846,257 +663,6 @@ func (d DisplaySystemMessage) Encode(buf *buffer.ByteBuffer) error {
 	return nil
 }
 
-type SetSystemUpdateTimer struct {
-	Ticks uint16
-}
-
-func (SetSystemUpdateTimer) Config() message.Config { return SetSystemUpdateTimerConfig }
-
-func (s SetSystemUpdateTimer) Encode(buf *buffer.ByteBuffer) error {
-	return buf.PutUint16(s.Ticks)
-}
-
-type ClearPerspectiveCamera struct{}
-
-func (ClearPerspectiveCamera) Config() message.Config { return ClearPerspectiveCameraConfig }
-
-func (c ClearPerspectiveCamera) Encode(buf *buffer.ByteBuffer) error {
-	return nil
-}
-
-type ClearInventory struct {
-	Parent uint32
-}
-
-func (ClearInventory) Config() message.Config { return ClearInventoryConfig }
-
-func (c ClearInventory) Encode(buf *buffer.ByteBuffer) error {
-	return buf.PutUint32(c.Parent)
-}
-
-type Logout struct{}
-
-func (Logout) Config() message.Config { return LogoutConfig }
-
-func (l Logout) Encode(buf *buffer.ByteBuffer) error {
-	return nil
-}
-
-type TargetPatch struct {
-	X uint8
-	Z uint8
-}
-
-func (TargetPatch) Config() message.Config { return TargetPatchConfig }
-
-func (t TargetPatch) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint8(t.Z); err != nil {
-		return err
-	}
-
-	if err := buf.PutUint8(t.X); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type ClearPatch struct {
-	X uint8
-	Z uint8
-}
-
-func (ClearPatch) Config() message.Config { return ClearPatchConfig }
-
-func (c ClearPatch) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint8(c.Z); err != nil {
-		return err
-	}
-
-	if err := buf.PutUint8(c.X); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type SetSkill struct {
-	Id         uint8
-	Level      uint8
-	Experience uint32
-}
-
-func (SetSkill) Config() message.Config { return SetSkillConfig }
-
-func (s SetSkill) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint8(s.Id); err != nil {
-		return err
-	}
-
-	if err := buf.PutUint8(s.Level); err != nil {
-		return err
-	}
-
-	if err := buf.PutUint32(s.Experience); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type Set32BitVariable struct {
-	Id    uint16
-	Value uint32
-}
-
-func (Set32BitVariable) Config() message.Config { return Set32BitVariableConfig }
-
-func (s Set32BitVariable) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint16(s.Id); err != nil {
-		return err
-	}
-
-	if err := buf.PutUint32(s.Value); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type Set8BitVariable struct {
-	Id    uint16
-	Value uint8
-}
-
-func (Set8BitVariable) Config() message.Config { return Set8BitVariableConfig }
-
-func (s Set8BitVariable) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint16(s.Id); err != nil {
-		return err
-	}
-
-	if err := buf.PutUint8(s.Value); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type ClearVariables struct{}
-
-func (ClearVariables) Config() message.Config { return ClearVariablesConfig }
-
-func (c ClearVariables) Encode(buf *buffer.ByteBuffer) error {
-	return nil
-}
-
-type RevertVariables struct{}
-
-func (RevertVariables) Config() message.Config { return RevertVariablesConfig }
-
-func (c RevertVariables) Encode(buf *buffer.ByteBuffer) error {
-	return nil
-}
-
-type OpenChildInterface struct {
-	Parent   uint32
-	Id       uint16
-	Behavior uint8
-}
-
-func (OpenChildInterface) Config() message.Config { return OpenChildInterfaceConfig }
-
-func (o OpenChildInterface) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint32(o.Parent); err != nil {
-		return err
-	}
-
-	if err := buf.PutUint16(o.Id); err != nil {
-		return err
-	}
-
-	if err := buf.PutUint8(o.Behavior); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type SetEnergy struct {
-	Percentage uint8
-}
-
-func (SetEnergy) Config() message.Config { return SetEnergyConfig }
-
-func (s SetEnergy) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint8(s.Percentage); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type SetWeight struct {
-	Kilograms uint16
-}
-
-func (SetWeight) Config() message.Config { return SetWeightConfig }
-
-func (s SetWeight) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint16(s.Kilograms); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type SetMinimapState struct {
-	Id uint8
-}
-
-func (SetMinimapState) Config() message.Config { return SetMinimapStateConfig }
-
-func (s SetMinimapState) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint8(s.Id); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type RelocateChildInterface struct {
-	ParentFrom uint32
-	ParentTo   uint32
-}
-
-func (RelocateChildInterface) Config() message.Config { return RelocateChildInterfaceConfig }
-
-func (r RelocateChildInterface) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint32(r.ParentTo); err != nil {
-		return err
-	}
-
-	if err := buf.PutUint32(r.ParentFrom); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type CloseChildInterface struct {
-	Parent uint32
-}
-
-func (CloseChildInterface) Config() message.Config { return CloseChildInterfaceConfig }
-
-func (c CloseChildInterface) Encode(buf *buffer.ByteBuffer) error {
-	if err := buf.PutUint32(c.Parent); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 type KeyTyped struct {
 }
 
@@ -1113,6 +679,16 @@ type MinimapWalk struct {
 	X             uint16
 	CtrlRunning   bool
 	CtrlShiftTele bool
+
+	// The state of the client when the minimap was clicked, the client sends it along with the destination so that
+	// the server can verify the click.
+	MouseOffsetX uint8
+	MouseOffsetY uint8
+	CameraYaw    uint16
+	MinimapAngle uint8
+	MinimapZoom  uint8
+	PlayerX      uint16
+	PlayerZ      uint16
 }
 
 func (MinimapWalk) Config() message.Config {
@@ -1139,27 +715,64 @@ func (m *MinimapWalk) Decode(buf *buffer.ByteBuffer, length int) (err error) {
 		m.CtrlRunning = true
 	}
 
-	if _, err = buf.GetUint8(); err != nil {
+	if m.MouseOffsetX, err = buf.GetUint8(); err != nil {
 		return
 	}
 
-	if _, err = buf.GetUint8(); err != nil {
+	if m.MouseOffsetY, err = buf.GetUint8(); err != nil {
 		return
 	}
 
-	if _, err = buf.GetUint16(); err != nil {
+	if m.CameraYaw, err = buf.GetUint16(); err != nil {
 		return
 	}
 
-	if _, err = buf.GetUint16(); err != nil {
+	if err = getMarker(buf, minimapMarkerYaw); err != nil {
 		return
 	}
 
-	if _, err = buf.GetUint16(); err != nil {
+	if m.MinimapAngle, err = buf.GetUint8(); err != nil {
 		return
 	}
 
-	return
+	if m.MinimapZoom, err = buf.GetUint8(); err != nil {
+		return
+	}
+
+	if err = getMarker(buf, minimapMarkerZoom); err != nil {
+		return
+	}
+
+	if m.PlayerX, err = buf.GetUint16(); err != nil {
+		return
+	}
+
+	if m.PlayerZ, err = buf.GetUint16(); err != nil {
+		return
+	}
+
+	return getMarker(buf, minimapMarkerPlayer)
+}
+
+// The constant bytes that the client writes after the camera yaw, the minimap zoom and the position of the player in
+// a minimap walk. They carry no information but a different value means that the payload is not laid out as expected.
+const (
+	minimapMarkerYaw    = 57
+	minimapMarkerZoom   = 89
+	minimapMarkerPlayer = 63
+)
+
+// Gets a constant marker byte, failing if it does not have the expected value.
+func getMarker(buf *buffer.ByteBuffer, expected uint8) error {
+	marker, err := buf.GetUint8()
+	if err != nil {
+		return err
+	}
+
+	if marker != expected {
+		return fmt.Errorf("gameold: unexpected marker %d, expected %d", marker, expected)
+	}
+	return nil
 }
 
 type WalkHere struct {
@@ -1195,30 +808,3 @@ func (w *WalkHere) Decode(buf *buffer.ByteBuffer, length int) (err error) {
 
 	return
 }
-
-type ExamineObject struct {
-	Id uint16
-}
-
-func (ExamineObject) Config() message.Config {
-	return ExamineObjectConfig
-}
-
-func (e *ExamineObject) Decode(buf *buffer.ByteBuffer, length int) (err error) {
-	if e.Id, err = buf.GetUint16(); err != nil {
-		return
-	}
-
-	return
-}
-
-type CameraRotated struct {
-}
-
-func (CameraRotated) Config() message.Config {
-	return CameraRotatedConfig
-}
-
-func (CameraRotated) Decode(buf *buffer.ByteBuffer, length int) error {
-	return nil
-}
@@ -0,0 +1,163 @@
+package gameold
+
+import (
+	"bytes"
+	"github.com/sprinkle-it/donut/account"
+	"github.com/sprinkle-it/donut/buffer"
+	"github.com/sprinkle-it/donut/message"
+	"github.com/sprinkle-it/donut/message/messagetest"
+	"reflect"
+	"testing"
+)
+
+// Decodes a single framed inbound message.
+func decode(t *testing.T, frame []byte) (message.Message, error) {
+	configs := make(map[uint8]message.Config)
+	for _, config := range inbound {
+		configs[config.Id] = config
+	}
+
+	input := buffer.NewRingBuffer(len(frame))
+	_, _ = input.Write(frame)
+
+	decoder := message.NewStreamDecoder(configs, 64)
+
+	msg, err := decoder.Decode(&input)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg == nil || buffer.HasReadable(&input) {
+		t.Fatalf("expected %v to be decoded as a single message", frame)
+	}
+	return msg, nil
+}
+
+func TestDefinitions(t *testing.T) {
+	if _, err := message.NewRegistry(Definitions...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInbound_Decode(t *testing.T) {
+	tests := map[string]struct {
+		frame    []byte
+		expected message.Message
+	}{
+		"camera rotated": {
+			[]byte{39, 0x01, 0x00, 0xff, 0x07},
+			&CameraRotated{Pitch: 0x0180, Yaw: 0x07ff},
+		},
+		"fixed window update": {
+			[]byte{35, 1, 0x02, 0xfd, 0x01, 0xf7},
+			&WindowUpdate{Resizable: false, Width: 765, Height: 503},
+		},
+		"resizable window update": {
+			[]byte{35, 2, 0x07, 0x80, 0x04, 0x38},
+			&WindowUpdate{Resizable: true, Width: 1920, Height: 1080},
+		},
+		"button pressed": {
+			[]byte{68, 0, 0x00, 0x95, 0x00, 0x05, 0xff, 0xff, 0xff, 0xff},
+			&ButtonPressed{Option: 0, Interface: 149, Component: 5, Slot: 0xffff, Item: 0xffff},
+		},
+		"button pressed in slot": {
+			[]byte{68, 3, 0x00, 0x95, 0x00, 0x00, 0x00, 0x1b, 0x03, 0xe3},
+			&ButtonPressed{Option: 3, Interface: 149, Component: 0, Slot: 27, Item: 995},
+		},
+		"walk here": {
+			[]byte{96, 5, 0x0c, 0xa2, 0x0c, 0x8f, 1},
+			&WalkHere{Z: 3234, X: 3215, CtrlRunning: true},
+		},
+		"minimap walk": {
+			[]byte{
+				52, 18,
+				0x0c, 0xa2, 0x0c, 0x8f, 2,
+				0x4a, 0x31, 0x05, 0x12,
+				57, 0x10, 0x03,
+				89, 0x0c, 0x90, 0x0c, 0x9c,
+				63,
+			},
+			&MinimapWalk{
+				Z: 3234, X: 3215, CtrlShiftTele: true,
+				MouseOffsetX: 0x4a, MouseOffsetY: 0x31, CameraYaw: 0x0512,
+				MinimapAngle: 0x10, MinimapZoom: 0x03, PlayerX: 3216, PlayerZ: 3228,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			msg, err := decode(t, test.frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(msg, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, msg)
+			}
+		})
+	}
+}
+
+func TestInbound_Decode_Malformed(t *testing.T) {
+	tests := map[string][]byte{
+		"unknown window mode":      {35, 0, 0x02, 0xfd, 0x01, 0xf7},
+		"unexpected yaw marker":    {52, 18, 0x0c, 0xa2, 0x0c, 0x8f, 0, 0x4a, 0x31, 0x05, 0x12, 0, 0x10, 0x03, 89, 0x0c, 0x90, 0x0c, 0x9c, 63},
+		"unexpected zoom marker":   {52, 18, 0x0c, 0xa2, 0x0c, 0x8f, 0, 0x4a, 0x31, 0x05, 0x12, 57, 0x10, 0x03, 0, 0x0c, 0x90, 0x0c, 0x9c, 63},
+		"unexpected player marker": {52, 18, 0x0c, 0xa2, 0x0c, 0x8f, 0, 0x4a, 0x31, 0x05, 0x12, 57, 0x10, 0x03, 89, 0x0c, 0x90, 0x0c, 0x9c, 0},
+	}
+
+	for name, frame := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decode(t, frame); err == nil {
+				t.Errorf("expected %v to fail to decode", frame)
+			}
+		})
+	}
+}
+
+func TestDisplaySystemMessage_Encode(t *testing.T) {
+	name := account.DisplayName("Sino")
+
+	tests := map[string]struct {
+		msg      DisplaySystemMessage
+		expected []byte
+	}{
+		"game message": {
+			DisplaySystemMessage{Type: 0, Text: "Hi"},
+			[]byte{0, 0, 'H', 'i', 0},
+		},
+		"trade request": {
+			DisplaySystemMessage{Type: 101, InteractingWith: &name, Text: "Hi"},
+			[]byte{101, 1, 'S', 'i', 'n', 'o', 0, 'H', 'i', 0},
+		},
+		"large type": {
+			DisplaySystemMessage{Type: 200, Text: ""},
+			[]byte{0x80, 200, 0, 0},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			buf := buffer.NewGrowableByteBuffer(0, 64)
+			if err := test.msg.Encode(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			if payload := buf.Bytes[:buf.Offset]; !bytes.Equal(payload, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, payload)
+			}
+		})
+	}
+}
+
+func TestOutbound_RoundTrip(t *testing.T) {
+	messagetest.RoundTrip(t, outbound)
+}
+
+func FuzzInbound_Decode(f *testing.F) {
+	messagetest.Seed(f, inbound)
+	f.Fuzz(func(t *testing.T, index uint8, payload []byte) {
+		messagetest.Decode(t, inbound, index, payload)
+	})
+}
@@ -151,7 +151,9 @@ func (u *PlayerUpdate) Encode(buf *buffer.ByteBuffer) error {
 type BlockList []SyncBlock
 
 func (l BlockList) Encode(buf *buffer.ByteBuffer) error {
-    buf.StartBitAccess()
+    if err := buf.StartBitAccess(); err != nil {
+        return err
+    }
 
     for _, block := range l {
         if err := block.Encode(buf); err != nil {
@@ -159,9 +161,7 @@ func (l BlockList) Encode(buf *buffer.ByteBuffer) error {
         }
     }
 
-    buf.FinishBitAccess()
-
-    return nil
+    return buf.FinishBitAccess()
 }
 
 func (l BlockList) Copy() BlockList {
@@ -181,20 +181,26 @@ type SkipBlock struct {
 }
 
 func (b SkipBlock) Encode(buf *buffer.ByteBuffer) error {
-    buf.PutBits(0, 1)
+    if err := buf.PutBits(0, 1); err != nil {
+        return err
+    }
+
     switch {
     case b.Count < 1:
-        buf.PutBits(uint32(b.Count), 2)
-        return nil
+        return buf.PutBits(uint32(b.Count), 2)
     case b.Count >= 1 && b.Count <= 31:
-        buf.PutBits(1, 2)
-        buf.PutBits(uint32(b.Count), 5)
+        return putSkipCount(buf, 1, b.Count, 5)
     case b.Count >= 32 && b.Count <= 255:
-        buf.PutBits(2, 2)
-        buf.PutBits(uint32(b.Count), 8)
+        return putSkipCount(buf, 2, b.Count, 8)
     default:
-        buf.PutBits(3, 2)
-        buf.PutBits(uint32(b.Count), 11)
+        return putSkipCount(buf, 3, b.Count, 11)
     }
-    return nil
+}
+
+// Puts the type of a skip count followed by the count encoded with the number of bits for the type.
+func putSkipCount(buf *buffer.ByteBuffer, kind uint32, count int, bits int) error {
+    if err := buf.PutBits(kind, 2); err != nil {
+        return err
+    }
+    return buf.PutBits(uint32(count), bits)
 }
@@ -16,14 +16,26 @@ func (p Position) ChunkZ() uint16 {
     return p.Z >> 3
 }
 
-func (p Position) EncodeHash(buf *buffer.ByteBuffer) {
-    buf.PutBits(uint32(p.Level), 2)
-    buf.PutBits(uint32(p.X), 14)
-    buf.PutBits(uint32(p.Z), 14)
+func (p Position) EncodeHash(buf *buffer.ByteBuffer) error {
+    if err := buf.PutBits(uint32(p.Level), 2); err != nil {
+        return err
+    }
+
+    if err := buf.PutBits(uint32(p.X), 14); err != nil {
+        return err
+    }
+
+    return buf.PutBits(uint32(p.Z), 14)
 }
 
-func (p Position) EncodeBlockHash(buf *buffer.ByteBuffer) {
-    buf.PutBits(uint32(p.Level), 2)
-    buf.PutBits(uint32(p.X >> 6), 8)
-    buf.PutBits(uint32(p.Z >> 6), 8)
+func (p Position) EncodeBlockHash(buf *buffer.ByteBuffer) error {
+    if err := buf.PutBits(uint32(p.Level), 2); err != nil {
+        return err
+    }
+
+    if err := buf.PutBits(uint32(p.X >> 6), 8); err != nil {
+        return err
+    }
+
+    return buf.PutBits(uint32(p.Z >> 6), 8)
 }
\ No newline at end of file
@@ -4,6 +4,9 @@ import (
     "github.com/sprinkle-it/donut/server"
 )
 
+// The stage clients are in once they have been registered to the world.
+const Stage server.Stage = "game"
+
 type Service struct {
 	capacity int
 	sessions map[uint64]*Session
@@ -35,6 +38,13 @@ func (s *Service) HandleMail(mail server.Mail) {
     s.execute(handleMessage{mail: mail})
 }
 
+func (s *Service) MailReceiver() server.MailReceiver {
+    return server.MailReceiver{
+        Handler: s.HandleMail,
+        Accept:  inbound,
+    }
+}
+
 type command interface {
 	execute(s *Service)
 }
@@ -64,6 +64,7 @@ func (w *World) registerPlayers() {
                 _ = msg.Source.SendNow(status.Full)
                 continue
             }
+            msg.Source.SetStage(Stage)
             player.Initialize()
         default:
             return
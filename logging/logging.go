@@ -0,0 +1,141 @@
+package logging
+
+import (
+    "go.uber.org/zap"
+    "go.uber.org/zap/zapcore"
+    "strings"
+    "sync"
+)
+
+// Levels holds the level of the root logger and the levels of named subsystem loggers. Every level is atomic so that
+// it can be changed while the application is running. A subsystem logger uses the level of the most specific name
+// that has a level set, for example entries from the logger named "file.session" use the level of "file.session" if
+// it is set, otherwise the level of "file", otherwise the root level.
+type Levels struct {
+    root  zap.AtomicLevel
+    named map[string]zap.AtomicLevel
+    mutex sync.RWMutex
+}
+
+func NewLevels(root zapcore.Level) *Levels {
+    return &Levels{
+        root:  zap.NewAtomicLevelAt(root),
+        named: make(map[string]zap.AtomicLevel),
+    }
+}
+
+// Gets the level of the root logger.
+func (l *Levels) Root() zap.AtomicLevel {
+    return l.root
+}
+
+// Gets the level for a subsystem. If the subsystem does not have a level set one is created with the level the
+// subsystem currently inherits.
+func (l *Levels) Level(name string) zap.AtomicLevel {
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+
+    if level, ok := l.named[name]; ok {
+        return level
+    }
+
+    level := zap.NewAtomicLevelAt(l.resolve(name).Level())
+    l.named[name] = level
+    return level
+}
+
+// Gets the level that a subsystem currently uses without setting one for it, which is the level of the most specific
+// name that has a level set.
+func (l *Levels) Resolve(name string) zapcore.Level {
+    l.mutex.RLock()
+    defer l.mutex.RUnlock()
+    return l.resolve(name).Level()
+}
+
+// Sets the level for a subsystem.
+func (l *Levels) SetLevel(name string, level zapcore.Level) {
+    l.Level(name).SetLevel(level)
+}
+
+// Gets the names and levels of all of the subsystems that have a level set.
+func (l *Levels) Named() map[string]zapcore.Level {
+    l.mutex.RLock()
+    defer l.mutex.RUnlock()
+
+    levels := make(map[string]zapcore.Level, len(l.named))
+    for name, level := range l.named {
+        levels[name] = level.Level()
+    }
+    return levels
+}
+
+// Resolves the level for a logger name by walking up the dotted name until a level is found. The mutex must be
+// held by the caller.
+func (l *Levels) resolve(name string) zap.AtomicLevel {
+    for name != "" {
+        if level, ok := l.named[name]; ok {
+            return level
+        }
+
+        i := strings.LastIndex(name, ".")
+        if i < 0 {
+            break
+        }
+        name = name[:i]
+    }
+    return l.root
+}
+
+// Checks if an entry from a logger with the given name and level should be logged.
+func (l *Levels) enabled(name string, level zapcore.Level) bool {
+    l.mutex.RLock()
+    defer l.mutex.RUnlock()
+    return l.resolve(name).Enabled(level)
+}
+
+// Checks if any logger would log an entry with the given level.
+func (l *Levels) anyEnabled(level zapcore.Level) bool {
+    l.mutex.RLock()
+    defer l.mutex.RUnlock()
+
+    if l.root.Enabled(level) {
+        return true
+    }
+
+    for _, named := range l.named {
+        if named.Enabled(level) {
+            return true
+        }
+    }
+    return false
+}
+
+// Builds the shared logger from the zap configuration. The level of the zap configuration is ignored in favour of the
+// given levels, services should derive their loggers from the returned logger with Named.
+func New(cfg zap.Config, levels *Levels) (*zap.Logger, error) {
+    cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+    return cfg.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+        return &levelCore{Core: core, levels: levels}
+    }))
+}
+
+// Core which filters entries by the level of the logger that created them.
+type levelCore struct {
+    zapcore.Core
+    levels *Levels
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+    return c.levels.anyEnabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+    return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+    if !c.levels.enabled(entry.LoggerName, entry.Level) {
+        return checked
+    }
+    return c.Core.Check(entry, checked)
+}
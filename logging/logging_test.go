@@ -0,0 +1,75 @@
+package logging
+
+import (
+    "go.uber.org/zap"
+    "go.uber.org/zap/zapcore"
+    "testing"
+)
+
+// Core which records the messages of every entry written to it.
+type recordingCore struct {
+    zapcore.LevelEnabler
+    messages *[]string
+}
+
+func (c recordingCore) With([]zapcore.Field) zapcore.Core { return c }
+
+func (c recordingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+    return checked.AddCore(entry, c)
+}
+
+func (c recordingCore) Write(entry zapcore.Entry, _ []zapcore.Field) error {
+    *c.messages = append(*c.messages, entry.LoggerName+": "+entry.Message)
+    return nil
+}
+
+func (recordingCore) Sync() error { return nil }
+
+func TestLevels(t *testing.T) {
+    var messages []string
+
+    levels := NewLevels(zapcore.InfoLevel)
+    levels.SetLevel("file", zapcore.DebugLevel)
+    levels.SetLevel("server.client", zapcore.WarnLevel)
+
+    core := recordingCore{LevelEnabler: zapcore.DebugLevel, messages: &messages}
+    logger := zap.New(&levelCore{Core: core, levels: levels})
+
+    logger.Named("file").Named("session").Debug("inherited")
+    logger.Named("server").Debug("filtered")
+    logger.Named("server").Info("root")
+    logger.Named("server").Named("client").Info("filtered")
+
+    levels.SetLevel("server", zapcore.DebugLevel)
+    logger.Named("server").Debug("changed")
+
+    expected := []string{"file.session: inherited", "server: root", "server: changed"}
+    if len(messages) != len(expected) {
+        t.Fatalf("expected %v, got %v", expected, messages)
+    }
+
+    for i := range expected {
+        if messages[i] != expected[i] {
+            t.Errorf("expected %v, got %v", expected, messages)
+        }
+    }
+}
+
+func TestLevels_Resolve(t *testing.T) {
+    levels := NewLevels(zapcore.InfoLevel)
+    levels.SetLevel("file", zapcore.DebugLevel)
+
+    for name, expected := range map[string]zapcore.Level{
+        "file":         zapcore.DebugLevel,
+        "file.session": zapcore.DebugLevel,
+        "server":       zapcore.InfoLevel,
+    } {
+        if level := levels.Resolve(name); level != expected {
+            t.Errorf("expected %s to resolve to %v, got %v", name, expected, level)
+        }
+    }
+
+    if named := levels.Named(); len(named) != 1 {
+        t.Errorf("expected resolving levels not to set any, got %v", named)
+    }
+}
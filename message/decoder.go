@@ -1,8 +1,10 @@
 package message
 
 import (
+    "errors"
     "fmt"
     "github.com/sprinkle-it/donut/buffer"
+    "io"
 )
 
 type StreamDecoderState int
@@ -13,38 +15,117 @@ const (
     AwaitBytes       = 2
 )
 
+var (
+    // The identifier of a message is not recognized by the decoder. The size of the message is not known so the
+    // stream can only continue to be decoded if the message is skipped.
+    ErrUnrecognized = errors.New("message: unrecognized message")
+
+    // An unrecognized message was skipped.
+    ErrSkipped = errors.New("message: skipped unrecognized message")
+)
+
+// An error for a single message that leaves the decoder at the start of the next message, so that the stream can
+// continue to be decoded if the error is tolerated. Errors that are not decode errors leave the stream out of sync and
+// must be treated as fatal.
+type DecodeError struct {
+    // The identifier of the message.
+    Id uint8
+
+    // A copy of the raw payload of the message, nil if the message was unrecognized.
+    Payload []byte
+
+    // The cause of the error, either ErrUnrecognized, ErrSkipped or the error returned by the message.
+    Err error
+}
+
+func (e *DecodeError) Error() string {
+    switch e.Err {
+    case ErrUnrecognized:
+        return fmt.Sprintf("message: unrecognized message %d", e.Id)
+    case ErrSkipped:
+        return fmt.Sprintf("message: skipped unrecognized message %d", e.Id)
+    default:
+        return fmt.Sprintf("message: failed to decode message %d: %v", e.Id, e.Err)
+    }
+}
+
+func (e *DecodeError) Unwrap() error {
+    return e.Err
+}
+
+// Decodes messages from a stream of bytes. Payloads are decoded in place when the readable buffer is Peekable and
+// stores the payload contiguously, otherwise they are copied into a pooled payload which is released once the message
+// has been decoded. Messages that keep references to their payload must implement PayloadOwner.
 type StreamDecoder struct {
     configs        map[uint8]Config
     messageConfig  Config
     receivedLength int
     state          StreamDecoderState
-    buffer         []byte
+
+    // Whether the identifier that was just read is unrecognized, which allows it to be skipped.
+    unrecognized bool
+
+    // Whether the payload of the current message is skipped rather than decoded.
+    skip bool
+
+    // Buffer for reading the identifier and length of messages.
+    header [2]byte
+
+    // The largest payload that can be decoded.
+    capacity int
 }
 
 func NewStreamDecoder(configs map[uint8]Config, capacity int) StreamDecoder {
     return StreamDecoder{
-        configs: configs,
-        state:   DecodeIdentifier,
-        buffer:  make([]byte, capacity),
+        configs:  configs,
+        state:    DecodeIdentifier,
+        capacity: capacity,
+    }
+}
+
+// Skips the unrecognized message that was reported by the last call to Decode, treating it as a message of the given
+// size. The skipped payload is reported by a DecodeError wrapping ErrSkipped once it has been read.
+func (d *StreamDecoder) Skip(size Size) error {
+    if !d.unrecognized {
+        return errors.New("message: can only skip a message that is unrecognized")
+    }
+
+    if size < SizeVariableShort {
+        return fmt.Errorf("message: cannot skip message %d with invalid size %d", d.header[0], size)
     }
+
+    d.unrecognized = false
+    d.skip = true
+    d.messageConfig = Config{Id: d.header[0], Size: size}
+    d.state = DecodeLength
+    return nil
+}
+
+// Sets the configurations that messages are decoded with. Must only be called between messages, which is whenever
+// Decode has just returned a message.
+func (d *StreamDecoder) SetConfigs(configs map[uint8]Config) {
+    d.configs = configs
 }
 
 func (d *StreamDecoder) Decode(r buffer.Readable) (Message, error) {
+    d.unrecognized = false
+
     switch d.state {
     case DecodeIdentifier:
         if !buffer.HasReadable(r) {
             return nil, nil
         }
 
-        if _, err := r.Read(d.buffer[:1]); err != nil {
+        if _, err := r.Read(d.header[:1]); err != nil {
             return nil, err
         }
 
-        id := d.buffer[0]
+        id := d.header[0]
 
         config, ok := d.configs[id]
         if !ok {
-            return nil, fmt.Errorf("message: unrecognized message %d", id)
+            d.unrecognized = true
+            return nil, &DecodeError{Id: id, Err: ErrUnrecognized}
         }
 
         d.state = DecodeLength
@@ -57,21 +138,21 @@ func (d *StreamDecoder) Decode(r buffer.Readable) (Message, error) {
                 return nil, nil
             }
 
-            if _, err := r.Read(d.buffer[:1]); err != nil {
+            if _, err := r.Read(d.header[:1]); err != nil {
                 return nil, err
             }
 
-            d.receivedLength = int(d.buffer[0])
+            d.receivedLength = int(d.header[0])
         case SizeVariableShort:
             if !buffer.IsReadable(r, 2) {
                 return nil, nil
             }
 
-            if _, err := r.Read(d.buffer[:2]); err != nil {
+            if _, err := r.Read(d.header[:2]); err != nil {
                 return nil, err
             }
 
-            d.receivedLength = int(uint16(d.buffer[0])<<8 | uint16(d.buffer[1]))
+            d.receivedLength = int(uint16(d.header[0])<<8 | uint16(d.header[1]))
         default:
             d.receivedLength = int(d.messageConfig.Size)
         }
@@ -79,24 +160,88 @@ func (d *StreamDecoder) Decode(r buffer.Readable) (Message, error) {
         d.state = AwaitBytes
         fallthrough
     case AwaitBytes:
+        // Messages that can never fit into the buffer cannot be decoded, otherwise the decoder would wait forever
+        // for bytes that can never be buffered.
+        if d.receivedLength > d.capacity {
+            return nil, fmt.Errorf("message: length %d of message %d exceeds capacity %d",
+                d.receivedLength, d.messageConfig.Id, d.capacity)
+        }
+
         if !buffer.IsReadable(r, d.receivedLength) {
             return nil, nil
         }
 
-        if _, err := r.Read(d.buffer[:d.receivedLength]); err != nil {
-            return nil, err
+        if d.skip {
+            payload := make([]byte, d.receivedLength)
+            if _, err := io.ReadFull(r, payload); err != nil {
+                return nil, err
+            }
+
+            d.skip = false
+            d.state = DecodeIdentifier
+            return nil, &DecodeError{Id: d.messageConfig.Id, Payload: payload, Err: ErrSkipped}
         }
 
-        msg := d.messageConfig.New().(Inbound)
-        buf := buffer.ByteBuffer{Bytes:d.buffer[:d.receivedLength]}
+        msg, ok := d.messageConfig.New().(Inbound)
+        if !ok {
+            return nil, fmt.Errorf("message: message %d is not an inbound message", d.messageConfig.Id)
+        }
 
-        if err := msg.Decode(&buf, d.receivedLength); err != nil {
+        if err := d.decodePayload(r, msg); err != nil {
             return nil, err
         }
 
         d.state = DecodeIdentifier
         return msg, nil
     default:
-        panic("Unexpected packet decoder state")
+        return nil, fmt.Errorf("message: unexpected decoder state %d", d.state)
+    }
+}
+
+// Decodes the payload of a message from the readable buffer. The payload must be readable.
+func (d *StreamDecoder) decodePayload(r buffer.Readable, msg Inbound) error {
+    owner, owns := msg.(PayloadOwner)
+
+    if p, ok := r.(buffer.Peekable); ok && !owns {
+        if bytes := p.PeekContiguous(d.receivedLength); bytes != nil {
+            // The payload is consumed even if decoding fails, the same as when it is copied out of the buffer.
+            buf := buffer.ByteBuffer{Bytes: bytes}
+            err := msg.Decode(&buf, d.receivedLength)
+            if err != nil {
+                err = d.failed(bytes, err)
+            }
+
+            if discardErr := p.Discard(d.receivedLength); discardErr != nil {
+                return discardErr
+            }
+            return err
+        }
+    }
+
+    payload := NewPayload(d.receivedLength)
+    if _, err := io.ReadFull(r, payload.Bytes()); err != nil {
+        payload.Release()
+        return err
     }
+
+    buf := buffer.ByteBuffer{Bytes: payload.Bytes()}
+    if err := msg.Decode(&buf, d.receivedLength); err != nil {
+        err = d.failed(payload.Bytes(), err)
+        payload.Release()
+        return err
+    }
+
+    if owns {
+        owner.OwnPayload(payload)
+    } else {
+        payload.Release()
+    }
+    return nil
+}
+
+// Creates the error for a message that failed to decode. The payload has been consumed so the decoder moves on to the
+// next message.
+func (d *StreamDecoder) failed(payload []byte, err error) error {
+    d.state = DecodeIdentifier
+    return &DecodeError{Id: d.messageConfig.Id, Payload: append([]byte(nil), payload...), Err: err}
 }
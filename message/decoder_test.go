@@ -0,0 +1,270 @@
+package message
+
+import (
+    "bytes"
+    "errors"
+    "github.com/sprinkle-it/donut/buffer"
+    "testing"
+)
+
+// Inbound message that skips over its payload.
+type skipped struct{ config Config }
+
+func (s *skipped) Config() Config { return s.config }
+
+func (s *skipped) Decode(buf *buffer.ByteBuffer, length int) error { return buf.Skip(length) }
+
+// Outbound message which is incorrectly configured to be decoded.
+type outboundOnly struct{}
+
+func (outboundOnly) Config() Config { return Config{Id: 4, Direction: DirectionOutbound} }
+
+func (outboundOnly) Encode(*buffer.ByteBuffer) error { return nil }
+
+func testConfigs() map[uint8]Config {
+    configs := make(map[uint8]Config)
+    for _, config := range []Config{
+        {Id: 0, Size: 0},
+        {Id: 1, Size: 3},
+        {Id: 2, Size: SizeVariableByte},
+        {Id: 3, Size: SizeVariableShort},
+    } {
+        config := config
+        config.Direction = DirectionInbound
+        config.New = func() Message { return &skipped{config: config} }
+        configs[config.Id] = config
+    }
+    // A misconfigured message that is declared as inbound but does not implement Inbound.
+    configs[4] = Config{Id: 4, Size: 0, Direction: DirectionInbound, New: Singleton(outboundOnly{})}
+    return configs
+}
+
+func TestStreamDecoder_Decode_ExceedsCapacity(t *testing.T) {
+    decoder := NewStreamDecoder(testConfigs(), 16)
+
+    input := buffer.NewRingBuffer(16)
+    _, _ = input.Write([]byte{3, 0xff, 0xff})
+
+    if _, err := decoder.Decode(&input); err == nil {
+        t.Error("expected an error for a message that exceeds the decoder capacity")
+    }
+}
+
+func TestStreamDecoder_Decode_NotInbound(t *testing.T) {
+    decoder := NewStreamDecoder(testConfigs(), 16)
+
+    input := buffer.NewRingBuffer(16)
+    _, _ = input.Write([]byte{4})
+
+    if _, err := decoder.Decode(&input); err == nil {
+        t.Error("expected an error for a message that is not inbound")
+    }
+}
+
+func FuzzStreamDecoder_Decode(f *testing.F) {
+    f.Add([]byte{0, 1, 1, 2, 3, 2, 0, 0, 3, 0, 1, 0}, uint8(4))
+    f.Add([]byte{3, 0xff, 0xff, 0}, uint8(1))
+    f.Add([]byte{255}, uint8(16))
+
+    f.Fuzz(func(t *testing.T, b []byte, chunk uint8) {
+        decoder := NewStreamDecoder(testConfigs(), 64)
+        input := buffer.NewRingBuffer(64)
+
+        if chunk == 0 {
+            chunk = 1
+        }
+
+        // Feed the bytes in chunks to exercise messages that are split over multiple reads.
+        for len(b) > 0 {
+            n := int(chunk)
+            if n > len(b) {
+                n = len(b)
+            }
+
+            written, _ := input.Write(b[:n])
+            b = b[written:]
+
+            for buffer.HasReadable(&input) {
+                msg, err := decoder.Decode(&input)
+                if err != nil {
+                    return
+                }
+
+                if msg == nil {
+                    break
+                }
+            }
+
+            if written == 0 {
+                return
+            }
+        }
+    })
+}
+
+// Inbound message that keeps a reference to its payload.
+type retained struct {
+    bytes   []byte
+    payload *Payload
+}
+
+func (*retained) Config() Config { return Config{Id: 5, Size: SizeVariableByte, Direction: DirectionInbound} }
+
+func (r *retained) Decode(buf *buffer.ByteBuffer, length int) error {
+    r.bytes = buf.Bytes[buf.Offset : buf.Offset+length]
+    return buf.Skip(length)
+}
+
+func (r *retained) OwnPayload(payload *Payload) { r.payload = payload }
+
+func TestStreamDecoder_Decode_PayloadOwner(t *testing.T) {
+    configs := testConfigs()
+    configs[5] = Config{Id: 5, Size: SizeVariableByte, Direction: DirectionInbound, New: func() Message {
+        return &retained{}
+    }}
+
+    decoder := NewStreamDecoder(configs, 16)
+
+    input := buffer.NewRingBuffer(16)
+    _, _ = input.Write([]byte{5, 3, 1, 2, 3})
+
+    msg, err := decoder.Decode(&input)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Fill the whole input buffer, which wraps around and overwrites the region that the message was read from, so
+    // that a message referencing the input buffer would be corrupted.
+    if n, _ := input.Write(bytes.Repeat([]byte{0xff}, input.Capacity())); n != input.Capacity() {
+        t.Fatalf("expected to overwrite all %d bytes of the input buffer, wrote %d", input.Capacity(), n)
+    }
+
+    r := msg.(*retained)
+    if r.payload == nil {
+        t.Fatal("expected the message to be handed ownership of its payload")
+    }
+
+    if !bytes.Equal(r.bytes, []byte{1, 2, 3}) {
+        t.Errorf("expected the retained bytes to be 010203, got %x", r.bytes)
+    }
+
+    r.payload.Release()
+}
+
+func TestStreamDecoder_Decode_Wrapped(t *testing.T) {
+    decoder := NewStreamDecoder(testConfigs(), 8)
+    input := buffer.NewRingBuffer(8)
+
+    // Move the positions of the buffer so that the next message wraps around the end of the buffer.
+    _, _ = input.Write(make([]byte, 6))
+    _ = input.Discard(6)
+    _, _ = input.Write([]byte{1, 1, 2, 3, 0})
+
+    for i := 0; i < 2; i++ {
+        msg, err := decoder.Decode(&input)
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        if msg == nil {
+            t.Fatalf("expected message %d to be decoded", i)
+        }
+    }
+
+    if buffer.HasReadable(&input) {
+        t.Errorf("expected all of the bytes to be consumed, %d were left", input.Readable())
+    }
+}
+
+// Inbound message whose payload always fails to decode.
+type malformed struct{}
+
+func (malformed) Config() Config { return Config{Id: 6, Size: 2, Direction: DirectionInbound} }
+
+func (malformed) Decode(*buffer.ByteBuffer, int) error { return errors.New("malformed") }
+
+func TestStreamDecoder_Decode_Skip(t *testing.T) {
+    decoder := NewStreamDecoder(testConfigs(), 16)
+    input := buffer.NewRingBuffer(16)
+    _, _ = input.Write([]byte{200, 7, 8, 0})
+
+    _, err := decoder.Decode(&input)
+    if decodeErr, ok := err.(*DecodeError); !ok || decodeErr.Err != ErrUnrecognized || decodeErr.Id != 200 {
+        t.Fatalf("expected message 200 to be unrecognized, got %v", err)
+    }
+
+    if err := decoder.Skip(2); err != nil {
+        t.Fatal(err)
+    }
+
+    _, err = decoder.Decode(&input)
+    decodeErr, ok := err.(*DecodeError)
+    if !ok || decodeErr.Err != ErrSkipped || !bytes.Equal(decodeErr.Payload, []byte{7, 8}) {
+        t.Fatalf("expected the payload of message 200 to be skipped, got %v", err)
+    }
+
+    if msg, err := decoder.Decode(&input); err != nil || msg == nil {
+        t.Fatalf("expected the message after the skipped message to be decoded, got %v", err)
+    }
+
+    if err := decoder.Skip(2); err == nil {
+        t.Error("expected an error for skipping a recognized message")
+    }
+}
+
+func TestStreamDecoder_Decode_Malformed(t *testing.T) {
+    configs := testConfigs()
+    configs[6] = Config{Id: 6, Size: 2, Direction: DirectionInbound, New: func() Message { return malformed{} }}
+
+    decoder := NewStreamDecoder(configs, 16)
+    input := buffer.NewRingBuffer(16)
+    _, _ = input.Write([]byte{6, 1, 2, 0})
+
+    _, err := decoder.Decode(&input)
+    decodeErr, ok := err.(*DecodeError)
+    if !ok || decodeErr.Id != 6 || !bytes.Equal(decodeErr.Payload, []byte{1, 2}) {
+        t.Fatalf("expected message 6 to fail to decode with its payload, got %v", err)
+    }
+
+    if msg, err := decoder.Decode(&input); err != nil || msg == nil {
+        t.Fatalf("expected the message after the malformed message to be decoded, got %v", err)
+    }
+}
+
+// Benchmarks decoding messages whose payloads are stored contiguously in the input buffer.
+func BenchmarkStreamDecoder_Decode_Contiguous(b *testing.B) {
+    benchmarkDecode(b, 0)
+}
+
+// Benchmarks decoding messages whose payloads wrap around the end of the input buffer and must be copied.
+func BenchmarkStreamDecoder_Decode_Wrapped(b *testing.B) {
+    benchmarkDecode(b, 64)
+}
+
+func benchmarkDecode(b *testing.B, offset int) {
+    decoder := NewStreamDecoder(testConfigs(), 256)
+    input := buffer.NewRingBuffer(256)
+
+    frame := append([]byte{2, 128}, make([]byte, 128)...)
+
+    b.ReportAllocs()
+    b.SetBytes(int64(len(frame)))
+
+    padding := make([]byte, 257-offset)
+
+    for i := 0; i < b.N; i++ {
+        // Position the buffer so that the payload either starts at the beginning of the buffer or wraps around its end.
+        b.StopTimer()
+        input = buffer.NewRingBuffer(256)
+        if offset > 0 {
+            _, _ = input.Write(padding)
+            _ = input.Discard(len(padding))
+        }
+        _, _ = input.Write(frame)
+        b.StartTimer()
+
+        if _, err := decoder.Decode(&input); err != nil {
+            b.Fatal(err)
+        }
+    }
+}
@@ -1,33 +1,90 @@
 package message
 
-import "github.com/sprinkle-it/donut/buffer"
+import (
+    "fmt"
+    "github.com/sprinkle-it/donut/buffer"
+    "io"
+    "sync"
+)
+
+// The initial capacity of the buffers that messages are encoded into. Buffers grow past this capacity for larger
+// messages, up to the maximum length of the size of the message being encoded.
+const initialEncodeCapacity = 4096
+
+// Pool of the buffers that messages are encoded into, shared by all encoders so that buffers that have grown to encode
+// a large message are reused instead of being allocated for every large message.
+var encodeBuffers = sync.Pool{
+    New: func() interface{} {
+        bytes := make([]byte, initialEncodeCapacity)
+        return &bytes
+    },
+}
 
 type StreamEncoder struct {
-    buffer []byte
+    // The protocol that messages are encoded with. Messages are encoded with their own configuration if nil.
+    protocol *Protocol
 }
 
-func NewStreamEncoder(capacity int) StreamEncoder {
-    return StreamEncoder{buffer: make([]byte, capacity)}
+func NewStreamEncoder() StreamEncoder {
+    return StreamEncoder{}
 }
 
-func (e *StreamEncoder) Encode(msg Outbound, output *buffer.RingBuffer) error {
-    buf := buffer.ByteBuffer{Bytes:e.buffer}
+// Sets the protocol that messages are encoded with.
+func (e *StreamEncoder) SetProtocol(protocol *Protocol) {
+    e.protocol = protocol
+}
+
+// Encodes a message with its identifier and length header and writes the result to the writer. Returns an error
+// without writing anything if the message does not fit in its size.
+func (e *StreamEncoder) Encode(msg Outbound, w io.Writer) error {
+    config := e.protocol.Outbound(msg)
+    size := config.Size
+
+    bytes := encodeBuffers.Get().(*[]byte)
+    defer encodeBuffers.Put(bytes)
 
-    if err := buf.PutUint8(uint8(msg.Config().Id)); err != nil {
+    // The buffer may grow up to the length of the identifier, the length header and the largest payload of the size.
+    // Pooled buffers can be longer than the limit so they are cut down to it, growing within their capacity is free.
+    limit := 1 + size.encodedLength() + size.MaximumLength()
+
+    initial := len(*bytes)
+    if initial > limit {
+        initial = limit
+    }
+
+    buf := buffer.ByteBuffer{Bytes: (*bytes)[:initial], Limit: limit}
+
+    if err := buf.PutUint8(config.Id); err != nil {
         return err
     }
 
     // Mark where we start beginning writing the message so that we can determine the length of the message.
     start := buf.Offset
 
-    // Skip over where we need to write the length of the packet. The number of bytes that dictate the length
+    // Reserve where we need to write the length of the packet. The number of bytes that dictate the length
     // is determined by the size of the packet.
-    size := msg.Config().Size
-    if err := buf.Skip(size.encodedLength()); err != nil {
-        return err
+    switch size {
+    case SizeVariableByte:
+        if err := buf.PutUint8(0); err != nil {
+            return err
+        }
+    case SizeVariableShort:
+        if err := buf.PutUint16(0); err != nil {
+            return err
+        }
     }
 
-    if err := msg.Encode(&buf); err != nil {
+    err := msg.Encode(&buf)
+
+    // Keep the buffer if it grew so that the next large message does not need to grow it again.
+    *bytes = buf.Bytes[:cap(buf.Bytes)]
+
+    if err == buffer.ErrLimitExceeded {
+        return fmt.Errorf("message: message %d is larger than the maximum of %d bytes for its size",
+            config.Id, size.MaximumLength())
+    }
+
+    if err != nil {
         return err
     }
 
@@ -35,6 +92,10 @@ func (e *StreamEncoder) Encode(msg Outbound, output *buffer.RingBuffer) error {
 
     length := end - start - size.encodedLength()
 
+    if size >= 0 && length != int(size) {
+        return fmt.Errorf("message: message %d encoded %d bytes but has a fixed size of %d", config.Id, length, size)
+    }
+
     // Move back to the start of the message and write the length.
     buf.Offset = start
 
@@ -51,8 +112,8 @@ func (e *StreamEncoder) Encode(msg Outbound, output *buffer.RingBuffer) error {
         // Statically sized messages do not need their length to be encoded.
     }
 
-    // Write the message bytes to the buffer.
-    if _, err := output.Write(e.buffer[:end]); err != nil {
+    // Write the message bytes to the writer.
+    if _, err := w.Write(buf.Bytes[:end]); err != nil {
         return err
     }
 
@@ -0,0 +1,61 @@
+package message
+
+import (
+    "bytes"
+    "github.com/sprinkle-it/donut/buffer"
+    "testing"
+)
+
+// Outbound message that encodes a payload of a given length.
+type sized struct {
+    size   Size
+    length int
+}
+
+func (s sized) Config() Config { return Config{Id: 7, Size: s.size, Direction: DirectionOutbound} }
+
+func (s sized) Encode(buf *buffer.ByteBuffer) error {
+    for i := 0; i < s.length; i++ {
+        if err := buf.PutUint8(uint8(i)); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func TestStreamEncoder_Encode_Large(t *testing.T) {
+    encoder := NewStreamEncoder()
+
+    var output bytes.Buffer
+    if err := encoder.Encode(sized{size: SizeVariableShort, length: 0xffff}, &output); err != nil {
+        t.Fatal(err)
+    }
+
+    if output.Len() != 3+0xffff {
+        t.Fatalf("expected %d bytes to be written, got %d", 3+0xffff, output.Len())
+    }
+
+    if header := output.Bytes()[:3]; !bytes.Equal(header, []byte{7, 0xff, 0xff}) {
+        t.Errorf("expected header 07ffff, got %x", header)
+    }
+}
+
+func TestStreamEncoder_Encode_ExceedsSize(t *testing.T) {
+    encoder := NewStreamEncoder()
+
+    for _, msg := range []sized{
+        {size: SizeVariableByte, length: 0x100},
+        {size: SizeVariableShort, length: 0x10000},
+        {size: 4, length: 5},
+        {size: 4, length: 3},
+    } {
+        var output bytes.Buffer
+        if err := encoder.Encode(msg, &output); err == nil {
+            t.Errorf("expected an error encoding %d bytes for size %d", msg.length, msg.size)
+        }
+
+        if output.Len() != 0 {
+            t.Errorf("expected nothing to be written when encoding fails, got %d bytes", output.Len())
+        }
+    }
+}
@@ -1,6 +1,7 @@
 package message
 
 import (
+    "fmt"
     "github.com/sprinkle-it/donut/buffer"
 )
 
@@ -13,6 +14,19 @@ const (
     SizeVariableShort = -2
 )
 
+// Gets the maximum number of bytes that the payload of a message with this size can have. Variable sizes are limited
+// by the largest length that can be encoded in their length header, fixed sizes by the size itself.
+func (s Size) MaximumLength() int {
+    switch s {
+    case SizeVariableByte:
+        return 0xff
+    case SizeVariableShort:
+        return 0xffff
+    default:
+        return int(s)
+    }
+}
+
 func (s Size) encodedLength() int {
     switch s {
     case SizeVariableByte:
@@ -24,10 +38,67 @@ func (s Size) encodedLength() int {
     }
 }
 
+// The direction that a message is sent in.
+type Direction int
+
+const (
+    // Messages sent by clients to the server, which must implement Inbound.
+    DirectionInbound Direction = iota + 1
+
+    // Messages sent by the server to clients, which must implement Outbound.
+    DirectionOutbound
+)
+
+func (d Direction) String() string {
+    switch d {
+    case DirectionInbound:
+        return "inbound"
+    case DirectionOutbound:
+        return "outbound"
+    default:
+        return fmt.Sprintf("Direction(%d)", int(d))
+    }
+}
+
+// Configuration of a message. Identifiers only need to be unique within a direction, an inbound and an outbound
+// message can share one because they are never decoded by the same end of a connection.
 type Config struct {
-    Id   uint8
-    Size Size
-    New  func() Message
+    Id        uint8
+    Size      Size
+    Direction Direction
+    New       func() Message
+}
+
+// Validates that the configuration is usable. The message created by the configuration is checked to implement the
+// interface of its direction so that misconfigured messages are found at startup instead of when they are first sent
+// or received.
+func (c Config) Validate() error {
+    if c.New == nil {
+        return fmt.Errorf("message: message %d has no constructor", c.Id)
+    }
+
+    msg := c.New()
+    if msg == nil {
+        return fmt.Errorf("message: constructor of message %d returned nil", c.Id)
+    }
+
+    if c.Size < SizeVariableShort {
+        return fmt.Errorf("message: message %d (%T) has invalid size %d", c.Id, msg, c.Size)
+    }
+
+    switch c.Direction {
+    case DirectionInbound:
+        if _, ok := msg.(Inbound); !ok {
+            return fmt.Errorf("message: inbound message %d (%T) does not implement Inbound", c.Id, msg)
+        }
+    case DirectionOutbound:
+        if _, ok := msg.(Outbound); !ok {
+            return fmt.Errorf("message: outbound message %d (%T) does not implement Outbound", c.Id, msg)
+        }
+    default:
+        return fmt.Errorf("message: message %d (%T) has no direction", c.Id, msg)
+    }
+    return nil
 }
 
 type Message interface {
@@ -41,7 +112,9 @@ type Inbound interface {
     // decode the message, if not then this function will return an error. If this function returns no error then
     // decoding the message was successful. Implementations of this method are expected not to validate the decoded
     // data to any contextual source. Exceptions to this include where there are checks to assure that the message is
-    // valid. Any other validation should be done outside of the message by receivers of the message.
+    // valid. Any other validation should be done outside of the message by receivers of the message. The bytes of the
+    // buffer are only valid for the duration of the call, messages that keep references to them must implement
+    // PayloadOwner.
     Decode(buf *buffer.ByteBuffer, length int) error
 }
 
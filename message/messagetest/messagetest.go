@@ -0,0 +1,197 @@
+// Package messagetest provides property based and fuzz testing utilities for message configurations.
+package messagetest
+
+import (
+    "fmt"
+    "github.com/sprinkle-it/donut/buffer"
+    "github.com/sprinkle-it/donut/message"
+    "math/rand"
+    "reflect"
+    "testing"
+    "testing/quick"
+)
+
+// Number of random values that are round tripped for each message configuration.
+const iterations = 50
+
+// Inbound message that captures the raw payload that it was decoded from.
+type raw struct {
+    config  message.Config
+    payload []byte
+}
+
+func (r *raw) Config() message.Config { return r.config }
+
+func (r *raw) Decode(buf *buffer.ByteBuffer, length int) error {
+    r.payload = append([]byte(nil), buf.Bytes[buf.Offset:buf.Offset+length]...)
+    return buf.Skip(length)
+}
+
+// Creates a configuration that decodes the raw payload of messages matching the given configuration.
+func rawConfig(config message.Config) message.Config {
+    rc := config
+    rc.New = func() message.Message { return &raw{config: config} }
+    return rc
+}
+
+// Generates a random value for a message type. Messages which have fields that cannot be randomly generated, such as
+// interfaces, are left with the value created by the configuration.
+func generate(config message.Config, rnd *rand.Rand) message.Message {
+    msg := config.New()
+
+    t := reflect.TypeOf(msg)
+    if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct || t.Elem().NumField() == 0 {
+        return msg
+    }
+
+    v, ok := quick.Value(t.Elem(), rnd)
+    if !ok {
+        return msg
+    }
+
+    ptr := reflect.New(t.Elem())
+    ptr.Elem().Set(v)
+
+    generated, ok := ptr.Interface().(message.Message)
+    if !ok {
+        return msg
+    }
+    return generated
+}
+
+// Gets the number of bytes that a message encodes to.
+func encodedLength(out message.Outbound) (int, error) {
+    buf := buffer.NewByteBuffer(1 << 20)
+    if err := out.Encode(&buf); err != nil {
+        return 0, err
+    }
+    return buf.Offset, nil
+}
+
+// Encodes and frames a message then decodes the frame and checks that the identifier and length survive the round
+// trip. Values are only compared when the configuration creates a message of the same type that is also inbound,
+// the payload is then decoded into a new message and compared with the original. Messages that are decoded as a
+// different type are only checked for their framing. Messages that are too large for their size must fail to encode.
+func roundTrip(config message.Config, out message.Outbound) error {
+    length, err := encodedLength(out)
+    if err != nil {
+        return fmt.Errorf("failed to encode: %v", err)
+    }
+
+    encoder := message.NewStreamEncoder()
+    stream := buffer.NewRingBuffer(65536 + 3)
+
+    err = encoder.Encode(out, &stream)
+    if length > config.Size.MaximumLength() {
+        if err == nil {
+            return fmt.Errorf("encoded %d bytes for a message with a maximum length of %d", length,
+                config.Size.MaximumLength())
+        }
+        return nil
+    }
+
+    if err != nil {
+        return fmt.Errorf("failed to encode: %v", err)
+    }
+
+    configs := map[uint8]message.Config{config.Id: rawConfig(config)}
+    decoder := message.NewStreamDecoder(configs, 65536)
+
+    decoded, err := decoder.Decode(&stream)
+    if err != nil {
+        return fmt.Errorf("failed to decode frame: %v", err)
+    }
+
+    if decoded == nil {
+        return fmt.Errorf("encoded frame was incomplete")
+    }
+
+    if buffer.HasReadable(&stream) {
+        return fmt.Errorf("%d bytes were left over after decoding the frame", stream.Readable())
+    }
+
+    payload := decoded.(*raw).payload
+    if config.Size >= 0 && len(payload) != int(config.Size) {
+        return fmt.Errorf("encoded %d bytes for a message with a fixed size of %d", len(payload), config.Size)
+    }
+
+    in, ok := config.New().(message.Inbound)
+    if !ok || reflect.TypeOf(in) != reflect.TypeOf(out) {
+        return nil
+    }
+
+    buf := buffer.ByteBuffer{Bytes: payload}
+    if err := in.Decode(&buf, len(payload)); err != nil {
+        return fmt.Errorf("failed to decode payload: %v", err)
+    }
+
+    if !reflect.DeepEqual(in, out) {
+        return fmt.Errorf("decoded %+v does not match encoded %+v", in, out)
+    }
+
+    return nil
+}
+
+// Round trips random values of every outbound message in the given configurations, see roundTrip for what is checked.
+// Inbound only configurations are skipped.
+func RoundTrip(t *testing.T, configs []message.Config) {
+    rnd := rand.New(rand.NewSource(1))
+
+    for _, config := range configs {
+        if _, ok := config.New().(message.Outbound); !ok {
+            continue
+        }
+
+        config := config
+        t.Run(fmt.Sprintf("%d/%T", config.Id, config.New()), func(t *testing.T) {
+            for i := 0; i < iterations; i++ {
+                out := generate(config, rnd).(message.Outbound)
+                if err := roundTrip(config, out); err != nil {
+                    t.Fatal(err)
+                }
+            }
+        })
+    }
+}
+
+// Seeds a fuzz target for decoding inbound messages. Each configuration is seeded with a payload of zeros of its
+// size, or of a few bytes if the message is variably sized.
+func Seed(f *testing.F, configs []message.Config) {
+    for i, config := range configs {
+        length := int(config.Size)
+        if config.Size < 0 {
+            length = 8
+        }
+        f.Add(uint8(i), make([]byte, length))
+    }
+}
+
+// Decodes a fuzzed payload with one of the inbound configurations. Decoding must never panic and a message that is
+// decoded without an error must not have read past the payload. Whether a payload has enough bytes is left to the
+// message, an error is accepted for any payload.
+func Decode(t *testing.T, configs []message.Config, index uint8, payload []byte) {
+    if len(configs) == 0 {
+        return
+    }
+
+    config := configs[int(index)%len(configs)]
+
+    // Fixed sized messages are only ever handed payloads of their size by the stream decoder.
+    if config.Size >= 0 && len(payload) != int(config.Size) {
+        return
+    }
+
+    in, ok := config.New().(message.Inbound)
+    if !ok {
+        return
+    }
+
+    buf := buffer.ByteBuffer{Bytes: payload}
+    if err := in.Decode(&buf, len(payload)); err != nil {
+        return
+    }
+
+    if buf.Offset > len(payload) {
+        t.Fatalf("decoding %T read %d bytes from a payload of %d bytes", in, buf.Offset, len(payload))
+    }
+}
@@ -0,0 +1,61 @@
+package message
+
+import "sync"
+
+// The capacities of the pooled payload buffers. A payload is taken from the pool of the smallest capacity that fits.
+var payloadClasses = []int{0xff, 0xfff, 0xffff}
+
+var payloadPools = func() []sync.Pool {
+    pools := make([]sync.Pool, len(payloadClasses))
+    for i, capacity := range payloadClasses {
+        capacity := capacity
+        pools[i].New = func() interface{} { return &Payload{bytes: make([]byte, capacity)} }
+    }
+    return pools
+}()
+
+// A pooled buffer holding the payload of a decoded message. Payloads are owned by whoever they were handed to and must
+// be released exactly once when their bytes are no longer used. The bytes of a released payload must not be used.
+type Payload struct {
+    bytes  []byte
+    length int
+    class  int
+}
+
+// Takes a payload from the pool that can hold the given number of bytes.
+func NewPayload(length int) *Payload {
+    for class, capacity := range payloadClasses {
+        if length <= capacity {
+            payload := payloadPools[class].Get().(*Payload)
+            payload.length = length
+            payload.class = class
+            return payload
+        }
+    }
+
+    // Payloads larger than every class are never larger than a message can be, they are allocated but not pooled.
+    return &Payload{bytes: make([]byte, length), length: length, class: -1}
+}
+
+// Gets the bytes of the payload.
+func (p *Payload) Bytes() []byte {
+    return p.bytes[:p.length]
+}
+
+// Returns the payload to the pool.
+func (p *Payload) Release() {
+    if p.class < 0 {
+        return
+    }
+    payloadPools[p.class].Put(p)
+}
+
+// Inbound messages that keep a reference to the bytes that they were decoded from must implement PayloadOwner. They
+// are always decoded from their own pooled payload, ownership of which is handed to them after they were decoded
+// successfully. The message is responsible for releasing the payload.
+type PayloadOwner interface {
+    Inbound
+
+    // Takes ownership of the payload that the message was decoded from.
+    OwnPayload(*Payload)
+}
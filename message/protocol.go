@@ -0,0 +1,165 @@
+package message
+
+import (
+    "fmt"
+    "reflect"
+    "sort"
+)
+
+// A definition declares the messages of a service for a client revision. The messages of a service are the same Go
+// types in every revision, a definition maps them to the identifier and size that they have in its revision.
+type Definition struct {
+    Revision uint32
+    Inbound  []Config
+    Outbound []Config
+}
+
+// Inbound messages that select the revision of the protocol used for the rest of the stream, such as handshakes.
+type Negotiation interface {
+    Inbound
+
+    // Gets the revision that the client requested.
+    Revision() uint32
+}
+
+// A protocol is the set of messages that are sent and received by clients of a revision.
+type Protocol struct {
+    revision uint32
+    inbound  map[uint8]Config
+    outbound map[reflect.Type]Config
+}
+
+func newProtocol(revision uint32) *Protocol {
+    return &Protocol{
+        revision: revision,
+        inbound:  make(map[uint8]Config),
+        outbound: make(map[reflect.Type]Config),
+    }
+}
+
+// Adds the messages of a definition to the protocol. Inbound messages are decoded by their identifier so no two of
+// them can share one, outbound messages are encoded by their type so no type can be declared twice.
+func (p *Protocol) add(definition Definition) error {
+    for _, config := range definition.Inbound {
+        if err := validate(config, DirectionInbound); err != nil {
+            return fmt.Errorf("%v in revision %d", err, p.revision)
+        }
+
+        if existing, ok := p.inbound[config.Id]; ok {
+            return fmt.Errorf("message: revision %d declares inbound messages %T and %T with the same id %d",
+                p.revision, existing.New(), config.New(), config.Id)
+        }
+        p.inbound[config.Id] = config
+    }
+
+    for _, config := range definition.Outbound {
+        if err := validate(config, DirectionOutbound); err != nil {
+            return fmt.Errorf("%v in revision %d", err, p.revision)
+        }
+
+        t := TypeOf(config.New())
+        if _, ok := p.outbound[t]; ok {
+            return fmt.Errorf("message: revision %d declares outbound message %s more than once", p.revision, t)
+        }
+        p.outbound[t] = config
+    }
+    return nil
+}
+
+// Gets the revision of the protocol.
+func (p *Protocol) Revision() uint32 {
+    return p.revision
+}
+
+// Gets the configurations of the inbound messages by their identifier. The returned map must not be modified.
+func (p *Protocol) Inbound() map[uint8]Config {
+    return p.inbound
+}
+
+// Gets the configuration that an outbound message is encoded with. Messages that the protocol does not declare are
+// encoded with their own configuration.
+func (p *Protocol) Outbound(msg Outbound) Config {
+    if p != nil {
+        if config, ok := p.outbound[TypeOf(msg)]; ok {
+            return config
+        }
+    }
+    return msg.Config()
+}
+
+// A registry holds the protocols of every client revision that is supported.
+type Registry struct {
+    protocols map[uint32]*Protocol
+}
+
+// Creates a registry from the definitions of every service. Definitions for the same revision are merged into one
+// protocol.
+func NewRegistry(definitions ...Definition) (*Registry, error) {
+    registry := &Registry{protocols: make(map[uint32]*Protocol)}
+
+    for _, definition := range definitions {
+        protocol, ok := registry.protocols[definition.Revision]
+        if !ok {
+            protocol = newProtocol(definition.Revision)
+            registry.protocols[definition.Revision] = protocol
+        }
+
+        if err := protocol.add(definition); err != nil {
+            return nil, err
+        }
+    }
+
+    return registry, nil
+}
+
+// Gets the protocol for a revision.
+func (r *Registry) Lookup(revision uint32) (*Protocol, bool) {
+    if r == nil {
+        return nil, false
+    }
+    protocol, ok := r.protocols[revision]
+    return protocol, ok
+}
+
+// Checks if a revision is supported.
+func (r *Registry) Supports(revision uint32) bool {
+    _, ok := r.Lookup(revision)
+    return ok
+}
+
+// Gets all of the supported revisions in ascending order.
+func (r *Registry) Revisions() []uint32 {
+    if r == nil {
+        return nil
+    }
+
+    revisions := make([]uint32, 0, len(r.protocols))
+    for revision := range r.protocols {
+        revisions = append(revisions, revision)
+    }
+    sort.Slice(revisions, func(i, j int) bool { return revisions[i] < revisions[j] })
+    return revisions
+}
+
+// Validates a configuration and checks that it is declared in the expected direction.
+func validate(config Config, direction Direction) error {
+    if err := config.Validate(); err != nil {
+        return err
+    }
+
+    if config.Direction != direction {
+        return fmt.Errorf("message: %s message %d (%T) is declared as %s", config.Direction, config.Id,
+            config.New(), direction)
+    }
+    return nil
+}
+
+// Gets the type of a message with pointers dereferenced, so that messages are matched whether they are sent by value
+// or by pointer.
+func TypeOf(msg Message) reflect.Type {
+    t := reflect.TypeOf(msg)
+    for t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+    return t
+}
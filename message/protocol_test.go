@@ -0,0 +1,107 @@
+package message
+
+import (
+    "testing"
+)
+
+func TestNewRegistry(t *testing.T) {
+    login := Config{Id: 1, Size: 0, Direction: DirectionInbound, New: func() Message { return &skipped{} }}
+    moved := Config{Id: 9, Size: 0, Direction: DirectionInbound, New: func() Message { return &skipped{} }}
+    sizedConfig := Config{Id: 3, Size: 4, Direction: DirectionOutbound, New: func() Message { return sized{} }}
+
+    registry, err := NewRegistry(
+        Definition{Revision: 177, Inbound: []Config{login}},
+        Definition{Revision: 177, Outbound: []Config{sizedConfig}},
+        Definition{Revision: 178, Inbound: []Config{moved}},
+    )
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if revisions := registry.Revisions(); len(revisions) != 2 || revisions[0] != 177 || revisions[1] != 178 {
+        t.Fatalf("expected revisions 177 and 178, got %v", revisions)
+    }
+
+    if registry.Supports(176) {
+        t.Error("expected revision 176 not to be supported")
+    }
+
+    protocol, _ := registry.Lookup(177)
+    if _, ok := protocol.Inbound()[1]; !ok {
+        t.Error("expected revision 177 to decode message 1")
+    }
+
+    if config := protocol.Outbound(&sized{}); config.Id != 3 {
+        t.Errorf("expected a pointer to be encoded with the declared id 3, got %d", config.Id)
+    }
+
+    if config := protocol.Outbound(outboundOnly{}); config.Id != 4 {
+        t.Errorf("expected an undeclared message to be encoded with its own id 4, got %d", config.Id)
+    }
+
+    protocol, _ = registry.Lookup(178)
+    if _, ok := protocol.Inbound()[9]; !ok {
+        t.Error("expected revision 178 to decode message 9")
+    }
+}
+
+func TestNewRegistry_InboundCollision(t *testing.T) {
+    first := Config{Id: 1, Direction: DirectionInbound, New: func() Message { return &skipped{} }}
+    second := Config{Id: 1, Direction: DirectionInbound, New: func() Message { return &retained{} }}
+
+    if _, err := NewRegistry(
+        Definition{Revision: 177, Inbound: []Config{first}},
+        Definition{Revision: 177, Inbound: []Config{second}},
+    ); err == nil {
+        t.Error("expected an error for inbound messages with the same id in one revision")
+    }
+
+    if _, err := NewRegistry(
+        Definition{Revision: 177, Inbound: []Config{first}},
+        Definition{Revision: 178, Inbound: []Config{second}},
+    ); err != nil {
+        t.Errorf("expected messages of different revisions to be able to share an id: %v", err)
+    }
+}
+
+func TestNewRegistry_Direction(t *testing.T) {
+    inbound := Config{Id: 1, Direction: DirectionInbound, New: func() Message { return &skipped{} }}
+    outbound := Config{Id: 3, Size: 4, Direction: DirectionOutbound, New: func() Message { return sized{} }}
+
+    if _, err := NewRegistry(Definition{Revision: 177, Inbound: []Config{outbound}}); err == nil {
+        t.Error("expected an error for an outbound message declared as inbound")
+    }
+
+    if _, err := NewRegistry(Definition{Revision: 177, Outbound: []Config{inbound}}); err == nil {
+        t.Error("expected an error for an inbound message declared as outbound")
+    }
+
+    if _, err := NewRegistry(
+        Definition{Revision: 177, Inbound: []Config{inbound}, Outbound: []Config{{Id: 1, Size: 4,
+            Direction: DirectionOutbound, New: func() Message { return sized{} }}}},
+    ); err != nil {
+        t.Errorf("expected an inbound and an outbound message to be able to share an id: %v", err)
+    }
+}
+
+func TestConfig_Validate(t *testing.T) {
+    for name, config := range map[string]Config{
+        "NoConstructor":    {Id: 1, Direction: DirectionInbound},
+        "NilMessage":       {Id: 1, Direction: DirectionInbound, New: func() Message { return nil }},
+        "NoDirection":      {Id: 1, New: func() Message { return &skipped{} }},
+        "InvalidSize":      {Id: 1, Size: -3, Direction: DirectionInbound, New: func() Message { return &skipped{} }},
+        "NotInbound":       {Id: 4, Direction: DirectionInbound, New: func() Message { return outboundOnly{} }},
+        "NotOutbound":      {Id: 5, Direction: DirectionOutbound, New: func() Message { return &retained{} }},
+        "UnknownDirection": {Id: 1, Direction: 3, New: func() Message { return &skipped{} }},
+    } {
+        if err := config.Validate(); err == nil {
+            t.Errorf("%s: expected the configuration to be invalid", name)
+        }
+    }
+
+    for _, config := range testConfigs() {
+        if err := config.Validate(); err != nil && config.Id != 4 {
+            t.Errorf("expected configuration %d to be valid: %v", config.Id, err)
+        }
+    }
+}
@@ -0,0 +1,181 @@
+package metrics
+
+import (
+    "math"
+    "sort"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// Options that describe a metric. The name is the name the metric will be exported under and help is a short
+// description of what the metric is measuring.
+type Opts struct {
+    Name string
+    Help string
+}
+
+// A counter is a metric that only ever increases, for example the number of messages that have been received.
+type Counter struct {
+    value uint64
+}
+
+func (c *Counter) Inc() { atomic.AddUint64(&c.value, 1) }
+
+func (c *Counter) Add(n uint64) { atomic.AddUint64(&c.value, n) }
+
+func (c *Counter) Value() uint64 { return atomic.LoadUint64(&c.value) }
+
+// A gauge is a metric that can arbitrarily increase or decrease, for example the number of clients that are connected.
+type Gauge struct {
+    value int64
+}
+
+func (g *Gauge) Inc() { atomic.AddInt64(&g.value, 1) }
+
+func (g *Gauge) Dec() { atomic.AddInt64(&g.value, -1) }
+
+func (g *Gauge) Add(n int64) { atomic.AddInt64(&g.value, n) }
+
+func (g *Gauge) Set(n int64) { atomic.StoreInt64(&g.value, n) }
+
+func (g *Gauge) Value() int64 { return atomic.LoadInt64(&g.value) }
+
+// Default buckets for histograms observing durations in seconds. Ranges from a hundred microseconds up to ten seconds.
+var DefaultBuckets = []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// A histogram samples observations and counts them in configurable buckets. Each bucket counts the observations that
+// are less than or equal to its upper bound.
+type Histogram struct {
+    bounds []float64
+    counts []uint64
+    count  uint64
+    sum    uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+    bounds := make([]float64, len(buckets))
+    copy(bounds, buckets)
+    sort.Float64s(bounds)
+
+    return &Histogram{
+        bounds: bounds,
+        counts: make([]uint64, len(bounds)),
+    }
+}
+
+func (h *Histogram) Observe(v float64) {
+    i := sort.SearchFloat64s(h.bounds, v)
+    if i < len(h.counts) {
+        atomic.AddUint64(&h.counts[i], 1)
+    }
+
+    atomic.AddUint64(&h.count, 1)
+
+    // The sum is stored as the bits of a float so that it can be updated without a lock.
+    for {
+        old := atomic.LoadUint64(&h.sum)
+        updated := math.Float64bits(math.Float64frombits(old) + v)
+        if atomic.CompareAndSwapUint64(&h.sum, old, updated) {
+            return
+        }
+    }
+}
+
+// Observes the duration that has elapsed since the given time in seconds.
+func (h *Histogram) ObserveSince(start time.Time) {
+    h.Observe(time.Since(start).Seconds())
+}
+
+// Gets a point in time copy of the cumulative bucket counts, the total count and the sum of all observations.
+func (h *Histogram) snapshot() ([]uint64, uint64, float64) {
+    cumulative := make([]uint64, len(h.counts))
+    total := uint64(0)
+    for i := range h.counts {
+        total += atomic.LoadUint64(&h.counts[i])
+        cumulative[i] = total
+    }
+    return cumulative, atomic.LoadUint64(&h.count), math.Float64frombits(atomic.LoadUint64(&h.sum))
+}
+
+// A vector is a collection of metrics of the same kind that are partitioned by a set of label values. Children are
+// created lazily the first time a set of label values is used.
+type vector struct {
+    labels   []string
+    new      func() interface{}
+    children map[string]interface{}
+    values   map[string][]string
+    mutex    sync.RWMutex
+}
+
+func newVector(labels []string, new func() interface{}) *vector {
+    return &vector{
+        labels:   labels,
+        new:      new,
+        children: make(map[string]interface{}),
+        values:   make(map[string][]string),
+    }
+}
+
+func (v *vector) with(values []string) interface{} {
+    if len(values) != len(v.labels) {
+        panic("metrics: number of label values does not match the number of labels")
+    }
+
+    key := strings.Join(values, "\xff")
+
+    v.mutex.RLock()
+    child, ok := v.children[key]
+    v.mutex.RUnlock()
+    if ok {
+        return child
+    }
+
+    v.mutex.Lock()
+    defer v.mutex.Unlock()
+
+    if child, ok := v.children[key]; ok {
+        return child
+    }
+
+    child = v.new()
+    v.children[key] = child
+    v.values[key] = append([]string(nil), values...)
+
+    return child
+}
+
+// Calls the function for each child in the vector sorted by its label values.
+func (v *vector) each(fn func(values []string, child interface{})) {
+    v.mutex.RLock()
+    keys := make([]string, 0, len(v.children))
+    for key := range v.children {
+        keys = append(keys, key)
+    }
+    v.mutex.RUnlock()
+
+    sort.Strings(keys)
+
+    for _, key := range keys {
+        v.mutex.RLock()
+        values, child := v.values[key], v.children[key]
+        v.mutex.RUnlock()
+        fn(values, child)
+    }
+}
+
+type CounterVec struct{ *vector }
+
+// Gets the counter for the given label values, the values must be given in the same order as the labels.
+func (v CounterVec) With(values ...string) *Counter { return v.with(values).(*Counter) }
+
+type GaugeVec struct{ *vector }
+
+// Gets the gauge for the given label values, the values must be given in the same order as the labels.
+func (v GaugeVec) With(values ...string) *Gauge { return v.with(values).(*Gauge) }
+
+type HistogramVec struct{ *vector }
+
+// Gets the histogram for the given label values, the values must be given in the same order as the labels.
+func (v HistogramVec) With(values ...string) *Histogram { return v.with(values).(*Histogram) }
@@ -0,0 +1,230 @@
+package metrics
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+type kind string
+
+const (
+    counterKind   kind = "counter"
+    gaugeKind     kind = "gauge"
+    histogramKind kind = "histogram"
+)
+
+// Registry which all of the metrics created through the package level functions are registered to.
+var DefaultRegistry = NewRegistry()
+
+type entry struct {
+    opts   Opts
+    kind   kind
+    metric interface{}
+}
+
+// A registry is a collection of metrics that can be exported in the Prometheus text exposition format.
+type Registry struct {
+    entries map[string]entry
+    mutex   sync.Mutex
+}
+
+func NewRegistry() *Registry {
+    return &Registry{entries: make(map[string]entry)}
+}
+
+func (r *Registry) register(opts Opts, kind kind, metric interface{}) {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    if _, exists := r.entries[opts.Name]; exists {
+        panic(fmt.Sprintf("metrics: metric %s is already registered", opts.Name))
+    }
+
+    r.entries[opts.Name] = entry{opts: opts, kind: kind, metric: metric}
+}
+
+func (r *Registry) NewCounter(opts Opts) *Counter {
+    c := &Counter{}
+    r.register(opts, counterKind, c)
+    return c
+}
+
+func (r *Registry) NewCounterVec(opts Opts, labels ...string) CounterVec {
+    v := CounterVec{newVector(labels, func() interface{} { return &Counter{} })}
+    r.register(opts, counterKind, v)
+    return v
+}
+
+func (r *Registry) NewGauge(opts Opts) *Gauge {
+    g := &Gauge{}
+    r.register(opts, gaugeKind, g)
+    return g
+}
+
+// Creates a gauge which has its value computed by calling the given function every time the metric is exported.
+func (r *Registry) NewGaugeFunc(opts Opts, fn func() int64) {
+    r.register(opts, gaugeKind, fn)
+}
+
+func (r *Registry) NewGaugeVec(opts Opts, labels ...string) GaugeVec {
+    v := GaugeVec{newVector(labels, func() interface{} { return &Gauge{} })}
+    r.register(opts, gaugeKind, v)
+    return v
+}
+
+func (r *Registry) NewHistogram(opts Opts, buckets []float64) *Histogram {
+    h := newHistogram(buckets)
+    r.register(opts, histogramKind, h)
+    return h
+}
+
+func (r *Registry) NewHistogramVec(opts Opts, buckets []float64, labels ...string) HistogramVec {
+    v := HistogramVec{newVector(labels, func() interface{} { return newHistogram(buckets) })}
+    r.register(opts, histogramKind, v)
+    return v
+}
+
+func NewCounter(opts Opts) *Counter { return DefaultRegistry.NewCounter(opts) }
+
+func NewCounterVec(opts Opts, labels ...string) CounterVec {
+    return DefaultRegistry.NewCounterVec(opts, labels...)
+}
+
+func NewGauge(opts Opts) *Gauge { return DefaultRegistry.NewGauge(opts) }
+
+func NewGaugeFunc(opts Opts, fn func() int64) { DefaultRegistry.NewGaugeFunc(opts, fn) }
+
+func NewGaugeVec(opts Opts, labels ...string) GaugeVec {
+    return DefaultRegistry.NewGaugeVec(opts, labels...)
+}
+
+func NewHistogram(opts Opts, buckets []float64) *Histogram {
+    return DefaultRegistry.NewHistogram(opts, buckets)
+}
+
+func NewHistogramVec(opts Opts, buckets []float64, labels ...string) HistogramVec {
+    return DefaultRegistry.NewHistogramVec(opts, buckets, labels...)
+}
+
+// Writes all of the registered metrics sorted by name in the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+    r.mutex.Lock()
+    entries := make([]entry, 0, len(r.entries))
+    for _, e := range r.entries {
+        entries = append(entries, e)
+    }
+    r.mutex.Unlock()
+
+    sort.Slice(entries, func(i, j int) bool { return entries[i].opts.Name < entries[j].opts.Name })
+
+    cw := &countingWriter{w: bufio.NewWriter(w)}
+
+    for _, e := range entries {
+        fmt.Fprintf(cw, "# HELP %s %s\n", e.opts.Name, e.opts.Help)
+        fmt.Fprintf(cw, "# TYPE %s %s\n", e.opts.Name, e.kind)
+
+        switch metric := e.metric.(type) {
+        case *Counter:
+            writeSample(cw, e.opts.Name, nil, nil, strconv.FormatUint(metric.Value(), 10))
+        case *Gauge:
+            writeSample(cw, e.opts.Name, nil, nil, strconv.FormatInt(metric.Value(), 10))
+        case func() int64:
+            writeSample(cw, e.opts.Name, nil, nil, strconv.FormatInt(metric(), 10))
+        case *Histogram:
+            writeHistogram(cw, e.opts.Name, nil, nil, metric)
+        case CounterVec:
+            metric.each(func(values []string, child interface{}) {
+                value := strconv.FormatUint(child.(*Counter).Value(), 10)
+                writeSample(cw, e.opts.Name, metric.labels, values, value)
+            })
+        case GaugeVec:
+            metric.each(func(values []string, child interface{}) {
+                value := strconv.FormatInt(child.(*Gauge).Value(), 10)
+                writeSample(cw, e.opts.Name, metric.labels, values, value)
+            })
+        case HistogramVec:
+            metric.each(func(values []string, child interface{}) {
+                writeHistogram(cw, e.opts.Name, metric.labels, values, child.(*Histogram))
+            })
+        }
+    }
+
+    if err := cw.w.Flush(); err != nil {
+        return cw.n, err
+    }
+
+    return cw.n, cw.err
+}
+
+// Gets a handler which serves the registered metrics.
+func (r *Registry) Handler() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+        _, _ = r.WriteTo(w)
+    })
+}
+
+// Gets a handler which serves the metrics registered to the default registry.
+func Handler() http.Handler { return DefaultRegistry.Handler() }
+
+// Listens on the given address and serves the metrics registered to the default registry at /metrics. This function
+// blocks until the listener fails.
+func Serve(address string) error {
+    mux := http.NewServeMux()
+    mux.Handle("/metrics", Handler())
+    return http.ListenAndServe(address, mux)
+}
+
+func writeHistogram(w io.Writer, name string, labels, values []string, h *Histogram) {
+    cumulative, count, sum := h.snapshot()
+
+    bucketLabels := append(append([]string(nil), labels...), "le")
+    for i, bound := range h.bounds {
+        bucketValues := append(append([]string(nil), values...), strconv.FormatFloat(bound, 'g', -1, 64))
+        writeSample(w, name+"_bucket", bucketLabels, bucketValues, strconv.FormatUint(cumulative[i], 10))
+    }
+
+    writeSample(w, name+"_bucket", bucketLabels, append(append([]string(nil), values...), "+Inf"),
+        strconv.FormatUint(count, 10))
+    writeSample(w, name+"_sum", labels, values, strconv.FormatFloat(sum, 'g', -1, 64))
+    writeSample(w, name+"_count", labels, values, strconv.FormatUint(count, 10))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func writeSample(w io.Writer, name string, labels, values []string, value string) {
+    if len(labels) == 0 {
+        fmt.Fprintf(w, "%s %s\n", name, value)
+        return
+    }
+
+    pairs := make([]string, len(labels))
+    for i := range labels {
+        pairs[i] = fmt.Sprintf(`%s="%s"`, labels[i], labelEscaper.Replace(values[i]))
+    }
+
+    fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(pairs, ","), value)
+}
+
+// Writer that keeps track of the number of bytes written and the first error encountered.
+type countingWriter struct {
+    w   *bufio.Writer
+    n   int64
+    err error
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+    if w.err != nil {
+        return 0, w.err
+    }
+    n, err := w.w.Write(b)
+    w.n += int64(n)
+    w.err = err
+    return n, err
+}
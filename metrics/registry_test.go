@@ -0,0 +1,44 @@
+package metrics
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+    registry := NewRegistry()
+
+    counter := registry.NewCounterVec(Opts{Name: "test_messages_total", Help: "Messages."}, "opcode")
+    counter.With("15").Add(3)
+
+    gauge := registry.NewGauge(Opts{Name: "test_clients", Help: "Clients."})
+    gauge.Inc()
+    gauge.Inc()
+    gauge.Dec()
+
+    histogram := registry.NewHistogram(Opts{Name: "test_duration_seconds", Help: "Duration."}, []float64{1, 5})
+    histogram.Observe(0.5)
+    histogram.Observe(3)
+    histogram.Observe(10)
+
+    var bldr strings.Builder
+    if _, err := registry.WriteTo(&bldr); err != nil {
+        t.Fatal(err)
+    }
+
+    expected := []string{
+        "# TYPE test_clients gauge\ntest_clients 1\n",
+        "test_duration_seconds_bucket{le=\"1\"} 1\n",
+        "test_duration_seconds_bucket{le=\"5\"} 2\n",
+        "test_duration_seconds_bucket{le=\"+Inf\"} 3\n",
+        "test_duration_seconds_sum 13.5\n",
+        "test_duration_seconds_count 3\n",
+        "# TYPE test_messages_total counter\ntest_messages_total{opcode=\"15\"} 3\n",
+    }
+
+    for _, line := range expected {
+        if !strings.Contains(bldr.String(), line) {
+            t.Errorf("expected output to contain %q, got:\n%s", line, bldr.String())
+        }
+    }
+}
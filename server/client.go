@@ -5,7 +5,9 @@ import (
     "github.com/sprinkle-it/donut/buffer"
     "github.com/sprinkle-it/donut/message"
     "go.uber.org/zap"
+    "io"
     "net"
+    "strconv"
     "sync"
     "time"
 )
@@ -15,6 +17,15 @@ var (
     ErrClosed    = errors.New("server: this operation could not be performed because the client is closed")
 )
 
+// The stage of the protocol that a client is in. Services set the stage of a client once it has been handed off to
+// them so that operators can tell what a client is currently doing.
+type Stage string
+
+const (
+    // Stage of a client that has connected but has not yet been accepted by any service.
+    StageConnected Stage = "connected"
+)
+
 // Creates a new client for the given connection and router.
 type Factory func(net.Conn, *zap.Logger, MailRouter) *Client
 
@@ -34,9 +45,23 @@ func IncrementalGenerator(start uint64) IdentifierGenerator {
 type FlushWriter struct {
     *Client
     counter int
+
+    // The token buckets that writes wait on before they are written, nil buckets are ignored.
+    Limiters []*TokenBucket
+
+    // Called once the client can accept commands again after a try write or flush found it busy. Optional.
+    Wake func()
+
+    // The number of bytes that can be written without waiting on the limiters. Bytes written within the burst still
+    // take tokens from the limiters so later writes make up for them.
+    Burst int
 }
 
 func (w *FlushWriter) Write(b []byte) error {
+    if err := w.throttle(len(b)); err != nil {
+        return err
+    }
+
     if w.counter+len(b) >= w.output.Capacity() {
         if err := w.Flush(); err != nil {
             return err
@@ -47,11 +72,68 @@ func (w *FlushWriter) Write(b []byte) error {
     return w.Client.Write(b)
 }
 
+// Attempts to write bytes without blocking on the client or on the limiters, flushing first if the output buffer would
+// fill up. Returns whether the bytes were written and if they were, how long the caller should wait before writing
+// again to keep to the limiters. Nothing was written if the client was busy, such as when it is flushing to a slow
+// connection, in which case the wake function is called once the write can be retried with the same bytes.
+func (w *FlushWriter) TryWrite(b []byte) (bool, time.Duration, error) {
+    if w.counter+len(b) >= w.output.Capacity() {
+        if ok, err := w.TryFlush(); !ok || err != nil {
+            return false, 0, err
+        }
+    }
+
+    if ok, err := w.Client.TryWrite(b, w.Wake); !ok || err != nil {
+        return false, 0, err
+    }
+    w.counter += len(b)
+    return true, w.reserve(len(b)), nil
+}
+
+// Attempts to flush the output buffer without blocking on the client. Returns false if the client was busy, in which
+// case the wake function is called once the flush can be retried.
+func (w *FlushWriter) TryFlush() (bool, error) {
+    ok, err := w.Client.TryFlush(w.Wake)
+    if ok {
+        w.counter = 0
+    }
+    return ok, err
+}
+
+// Waits until the limiters allow the given number of bytes to be written.
+func (w *FlushWriter) throttle(n int) error {
+    return sleep(w.reserve(n), w.Quit())
+}
+
+// Takes the tokens for the given number of bytes from the limiters and returns how long to wait for them.
+func (w *FlushWriter) reserve(n int) time.Duration {
+    burst := n
+    if burst > w.Burst {
+        burst = w.Burst
+    }
+    w.Burst -= burst
+
+    // Bytes within the burst are taken without waiting on the debt of the limiters.
+    for _, limiter := range w.Limiters {
+        limiter.Take(burst)
+    }
+
+    if n == burst {
+        return 0
+    }
+    return ReserveAll(n-burst, w.Limiters...)
+}
+
 type ClientConfig struct {
     GenerateIdentifier IdentifierGenerator
     InputCapacity      int
     OutputCapacity     int
     MessageCapacity    int
+
+    // The number of commands that can be queued for the output go routine. Callers that try to write without waiting
+    // only find the client busy once the queue is full.
+    CommandCapacity int
+    DecodePolicy    DecodePolicy
 }
 
 func NewDefaultClientConfig() ClientConfig {
@@ -60,6 +142,7 @@ func NewDefaultClientConfig() ClientConfig {
         InputCapacity:      10240,
         OutputCapacity:     10240,
         MessageCapacity:    1000,
+        CommandCapacity:    64,
     }
 }
 
@@ -71,11 +154,14 @@ func (c *ClientConfig) Build(connection net.Conn, logger *zap.Logger, router Mai
         logger:         logger,
         input:          buffer.NewRingBuffer(c.InputCapacity),
         output:         buffer.NewRingBuffer(c.OutputCapacity),
-        outputCommands: make(chan outputCommand),
+        outputCommands: make(chan outputCommand, c.CommandCapacity),
+        outputStopped:  make(chan struct{}),
         decoder:        message.NewStreamDecoder(router.accepted, c.InputCapacity),
-        encoder:        message.NewStreamEncoder(c.OutputCapacity),
+        encoder:        message.NewStreamEncoder(),
         messages:       make(chan message.Message, c.MessageCapacity),
         router:         router,
+        decodePolicy:   c.DecodePolicy,
+        stage:          StageConnected,
         mutex:          sync.Mutex{},
         quit:           make(chan struct{}),
     }
@@ -97,6 +183,14 @@ type writeMessage struct {
 // Flushes the bytes from the output buffer to the connection.
 type flushBytes struct{}
 
+// Flushes the bytes from the output buffer to the connection and then closes the client.
+type closeAfterFlush struct{}
+
+// Sets the protocol that messages are encoded with.
+type setProtocol struct {
+    protocol *message.Protocol
+}
+
 type Client struct {
     id uint64
 
@@ -112,10 +206,19 @@ type Client struct {
     // control over when bytes are flushed to the client.
     output buffer.RingBuffer
 
-    // Blocking channel for output commands. When an operation wants to interact with the output buffer and encoder a
-    // command needs to be published to this channel so that the operation can be executed synchronously.
+    // Bounded queue of output commands. When an operation wants to interact with the output buffer and encoder a
+    // command needs to be published to this channel so that the operation can be executed synchronously. Commands are
+    // executed after the call that queued them returns, so bytes and messages must not be modified once queued.
     outputCommands chan outputCommand
 
+    // Closed once the output go routine has stopped, after which the commands left in the queue are never executed.
+    outputStopped chan struct{}
+
+    // Called by the output go routine when it takes a command off the queue after a try command found the queue full.
+    // Administered by the wake mutex.
+    wake      func()
+    wakeMutex sync.Mutex
+
     // Decodes byte streams into messages. Contains the state so that messages that have not been entirely transmitted
     // can be decoded when the bytes are received.
     decoder message.StreamDecoder
@@ -130,6 +233,16 @@ type Client struct {
 
     router MailRouter
 
+    // Decides whether the client survives messages that cannot be decoded. Only used by the input go routine.
+    decodePolicy DecodePolicy
+
+    // The stage of the protocol the client is currently in. Administered by the mutex.
+    stage Stage
+
+    // The protocol of the revision that the client negotiated, nil until it has negotiated one. Administered by the
+    // mutex.
+    protocol *message.Protocol
+
     // Mutex which handles locking when operations need to check state that is not maintained by a go routine.
     mutex sync.Mutex
 
@@ -157,48 +270,152 @@ func (c *Client) RemoteAddress() net.Addr {
     return c.connection.RemoteAddr()
 }
 
+// Gets the stage of the protocol the client is currently in.
+func (c *Client) Stage() Stage {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    return c.stage
+}
+
+// Sets the stage of the protocol the client is currently in.
+func (c *Client) SetStage(stage Stage) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    c.stage = stage
+}
+
+// Gets the protocol of the revision that the client negotiated, nil if it has not negotiated one.
+func (c *Client) Protocol() *message.Protocol {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    return c.protocol
+}
+
+// Switches the client to the protocol of a revision. Messages received after the one that negotiated the revision are
+// decoded with the protocol and messages sent after it are encoded with it. Clients requesting revisions that are not
+// supported keep their current protocol, it is left to the service that receives the negotiation to reject them.
+// Only to be called by the input goroutine.
+func (c *Client) negotiate(revision uint32) {
+    protocol, ok := c.router.protocols.Lookup(revision)
+    if !ok {
+        return
+    }
+
+    c.decoder.SetConfigs(c.router.accepts(protocol))
+
+    c.mutex.Lock()
+    c.protocol = protocol
+    c.mutex.Unlock()
+
+    // The protocol is set for encoding before the negotiating message is published, so any reply to it is encoded
+    // with the new protocol.
+    select {
+    case c.outputCommands <- setProtocol{protocol: protocol}:
+    case <-c.quit:
+    }
+}
+
 func (c *Client) Info(message string) {
     c.logger.Info(message, zap.Uint64("id", c.Id()), zap.Stringer("address", c.RemoteAddress()), )
 }
 
-// Writes bytes to the output buffer.
+// Writes bytes to the output buffer. The bytes are queued rather than copied, so they must not be modified afterwards.
 func (c *Client) Write(b []byte) error {
+    return c.command(writeBytes{bytes: b})
+}
+
+// Attempts to write bytes to the output buffer without waiting on the output go routine. Returns false if the command
+// queue is full, for example while the output go routine is flushing to a slow connection, in which case wake is
+// called once the write can be retried. The bytes must not be modified once they have been queued.
+func (c *Client) TryWrite(b []byte, wake func()) (bool, error) {
+    return c.tryCommand(writeBytes{bytes: b}, wake)
+}
+
+// Attempts to flush the output buffer without waiting on the output go routine. Returns false if the command queue is
+// full, in which case wake is called once the flush can be retried.
+func (c *Client) TryFlush(wake func()) (bool, error) {
+    return c.tryCommand(flushBytes{}, wake)
+}
+
+// Attempts to close the client once the bytes that were written before it have been flushed, without waiting on the
+// output go routine. Returns false if the command queue is full, in which case wake is called once the close can be
+// retried.
+func (c *Client) TryCloseAfterFlush(wake func()) (bool, error) {
+    return c.tryCommand(closeAfterFlush{}, wake)
+}
+
+// Queues a command without waiting. If the queue is full the wake function is registered and the command is tried
+// again, so that either the command is queued or the output go routine takes a command off the queue afterwards and
+// calls the wake function. Wake may be nil.
+func (c *Client) tryCommand(cmd outputCommand, wake func()) (bool, error) {
     if err := c.check(); err != nil {
-        return err
+        return false, err
     }
 
-    c.outputCommands <- writeBytes{bytes: b}
-    return nil
+    select {
+    case c.outputCommands <- cmd:
+        return true, nil
+    default:
+    }
+
+    if wake != nil {
+        c.wakeMutex.Lock()
+        c.wake = wake
+        c.wakeMutex.Unlock()
+    }
+
+    select {
+    case c.outputCommands <- cmd:
+        return true, nil
+    case <-c.quit:
+        return false, ErrClosed
+    default:
+        return false, nil
+    }
 }
 
-// Writes a message to the output buffer.
-func (c *Client) Send(msg message.Outbound) error {
-    if err := c.check(); err != nil {
-        return err
+// Calls the registered wake function once there is room in the command queue. Only to be called by the output go
+// routine after it has taken a command off the queue.
+func (c *Client) woken() {
+    c.wakeMutex.Lock()
+    wake := c.wake
+    c.wake = nil
+    c.wakeMutex.Unlock()
+
+    if wake != nil {
+        wake()
     }
+}
 
-    c.outputCommands <- writeMessage{out: msg}
-    return nil
+// Writes a message to the output buffer.
+func (c *Client) Send(msg message.Outbound) error {
+    return c.command(writeMessage{out: msg})
 }
 
 // Writes a message to the output buffer and flushes afterward.
 func (c *Client) SendNow(msg message.Outbound) error {
-    if err := c.check(); err != nil {
-        return err
-    }
-
-    c.outputCommands <- writeMessage{out: msg}
-    c.outputCommands <- flushBytes{}
-    return nil
+    return c.command(writeMessage{out: msg}, flushBytes{})
 }
 
 // Flushes the output buffer to the connection.
 func (c *Client) Flush() error {
+    return c.command(flushBytes{})
+}
+
+// Queues commands for the output go routine, waiting while the queue is full. Returns ErrClosed if the client closes
+// while waiting, as the output go routine stops taking commands off the queue once it is closed.
+func (c *Client) command(cmds ...outputCommand) error {
     if err := c.check(); err != nil {
         return err
     }
 
-    c.outputCommands <- flushBytes{}
+    for _, cmd := range cmds {
+        select {
+        case c.outputCommands <- cmd:
+        case <-c.quit:
+            return ErrClosed
+        }
+    }
     return nil
 }
 
@@ -220,7 +437,7 @@ func (c *Client) processMessages() {
 // Process reading from the connection and decoding messages.
 func (c *Client) processInput() {
     go func() {
-        transfer := make([]byte, c.input.Capacity())
+        consumed := 0
         for {
             select {
             case <-c.quit:
@@ -230,8 +447,8 @@ func (c *Client) processInput() {
                 // Continue reading from connection.
             }
 
-            count, err := c.connection.Read(transfer)
-            if err != nil {
+            // Read directly into the input buffer. If the input buffer is full the client is closed.
+            if _, err := c.input.Fill(c.connection); err != nil {
                 if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
                     continue
                 }
@@ -239,24 +456,45 @@ func (c *Client) processInput() {
                 return
             }
 
-            if _, err := c.input.Write(transfer[:count]); err != nil {
-                c.Fatal(err)
-                return
-            }
-
             // Keep reading in bytes until the stream decoder says that it currently cannot decode a certain message.
             for buffer.HasReadable(&c.input) {
+                readable := c.input.Readable()
+
                 msg, err := c.decoder.Decode(&c.input)
                 if err != nil {
-                    c.Fatal(err)
-                    return
+                    // A skipped message reports the unrecognized message that was already counted.
+                    if decodeErr, ok := err.(*message.DecodeError); !ok || decodeErr.Err != message.ErrSkipped {
+                        decodeErrors.Inc()
+                    }
+
+                    if !c.recover(err) {
+                        c.Fatal(err)
+                        return
+                    }
+
+                    // The bytes of the message were consumed without producing a message.
+                    consumed = 0
+                    continue
                 }
 
+                // Messages can be decoded over multiple calls so keep track of how many bytes have been consumed
+                // until the message has been entirely decoded.
+                consumed += readable - c.input.Readable()
+
                 // Read bytes didn't contain a message and needs more bytes to continue.
                 if msg == nil {
                     break
                 }
 
+                if negotiation, ok := msg.(message.Negotiation); ok {
+                    c.negotiate(negotiation.Revision())
+                }
+
+                opcode := strconv.Itoa(int(msg.Config().Id))
+                messagesTotal.With(inbound, opcode).Inc()
+                messageBytesTotal.With(inbound, opcode).Add(uint64(consumed))
+                consumed = 0
+
                 select {
                 case c.messages <- msg:
                     // Successfully buffered message to client
@@ -270,42 +508,142 @@ func (c *Client) processInput() {
     }()
 }
 
+// Decides whether the client can continue after a decode error using the decode policy. Every decision is logged with
+// the opcode and raw bytes of the message. Returns false if the error is fatal.
+func (c *Client) recover(err error) bool {
+    decodeErr, ok := err.(*message.DecodeError)
+    if !ok {
+        // The stream is out of sync, there is no way to find the start of the next message.
+        return false
+    }
+
+    stage := c.Stage()
+    logger := c.logger.With(
+        zap.Uint64("id", c.id),
+        zap.String("stage", string(stage)),
+        zap.Uint8("opcode", decodeErr.Id),
+        zap.Binary("payload", decodeErr.Payload),
+        zap.Error(decodeErr.Err),
+    )
+
+    opcode := strconv.Itoa(int(decodeErr.Id))
+
+    switch decodeErr.Err {
+    case message.ErrUnrecognized:
+        size, ok := c.decodePolicy.skip(stage, decodeErr.Id)
+        if !ok {
+            logger.Info("Closing client after unrecognized message")
+            return false
+        }
+
+        if err := c.decoder.Skip(size); err != nil {
+            logger.Info("Closing client after failing to skip unrecognized message", zap.NamedError("cause", err))
+            return false
+        }
+
+        logger.Info("Skipping unrecognized message", zap.Int("size", int(size)))
+        return true
+    case message.ErrSkipped:
+        decodeRecoveries.With("skip", opcode).Inc()
+        logger.Info("Skipped unrecognized message")
+        return true
+    default:
+        if !c.decodePolicy.drop(stage, decodeErr.Id) {
+            logger.Info("Closing client after message failed to decode")
+            return false
+        }
+
+        decodeRecoveries.With("drop", opcode).Inc()
+        logger.Info("Dropped message that failed to decode")
+        return true
+    }
+}
+
+// Writer for the output buffer that flushes the output buffer to the connection whenever it fills up, which allows
+// writing messages that are larger than the output buffer. Only to be used by the output goroutine.
+type outputWriter struct {
+    client *Client
+
+    // The number of bytes that have been written.
+    written int
+}
+
+func (w *outputWriter) Write(b []byte) (int, error) {
+    total := 0
+    for {
+        n, err := w.client.output.Write(b[total:])
+        total += n
+        w.written += n
+
+        if err != io.ErrShortWrite {
+            return total, err
+        }
+
+        if err := w.client.flush(); err != nil {
+            return total, err
+        }
+    }
+}
+
+// Flushes all of the readable bytes of the output buffer to the connection. Only to be called by the output goroutine.
+func (c *Client) flush() error {
+    if !buffer.HasReadable(&c.output) {
+        return nil
+    }
+
+    _, err := c.output.WriteTo(c.connection)
+    return err
+}
+
 // Process all of the output commands for the client.
 func (c *Client) processOutput() {
     go func() {
-        transfer := make([]byte, c.input.Capacity())
+        defer close(c.outputStopped)
+
+        // Callers waiting for room in the queue are woken when the client stops so that they see it was closed.
+        defer c.woken()
+
+        writer := outputWriter{client: c}
         for {
             select {
             case <-c.quit:
                 // Client was closed, stop handling commands.
                 return
             case cmd := <-c.outputCommands:
+                // Taking the command made room in the queue for a caller that found it full.
+                c.woken()
+
                 switch cmd := cmd.(type) {
                 case writeBytes:
-                    if _, err := c.output.Write(cmd.bytes); err != nil {
+                    if _, err := writer.Write(cmd.bytes); err != nil {
                         c.Fatal(err)
                         return
                     }
                 case writeMessage:
-                    if err := c.encoder.Encode(cmd.out, &c.output); err != nil {
+                    written := writer.written
+
+                    if err := c.encoder.Encode(cmd.out, &writer); err != nil {
                         c.Fatal(err)
                         return
                     }
-                case flushBytes:
-                    if !buffer.HasReadable(&c.output) {
-                        return
-                    }
 
-                    count, err := c.output.Read(transfer[:c.output.Readable()])
-                    if err != nil {
+                    opcode := strconv.Itoa(int(cmd.out.Config().Id))
+                    messagesTotal.With(outbound, opcode).Inc()
+                    messageBytesTotal.With(outbound, opcode).Add(uint64(writer.written - written))
+                case flushBytes:
+                    if err := c.flush(); err != nil {
                         c.Fatal(err)
                         return
                     }
-
-                    if _, err = c.connection.Write(transfer[:count]); err != nil {
+                case closeAfterFlush:
+                    if err := c.flush(); err != nil {
                         c.Fatal(err)
                         return
                     }
+                    c.Close()
+                    return
+                case setProtocol:
+                    c.encoder.SetProtocol(cmd.protocol)
                 }
             }
         }
@@ -373,6 +711,12 @@ func (c *Client) Quit() <-chan struct{} {
     return c.quit
 }
 
+// Gets a channel that is closed once the output go routine has stopped after the client was closed. Bytes that were
+// written to the client are no longer read once it is closed, so they can be reused or freed.
+func (c *Client) OutputStopped() <-chan struct{} {
+    return c.outputStopped
+}
+
 // Gets if the client is closed.
 func (c *Client) Closed() bool {
     c.mutex.Lock()
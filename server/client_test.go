@@ -0,0 +1,42 @@
+package server
+
+import (
+    "go.uber.org/zap"
+    "net"
+    "testing"
+    "time"
+)
+
+func TestClient_Write_Closed(t *testing.T) {
+    local, remote := net.Pipe()
+    defer remote.Close()
+
+    cfg := NewDefaultClientConfig()
+    cfg.OutputCapacity, cfg.CommandCapacity = 16, 1
+
+    client := cfg.Build(local, zap.NewNop(), MailRouter{})
+    client.Process()
+
+    // Nothing is read from the other end of the pipe, so the output go routine blocks flushing and the queue fills up.
+    errs := make(chan error, 1)
+    go func() {
+        for {
+            if err := client.Write(make([]byte, 16)); err != nil {
+                errs <- err
+                return
+            }
+        }
+    }()
+
+    time.Sleep(50 * time.Millisecond)
+    client.Close()
+
+    select {
+    case err := <-errs:
+        if err != ErrClosed {
+            t.Errorf("expected ErrClosed, got %v", err)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("expected writing to a full queue to stop once the client closed")
+    }
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+    "sync"
+    "time"
+)
+
+// A token bucket that limits the rate that bytes are written at. The bucket fills at the rate up to the burst, every
+// byte that is written takes a token. Bytes can be taken beyond the tokens in the bucket, which puts the bucket into
+// debt that later writes have to wait out, so writes larger than the burst are still allowed. A nil bucket does not
+// limit anything. Safe to be shared by multiple go routines.
+type TokenBucket struct {
+    // The number of tokens that are added to the bucket every second.
+    rate float64
+
+    // The maximum number of tokens that the bucket can hold.
+    burst float64
+
+    // The tokens in the bucket as of the last update, negative when the bucket is in debt. Administered by the mutex.
+    tokens float64
+    last   time.Time
+    mutex  sync.Mutex
+}
+
+// Creates a token bucket that fills at a rate in bytes per second up to a burst of bytes. The bucket starts full.
+// Returns nil if the rate is not positive, which does not limit anything.
+func NewTokenBucket(rate, burst int) *TokenBucket {
+    if rate <= 0 {
+        return nil
+    }
+
+    if burst < 1 {
+        burst = 1
+    }
+
+    return &TokenBucket{
+        rate:   float64(rate),
+        burst:  float64(burst),
+        tokens: float64(burst),
+        last:   time.Now(),
+    }
+}
+
+// Takes tokens from the bucket without waiting for them and returns how long the caller has to wait for the bucket to
+// pay off its debt.
+func (b *TokenBucket) Reserve(n int) time.Duration {
+    if b == nil {
+        return 0
+    }
+
+    b.mutex.Lock()
+    defer b.mutex.Unlock()
+
+    now := time.Now()
+    b.tokens += now.Sub(b.last).Seconds() * b.rate
+    if b.tokens > b.burst {
+        b.tokens = b.burst
+    }
+    b.last = now
+
+    b.tokens -= float64(n)
+    if b.tokens >= 0 {
+        return 0
+    }
+    return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Takes tokens from the bucket without waiting for them, any debt is left for later writes to wait out.
+func (b *TokenBucket) Take(n int) {
+    b.Reserve(n)
+}
+
+// Takes tokens from the bucket and waits until the bucket is out of debt. Returns ErrClosed if the quit channel is
+// closed while waiting.
+func (b *TokenBucket) Wait(n int, quit <-chan struct{}) error {
+    return sleep(b.Reserve(n), quit)
+}
+
+// Sleeps for a duration. Returns ErrClosed if the quit channel is closed before the duration has passed.
+func sleep(delay time.Duration, quit <-chan struct{}) error {
+    if delay <= 0 {
+        return nil
+    }
+
+    timer := time.NewTimer(delay)
+    defer timer.Stop()
+
+    select {
+    case <-quit:
+        return ErrClosed
+    case <-timer.C:
+        return nil
+    }
+}
+
+// Takes tokens from every bucket and waits until all of them are out of debt. Returns ErrClosed if the quit channel is
+// closed while waiting.
+func WaitAll(n int, quit <-chan struct{}, buckets ...*TokenBucket) error {
+    return sleep(ReserveAll(n, buckets...), quit)
+}
+
+// Takes tokens from every bucket up front and returns how long to wait for all of them to be out of debt. The longest
+// debt is waited out rather than the debt of each bucket in turn, which would wait out the debt of every bucket.
+func ReserveAll(n int, buckets ...*TokenBucket) time.Duration {
+    var delay time.Duration
+    for _, b := range buckets {
+        if d := b.Reserve(n); d > delay {
+            delay = d
+        }
+    }
+    return delay
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+    "testing"
+    "time"
+)
+
+func TestTokenBucket(t *testing.T) {
+    bucket := NewTokenBucket(1000, 100)
+
+    if delay := bucket.Reserve(100); delay != 0 {
+        t.Errorf("expected the burst to be taken without waiting, got %v", delay)
+    }
+
+    if delay := bucket.Reserve(500); delay < 400*time.Millisecond || delay > 500*time.Millisecond {
+        t.Errorf("expected to wait about half a second for 500 bytes at 1000 bytes per second, got %v", delay)
+    }
+}
+
+func TestTokenBucket_Nil(t *testing.T) {
+    var bucket *TokenBucket
+    if bucket != NewTokenBucket(0, 100) {
+        t.Error("expected a bucket without a rate to be nil")
+    }
+
+    if err := bucket.Wait(1<<20, nil); err != nil {
+        t.Errorf("expected a nil bucket not to limit writes, got %v", err)
+    }
+}
+
+func TestTokenBucket_Wait_Quit(t *testing.T) {
+    bucket := NewTokenBucket(1, 1)
+
+    quit := make(chan struct{})
+    close(quit)
+
+    if err := bucket.Wait(10, quit); err != ErrClosed {
+        t.Errorf("expected waiting to stop when quit is closed, got %v", err)
+    }
+}
+
+func TestWaitAll(t *testing.T) {
+    fast, slow := NewTokenBucket(1000000, 1), NewTokenBucket(1000, 1)
+
+    start := time.Now()
+    if err := WaitAll(100, nil, fast, slow, nil); err != nil {
+        t.Fatal(err)
+    }
+
+    if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > time.Second {
+        t.Errorf("expected to wait out the debt of the slowest bucket, waited %v", elapsed)
+    }
+}
@@ -2,7 +2,9 @@ package server
 
 import (
     "errors"
+    "fmt"
     "github.com/sprinkle-it/donut/message"
+    "reflect"
 )
 
 // Type declaration for handlers that execute logic for received mail. Implementations of this type should never
@@ -21,32 +23,75 @@ type MailReceiver struct {
 // be thousands or tens to hundreds of thousands per minute. This adds up quickly, the scheduler and garbage collector
 // would be strained. The alternative to this implementation is to pass the message to a queue where a worker accepts a
 // publish job. This has similar issues with locking out workers and in my opinion seems to be extraneous.
+//
+// Handlers are matched by the type of the message rather than its identifier because the identifier of a message can
+// differ between the protocols of client revisions.
 type MailRouter struct {
-    handlers map[uint8]MailHandler
+    handlers map[reflect.Type]MailHandler
+
+    // The messages that are accepted before a client has negotiated the revision of its protocol.
     accepted map[uint8]message.Config
+
+    // The protocols of the supported client revisions, may be nil if there are none.
+    protocols *message.Registry
 }
 
-func NewMailRouter(receivers []MailReceiver) (MailRouter, error) {
+func NewMailRouter(receivers []MailReceiver, protocols *message.Registry) (MailRouter, error) {
     router := MailRouter{
-        handlers: make(map[uint8]MailHandler),
-        accepted: make(map[uint8]message.Config),
+        handlers:  make(map[reflect.Type]MailHandler),
+        accepted:  make(map[uint8]message.Config),
+        protocols: protocols,
     }
 
     for _, receiver := range receivers {
         for _, descriptor := range receiver.Accept {
-            if _, ok := router.handlers[descriptor.Id]; ok {
+            if err := descriptor.Validate(); err != nil {
+                return MailRouter{}, err
+            }
+
+            if descriptor.Direction != message.DirectionInbound {
+                return MailRouter{}, fmt.Errorf("server: receivers can only accept inbound messages, message %d (%T) "+
+                    "is %s", descriptor.Id, descriptor.New(), descriptor.Direction)
+            }
+
+            if _, ok := router.accepted[descriptor.Id]; ok {
                 return MailRouter{}, errors.New("server: multiple receivers cannot accept the same message")
             }
-            router.handlers[descriptor.Id] = receiver.Handler
+            router.handlers[message.TypeOf(descriptor.New())] = receiver.Handler
             router.accepted[descriptor.Id] = descriptor
         }
     }
 
+    // Every message of every protocol needs a receiver, otherwise it would be decoded and then silently dropped.
+    for _, revision := range protocols.Revisions() {
+        protocol, _ := protocols.Lookup(revision)
+        for _, config := range protocol.Inbound() {
+            if _, ok := router.handlers[message.TypeOf(config.New())]; !ok {
+                return MailRouter{}, fmt.Errorf("server: no receiver accepts message %T of revision %d",
+                    config.New(), revision)
+            }
+        }
+    }
+
     return router, nil
 }
 
+// Gets the messages that clients using the given protocol can send, which are the messages accepted before
+// negotiating overridden by the messages of the protocol.
+func (r MailRouter) accepts(protocol *message.Protocol) map[uint8]message.Config {
+    configs := make(map[uint8]message.Config, len(r.accepted)+len(protocol.Inbound()))
+    for id, config := range r.accepted {
+        configs[id] = config
+    }
+
+    for id, config := range protocol.Inbound() {
+        configs[id] = config
+    }
+    return configs
+}
+
 func (r MailRouter) Publish(source *Client, msg message.Message) {
-    if handler, ok := r.handlers[msg.Config().Id]; ok {
+    if handler, ok := r.handlers[message.TypeOf(msg)]; ok {
         handler(Mail{Source: source, Message: msg})
     }
 }
@@ -0,0 +1,40 @@
+package server
+
+import "github.com/sprinkle-it/donut/metrics"
+
+const (
+    inbound  = "inbound"
+    outbound = "outbound"
+)
+
+var (
+    connectedClients = metrics.NewGauge(metrics.Opts{
+        Name: "donut_server_connected_clients",
+        Help: "Number of clients currently registered to the server.",
+    })
+
+    acceptRejections = metrics.NewCounter(metrics.Opts{
+        Name: "donut_server_accept_rejections_total",
+        Help: "Number of connections that were closed because the server was at capacity.",
+    })
+
+    messagesTotal = metrics.NewCounterVec(metrics.Opts{
+        Name: "donut_server_messages_total",
+        Help: "Number of messages decoded from or encoded to clients by direction and opcode.",
+    }, "direction", "opcode")
+
+    messageBytesTotal = metrics.NewCounterVec(metrics.Opts{
+        Name: "donut_server_message_bytes_total",
+        Help: "Number of bytes of messages decoded from or encoded to clients by direction and opcode.",
+    }, "direction", "opcode")
+
+    decodeErrors = metrics.NewCounter(metrics.Opts{
+        Name: "donut_server_decode_errors_total",
+        Help: "Number of errors encountered while decoding messages from clients.",
+    })
+
+    decodeRecoveries = metrics.NewCounterVec(metrics.Opts{
+        Name: "donut_server_decode_recoveries_total",
+        Help: "Number of messages that could not be decoded but were skipped or dropped by action and opcode.",
+    }, "action", "opcode")
+)
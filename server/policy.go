@@ -0,0 +1,36 @@
+package server
+
+import (
+    "github.com/sprinkle-it/donut/message"
+)
+
+// Stage that decode rules are declared under to apply to clients in every stage.
+const StageAny Stage = "*"
+
+// The policy that decides whether a client survives a message that cannot be decoded. Messages are looked up by the
+// stage that the client is in first, then by StageAny. Errors that leave the stream out of sync, such as messages
+// exceeding the input capacity, are always fatal. The zero value treats every error as fatal.
+type DecodePolicy struct {
+    // The sizes of unrecognized messages that are skipped by stage and identifier. Only messages whose size is known
+    // can be skipped, otherwise the start of the next message cannot be found.
+    Skip map[Stage]map[uint8]message.Size
+
+    // The messages that are dropped rather than closing the client when their payload fails to decode, by stage and
+    // identifier.
+    Drop map[Stage]map[uint8]bool
+}
+
+// Gets the size that an unrecognized message is skipped with, if it can be skipped by clients in the stage.
+func (p DecodePolicy) skip(stage Stage, id uint8) (message.Size, bool) {
+    for _, s := range [...]Stage{stage, StageAny} {
+        if size, ok := p.Skip[s][id]; ok {
+            return size, true
+        }
+    }
+    return 0, false
+}
+
+// Checks if a message that failed to decode is dropped for clients in the stage.
+func (p DecodePolicy) drop(stage Stage, id uint8) bool {
+    return p.Drop[stage][id] || p.Drop[StageAny][id]
+}
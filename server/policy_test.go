@@ -0,0 +1,120 @@
+package server
+
+import (
+    "errors"
+    "github.com/sprinkle-it/donut/buffer"
+    "github.com/sprinkle-it/donut/message"
+    "go.uber.org/zap"
+    "net"
+    "testing"
+)
+
+func TestDecodePolicy_Skip(t *testing.T) {
+    policy := DecodePolicy{
+        Skip: map[Stage]map[uint8]message.Size{
+            "game":   {1: 4},
+            StageAny: {1: 8, 2: message.SizeVariableByte},
+        },
+    }
+
+    tests := []struct {
+        stage Stage
+        id    uint8
+        size  message.Size
+        ok    bool
+    }{
+        {stage: "game", id: 1, size: 4, ok: true},
+        {stage: "file", id: 1, size: 8, ok: true},
+        {stage: "game", id: 2, size: message.SizeVariableByte, ok: true},
+        {stage: "game", id: 3},
+    }
+
+    for _, test := range tests {
+        if size, ok := policy.skip(test.stage, test.id); size != test.size || ok != test.ok {
+            t.Errorf("expected message %d in stage %s to skip with %d %v, got %d %v", test.id, test.stage, test.size,
+                test.ok, size, ok)
+        }
+    }
+
+    if _, ok := (DecodePolicy{}).skip("game", 1); ok {
+        t.Error("expected the zero policy not to skip anything")
+    }
+}
+
+func TestDecodePolicy_Drop(t *testing.T) {
+    policy := DecodePolicy{
+        Drop: map[Stage]map[uint8]bool{
+            "game":   {1: true},
+            StageAny: {2: true},
+        },
+    }
+
+    tests := []struct {
+        stage Stage
+        id    uint8
+        drop  bool
+    }{
+        {stage: "game", id: 1, drop: true},
+        {stage: "file", id: 1},
+        {stage: "file", id: 2, drop: true},
+        {stage: "game", id: 3},
+    }
+
+    for _, test := range tests {
+        if drop := policy.drop(test.stage, test.id); drop != test.drop {
+            t.Errorf("expected message %d in stage %s to be dropped %v, got %v", test.id, test.stage, test.drop, drop)
+        }
+    }
+}
+
+// Inbound message that always fails to decode.
+type malformed struct{}
+
+func (malformed) Config() message.Config { return message.Config{Id: 7, Size: 1, Direction: message.DirectionInbound} }
+
+func (malformed) Decode(*buffer.ByteBuffer, int) error { return errors.New("malformed") }
+
+func TestClient_Recover(t *testing.T) {
+    local, remote := net.Pipe()
+    defer remote.Close()
+
+    cfg := NewDefaultClientConfig()
+    cfg.DecodePolicy = DecodePolicy{
+        Skip: map[Stage]map[uint8]message.Size{StageAny: {50: 2}},
+        Drop: map[Stage]map[uint8]bool{StageConnected: {7: true}},
+    }
+
+    client := cfg.Build(local, zap.NewNop(), MailRouter{})
+    defer client.Close()
+
+    config := malformed{}.Config()
+    config.New = message.Singleton(malformed{})
+    client.decoder.SetConfigs(map[uint8]message.Config{7: config})
+
+    // An unrecognized message that is skipped, a message that fails to decode and is dropped, then an unrecognized
+    // message that is not skipped.
+    _, _ = client.input.Write([]byte{50, 1, 2, 7, 0, 51})
+
+    // The dropped message fails with the error of the message rather than a sentinel, which is left nil.
+    for _, expected := range []error{message.ErrUnrecognized, message.ErrSkipped, nil} {
+        _, err := client.decoder.Decode(&client.input)
+
+        decodeErr, ok := err.(*message.DecodeError)
+        if !ok || expected != nil && decodeErr.Err != expected {
+            t.Fatalf("expected a decode error of %v, got %v", expected, err)
+        }
+
+        if !client.recover(err) {
+            t.Fatalf("expected the client to recover from %v", err)
+        }
+    }
+
+    _, err := client.decoder.Decode(&client.input)
+    if client.recover(err) {
+        t.Errorf("expected the client not to recover from an unrecognized message that is not skipped, got %v", err)
+    }
+
+    if client.recover(errors.New("message: out of sync")) {
+        t.Error("expected the client not to recover from an error that is not a decode error")
+    }
+}
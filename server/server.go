@@ -1,31 +1,37 @@
 package server
 
 import (
+    "errors"
     "fmt"
+    "github.com/sprinkle-it/donut/message"
     "go.uber.org/zap"
     "net"
 )
 
 type Config struct {
-    LoggerConfig   zap.Config
+    // The shared logger, the server logs to a sub-logger named "server".
+    Logger         *zap.Logger
     ClientCapacity int
     ClientConfig   ClientConfig
     Receivers      []MailReceiver
+
+    // The protocols of the client revisions that are supported. Clients switch to the protocol of their revision when
+    // they send a message that negotiates it.
+    Protocols *message.Registry
 }
 
 func (cfg Config) Build() (*Server, error) {
-    logger, err := cfg.LoggerConfig.Build()
-    if err != nil {
-        return nil, err
+    if cfg.Logger == nil {
+        return nil, errors.New("server: a logger is required")
     }
 
-    router, err := NewMailRouter(cfg.Receivers)
+    router, err := NewMailRouter(cfg.Receivers, cfg.Protocols)
     if err != nil {
         return nil, err
     }
 
     return &Server{
-        logger:         logger,
+        logger:         cfg.Logger.Named("server"),
         clientCapacity: cfg.ClientCapacity,
         clientFactory:  cfg.ClientConfig.Build,
         clients:        make(map[uint64]*Client, cfg.ClientCapacity),
@@ -51,6 +57,28 @@ func New(config Config) (*Server, error) {
 
 func (s *Server) execute(cmd command) { s.commands <- cmd }
 
+// Gets all of the clients that are currently registered to the server.
+func (s *Server) Clients() []*Client {
+    reply := make(chan []*Client, 1)
+    s.execute(listClients{reply: reply})
+    return <-reply
+}
+
+// Closes the client with the given identifier. Returns false if there is no client registered with the identifier.
+func (s *Server) Kick(id uint64) bool {
+    reply := make(chan bool, 1)
+    s.execute(kickClient{id: id, reply: reply})
+    return <-reply
+}
+
+// Sends a message to every client that is in the given stage and flushes it immediately. Returns the number of
+// clients the message was sent to.
+func (s *Server) Broadcast(stage Stage, msg message.Outbound) int {
+    reply := make(chan int, 1)
+    s.execute(broadcastMessage{stage: stage, msg: msg, reply: reply})
+    return <-reply
+}
+
 func (s *Server) process() {
     go func() {
         for cmd := range s.commands {
@@ -66,7 +94,11 @@ func (s *Server) Listen(port int) error {
     }
 
     s.logger.Info("Listening", zap.Int("port", port))
+    return s.Serve(listener)
+}
 
+// Accepts connections from the listener until it fails, registering a client for each of them.
+func (s *Server) Serve(listener net.Listener) error {
     s.process()
 
     for {
@@ -95,11 +127,13 @@ func (cmd acceptConnection) Execute(server *Server) {
             zap.Stringer("address", cmd.connection.RemoteAddr()),
         )
         _ = cmd.connection.Close()
+        acceptRejections.Inc()
         return
     }
 
-    cli := server.clientFactory(cmd.connection, server.logger, server.router)
+    cli := server.clientFactory(cmd.connection, server.logger.Named("client"), server.router)
     server.clients[cli.Id()] = cli
+    connectedClients.Inc()
 
     // Register a callback that will unregister the client from the server when it is closed.
     cli.OnClosed(func(cli *Client) {
@@ -121,9 +155,67 @@ type unregisterClient struct {
 
 func (cmd unregisterClient) Execute(server *Server) {
     delete(server.clients, cmd.client.Id())
+    connectedClients.Dec()
 
     server.logger.Info("Unregistered client",
         zap.Uint64("id", cmd.client.Id()),
         zap.Stringer("address", cmd.client.RemoteAddress()),
     )
 }
+
+// Replies with all of the clients that are currently registered.
+type listClients struct {
+    reply chan<- []*Client
+}
+
+func (cmd listClients) Execute(server *Server) {
+    clients := make([]*Client, 0, len(server.clients))
+    for _, cli := range server.clients {
+        clients = append(clients, cli)
+    }
+    cmd.reply <- clients
+}
+
+// Closes a client by its identifier. The client will be unregistered once its close callback is called.
+type kickClient struct {
+    id    uint64
+    reply chan<- bool
+}
+
+func (cmd kickClient) Execute(server *Server) {
+    cli, ok := server.clients[cmd.id]
+    if !ok {
+        cmd.reply <- false
+        return
+    }
+
+    server.logger.Info("Kicking client",
+        zap.Uint64("id", cli.Id()),
+        zap.Stringer("address", cli.RemoteAddress()),
+    )
+
+    cli.Close()
+    cmd.reply <- true
+}
+
+// Sends a message to every registered client that is in a stage.
+type broadcastMessage struct {
+    stage Stage
+    msg   message.Outbound
+    reply chan<- int
+}
+
+func (cmd broadcastMessage) Execute(server *Server) {
+    count := 0
+    for _, cli := range server.clients {
+        if cli.Stage() != cmd.stage {
+            continue
+        }
+
+        // Sending to the client may block while its output is being flushed, send it from another go routine so that
+        // a slow client does not hold up the server. The send gives up once the client is closed.
+        go func(cli *Client) { _ = cli.SendNow(cmd.msg) }(cli)
+        count++
+    }
+    cmd.reply <- count
+}
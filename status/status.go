@@ -7,105 +7,122 @@ import (
 
 var (
     okayConfig = message.Config{
-        Id:   0,
-        Size: 0,
-        New:  message.Singleton(Okay),
+        Id:        0,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(Okay),
     }
 
     invalidCredentialsConfig = message.Config{
-        Id:   3,
-        Size: 0,
-        New:  message.Singleton(InvalidCredentials),
+        Id:        3,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(InvalidCredentials),
     }
 
     accountDisabledConfig = message.Config{
-        Id:   4,
-        Size: 0,
-        New:  message.Singleton(AccountDisabled),
+        Id:        4,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(AccountDisabled),
     }
 
     alreadyOnlineConfig = message.Config{
-        Id:   5,
-        Size: 0,
-        New:  message.Singleton(AlreadyOnline),
+        Id:        5,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(AlreadyOnline),
     }
 
     unsupportedVersionConfig = message.Config{
-        Id:   6,
-        Size: 0,
-        New:  message.Singleton(UnsupportedVersion),
+        Id:        6,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(UnsupportedVersion),
     }
 
     fullConfig = message.Config{
-        Id:   7,
-        Size: 0,
-        New:  message.Singleton(Full),
+        Id:        7,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(Full),
     }
 
     loginLimitExceededConfig = message.Config{
-        Id:   9,
-        Size: 0,
-        New:  message.Singleton(LoginLimitExceeded),
+        Id:        9,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(LoginLimitExceeded),
     }
 
     serverUpdateConfig = message.Config{
-        Id:   14,
-        Size: 0,
-        New:  message.Singleton(ServerUpdate),
+        Id:        14,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(ServerUpdate),
     }
 
     closedBetaConfig = message.Config{
-        Id:   19,
-        Size: 0,
-        New:  message.Singleton(ClosedBeta),
+        Id:        19,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(ClosedBeta),
     }
 
     profileTransferConfig = message.Config{
-        Id:   21,
-        Size: 1,
-        New:  func() message.Message { return &ProfileTransfer{} },
+        Id:        21,
+        Size:      1,
+        Direction: message.DirectionOutbound,
+        New:       func() message.Message { return &ProfileTransfer{} },
     }
 
     malformedLoginPacketConfig = message.Config{
-        Id:   22,
-        Size: 0,
-        New:  message.Singleton(MalformedLoginPacket),
+        Id:        22,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(MalformedLoginPacket),
     }
 
     errorLoadingProfileConfig = message.Config{
-        Id:   24,
-        Size: 0,
-        New:  message.Singleton(ErrorLoadingProfile),
+        Id:        24,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(ErrorLoadingProfile),
     }
 
     blockedAddressConfig = message.Config{
-        Id:   26,
-        Size: 0,
-        New:  message.Singleton(BlockedAddress),
+        Id:        26,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(BlockedAddress),
     }
 
     serviceUnavailableConfig = message.Config{
-        Id:   27,
-        Size: 0,
-        New:  message.Singleton(ServiceUnavailable),
+        Id:        27,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(ServiceUnavailable),
     }
 
     customRejectionConfig = message.Config{
-        Id:   29,
-        Size: message.SizeVariableShort,
-        New:  func() message.Message { return &CustomRejection{} },
+        Id:        29,
+        Size:      message.SizeVariableShort,
+        Direction: message.DirectionOutbound,
+        New:       func() message.Message { return &CustomRejection{} },
     }
 
     enterPinConfig = message.Config{
-        Id:   56,
-        Size: 0,
-        New:  message.Singleton(EnterPin),
+        Id:        56,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(EnterPin),
     }
 
     invalidPinConfig = message.Config{
-        Id:   57,
-        Size: 0,
-        New:  message.Singleton(InvalidPin),
+        Id:        57,
+        Size:      0,
+        Direction: message.DirectionOutbound,
+        New:       message.Singleton(InvalidPin),
     }
 
     Okay                 = okay{}